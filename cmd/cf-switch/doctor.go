@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+const (
+	// Timeout for all Cloudflare API token permission checks.
+	permissionCheckTimeout = time.Minute
+	// Where Cloudflare API token permissions are edited.
+	apiTokensURL = "https://dash.cloudflare.com/profile/api-tokens"
+)
+
+// doctor checks the configured Cloudflare API token, writes a report to w, and returns the exit code.
+func doctor(ctx context.Context, w io.Writer, logger *slog.Logger) int {
+	config, err := types.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(w, "Invalid configuration:", err)
+		return 1
+	}
+
+	cfClient, err := newCloudflareClient(config, logger)
+	if err != nil {
+		fmt.Fprintln(w, "Invalid Cloudflare API configuration:", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, permissionCheckTimeout)
+	defer cancel()
+	report := cfClient.CheckPermissions(ctx, config.CloudflareZoneID)
+
+	printPermissionReport(w, config, report)
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
+
+// newCloudflareClient creates a Cloudflare API client for config.
+func newCloudflareClient(
+	config *types.Config,
+	logger *slog.Logger,
+	opts ...cloudflare.Option,
+) (*cloudflare.Client, error) {
+	httpClient, err := cloudflare.NewHTTPClient(cloudflare.HTTPConfig{
+		ProxyURL:       config.CloudflareAPIProxy,
+		CAFile:         config.CloudflareAPICAFile,
+		Timeout:        config.CloudflareAPITimeout,
+		ConnectTimeout: config.CloudflareAPIConnectTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloudflare HTTP client: %w", err)
+	}
+
+	opts = append([]cloudflare.Option{
+		cloudflare.WithBaseURL(config.CloudflareAPIBaseURL),
+		cloudflare.WithHTTPClient(httpClient),
+	}, opts...)
+	return cloudflare.NewClient(config.CloudflareAPIToken, logger, opts...), nil
+}
+
+// printPermissionReport writes the results of a permission check as a table.
+func printPermissionReport(w io.Writer, config *types.Config, report cloudflare.PermissionReport) {
+	fmt.Fprintf(w, "Cloudflare API token check for zone %s (%s)\n\n", config.CloudflareZoneID, config.CloudflareAPIBaseURL)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:mnd // Column padding.
+	fmt.Fprintln(tw, "STATUS\tCHECK\tREQUIRED PERMISSION\tDETAIL")
+	for _, check := range report {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", check.Status, check.Name, check.Permission, check.Detail)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	switch {
+	case report.OK() && report.Unverified():
+		fmt.Fprintln(w, "All required checks passed; unverified checks are not needed to run cf-switch.")
+	case report.OK():
+		fmt.Fprintln(w, "All checks passed.")
+	case report.Missing():
+		fmt.Fprintln(w, "The API token lacks required permissions. Edit it at", apiTokensURL)
+	default:
+		fmt.Fprintln(w, "Some checks could not be completed; see the details above.")
+	}
+}
+
+// logPermissionReport logs the results of the startup permission check.
+func logPermissionReport(ctx context.Context, logger *slog.Logger, report cloudflare.PermissionReport) {
+	for _, check := range report {
+		switch check.Status {
+		case cloudflare.CheckOK:
+			logger.DebugContext(ctx, "Cloudflare permission check passed", "check", check.Name, "detail", check.Detail)
+		case cloudflare.CheckMissing:
+			logger.ErrorContext(ctx, "Cloudflare API token lacks permission",
+				"check", check.Name,
+				"required_permission", check.Permission,
+				"detail", check.Detail)
+		case cloudflare.CheckUnverified:
+			logger.InfoContext(ctx, "Cloudflare permission could not be verified",
+				"check", check.Name,
+				"required_permission", check.Permission,
+				"detail", check.Detail)
+		case cloudflare.CheckError:
+			logger.WarnContext(ctx, "Cloudflare permission check failed",
+				"check", check.Name,
+				"required_permission", check.Permission,
+				"detail", check.Detail)
+		}
+	}
+}
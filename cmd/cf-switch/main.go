@@ -4,16 +4,22 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
+	"github.com/meyeringh/cf-switch/internal/auth"
 	"github.com/meyeringh/cf-switch/internal/cloudflare"
 	"github.com/meyeringh/cf-switch/internal/kube"
+	"github.com/meyeringh/cf-switch/internal/notify"
 	"github.com/meyeringh/cf-switch/internal/reconcile"
 	"github.com/meyeringh/cf-switch/internal/server"
+	"github.com/meyeringh/cf-switch/internal/tracing"
 	"github.com/meyeringh/cf-switch/pkg/types"
 )
 
@@ -38,6 +44,12 @@ func main() {
 
 	ctx := context.Background()
 
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		// Keep the report readable; only warnings go to stderr.
+		doctorLogger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+		os.Exit(doctor(ctx, os.Stdout, doctorLogger))
+	}
+
 	// Load configuration.
 	config, err := types.LoadConfig()
 	if err != nil {
@@ -48,12 +60,36 @@ func main() {
 	logger.Info("Starting cf-switch",
 		"version", version,
 		"zone_id", config.CloudflareZoneID,
+		"cloudflare_api", config.CloudflareAPIBaseURL,
 		"hostnames", config.DestHostnames,
 		"http_addr", config.HTTPAddr,
 		"reconcile_interval", config.ReconcileInterval,
+		"leader_election", config.LeaderElection,
 		"running_locally", config.RunningLocally)
 
-	var authToken string
+	// Set up tracing before any component creates spans.
+	shutdownTracing, err := tracing.Setup(ctx, config.TracingEnabled, version)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	var (
+		authToken         string
+		fileTokens        []auth.Token
+		namedTokens       []auth.Token
+		kubeClient        *kube.Client
+		kubeAuthenticator *kube.ServiceAccountAuthenticator
+	)
+
+	if config.AuthTokensFile != "" {
+		fileTokens, err = auth.LoadTokensFile(config.AuthTokensFile)
+		if err != nil {
+			logger.Error("Failed to load API tokens file", "error", err)
+			os.Exit(1)
+		}
+		namedTokens = fileTokens
+	}
 
 	if config.RunningLocally {
 		// When running locally, use a static development token
@@ -61,9 +97,9 @@ func main() {
 		logger.Info("Running in local development mode", "auth_token", authToken)
 	} else {
 		// Initialize Kubernetes client for secret management.
-		kubeClient, kubeErr := kube.NewClient(config.Namespace, logger)
-		if kubeErr != nil {
-			logger.Error("Failed to create Kubernetes client", "error", kubeErr)
+		kubeClient, err = kube.NewClient(config.Namespace, logger)
+		if err != nil {
+			logger.Error("Failed to create Kubernetes client", "error", err)
 			os.Exit(1)
 		}
 
@@ -77,13 +113,114 @@ func main() {
 		logger.Info("Authentication token ready",
 			"secret_name", kube.SecretName,
 			"namespace", config.Namespace)
+
+		// Load named tokens stored alongside the default token.
+		secretTokens, tokensErr := kubeClient.GetNamedTokens(ctx)
+		if tokensErr != nil {
+			logger.Error("Failed to read named API tokens", "error", tokensErr)
+			os.Exit(1)
+		}
+		if secretTokens != nil {
+			parsed, parseErr := auth.ParseTokens(secretTokens)
+			if parseErr != nil {
+				logger.Error("Failed to parse named API tokens", "secret_name", kube.SecretName, "error", parseErr)
+				os.Exit(1)
+			}
+			namedTokens = append(slices.Clone(fileTokens), parsed...)
+		}
+
+		if config.ServiceAccountAuth {
+			var authErr error
+			kubeAuthenticator, authErr = kube.NewServiceAccountAuthenticator(
+				kubeClient, config.ServiceAccountIssuer, config.ServiceAccountAudiences, logger)
+			if authErr != nil {
+				logger.Error("Failed to set up ServiceAccount token authentication", "error", authErr)
+				os.Exit(1)
+			}
+		}
 	}
 
+	// Metrics are shared by the Cloudflare client, reconciler, and HTTP server.
+	metrics := server.NewMetrics()
+
 	// Initialize Cloudflare client.
-	cfClient := cloudflare.NewClient(config.CloudflareAPIToken, logger)
+	cfClient, err := newCloudflareClient(config, logger,
+		cloudflare.WithCircuitBreaker(cloudflare.CircuitBreakerConfig{
+			FailureThreshold: config.CloudflareCircuitThreshold,
+			OpenTimeout:      config.CloudflareCircuitOpenTimeout,
+			OnStateChange:    metrics.ObserveCircuitState,
+		}),
+		cloudflare.WithInstrumentation(metrics.ObserveCloudflareRequest))
+	if err != nil {
+		logger.Error("Failed to create Cloudflare client", "error", err)
+		os.Exit(1)
+	}
+	if state := cfClient.CircuitState(); state != "" {
+		metrics.ObserveCircuitState(state)
+	}
+
+	// Report missing API token permissions early; only "cf-switch doctor" fails on them.
+	if config.CloudflareStartupCheck {
+		checkCtx, cancelCheck := context.WithTimeout(ctx, permissionCheckTimeout)
+		report := cfClient.CheckPermissions(checkCtx, config.CloudflareZoneID)
+		cancelCheck()
+		logPermissionReport(ctx, logger, report)
+		if report.Missing() {
+			logger.Warn("Cloudflare API token lacks required permissions, run \"cf-switch doctor\" for a report")
+		}
+	}
+
+	// With leader election only the leader changes the rule; the others serve reads and forward changes.
+	var (
+		reconciler *reconcile.Reconciler
+		elector    *kube.LeaderElector
+		peerKey    []byte
+	)
+	reconcilerOpts := []reconcile.Option{reconcile.WithMetrics(metrics)}
+	if config.LeaderElection {
+		address, addrErr := advertiseAddress(config.PodIP, config.HTTPAddr)
+		if addrErr != nil {
+			logger.Error("Failed to determine the address for leader election", "error", addrErr)
+			os.Exit(1)
+		}
+		elector, err = kubeClient.NewLeaderElector(kube.LeaderElectionConfig{
+			PodName:       config.PodName,
+			Address:       address,
+			LeaseDuration: config.LeaderElectionLeaseDuration,
+			OnStartedLeading: func(context.Context) {
+				reconciler.Trigger(reconcile.ReasonLeaderElected)
+			},
+		})
+		if err != nil {
+			logger.Error("Failed to create leader elector", "error", err)
+			os.Exit(1)
+		}
+		reconcilerOpts = append(reconcilerOpts, reconcile.WithLeadership(elector))
+
+		// Followers sign the identities of forwarded callers with the key shared through the auth secret.
+		if peerKey, err = kubeClient.EnsurePeerKey(ctx); err != nil {
+			logger.Error("Failed to load the peer key", "error", err)
+			os.Exit(1)
+		}
+	}
 
 	// Initialize reconciler.
-	reconciler := reconcile.NewReconciler(cfClient, config, logger)
+	reconciler = reconcile.NewReconciler(cfClient, config, logger, reconcilerOpts...)
+
+	// Start webhook notifier if configured, subscribing before the initial reconcile publishes its event.
+	notifyCtx, stopNotifier := context.WithCancel(ctx)
+
+	var notifier *notify.Notifier
+	if config.NotifyConfigFile != "" {
+		notifyConfig, notifyErr := notify.LoadConfig(config.NotifyConfigFile)
+		if notifyErr != nil {
+			logger.Error("Failed to load notify configuration", "error", notifyErr)
+			os.Exit(1)
+		}
+
+		notifier = notify.NewNotifier(notifyConfig, logger)
+		notifier.Start(notifyCtx, reconciler)
+	}
 
 	// Start reconciler.
 	if startErr := reconciler.Start(ctx); startErr != nil {
@@ -93,8 +230,147 @@ func main() {
 
 	logger.Info("Reconciler started successfully")
 
+	// Campaign for leadership once the reconciler can act on it.
+	electionCtx, stopElection := context.WithCancel(ctx)
+	electionDone := make(chan struct{})
+	if elector != nil {
+		go func() {
+			defer close(electionDone)
+			elector.Run(electionCtx)
+		}()
+	} else {
+		close(electionDone)
+	}
+
 	// Initialize HTTP server.
-	httpServer := server.NewServer(config.HTTPAddr, authToken, reconciler, logger)
+	var serverOpts []server.Option
+	if config.AlertmanagerConfigFile != "" {
+		alertmanagerConfig, amErr := server.LoadAlertmanagerConfig(config.AlertmanagerConfigFile)
+		if amErr != nil {
+			logger.Error("Failed to load alertmanager configuration", "error", amErr)
+			os.Exit(1)
+		}
+		serverOpts = append(serverOpts, server.WithAlertmanager(alertmanagerConfig))
+		logger.Info("Alertmanager integration enabled", "rules", len(alertmanagerConfig.Rules))
+	}
+
+	if config.SlackSigningSecret != "" {
+		serverOpts = append(serverOpts, server.WithSlack(server.SlackConfig{
+			SigningSecret:  config.SlackSigningSecret,
+			AllowedUserIDs: config.SlackAllowedUserIDs,
+			AllowAllUsers:  config.SlackAllowAllUsers,
+		}))
+		logger.Info("Slack integration enabled",
+			"allowed_users", len(config.SlackAllowedUserIDs),
+			"allow_all_users", config.SlackAllowAllUsers)
+		if len(config.SlackAllowedUserIDs) == 0 && !config.SlackAllowAllUsers {
+			logger.Warn("No Slack users may change the rule, set SLACK_ALLOWED_USER_IDS or SLACK_ALLOW_ALL_USERS")
+		}
+	}
+
+	if config.OIDCIssuerURL != "" {
+		groupScopes, scopesErr := auth.ParseGroupScopes(config.OIDCGroupScopes)
+		if scopesErr != nil {
+			logger.Error("Invalid OIDC_GROUP_SCOPES", "error", scopesErr)
+			os.Exit(1)
+		}
+
+		jwtAuthenticator, jwtErr := auth.NewJWTAuthenticator(ctx, auth.JWTConfig{
+			Issuer:        config.OIDCIssuerURL,
+			Audience:      config.OIDCAudience,
+			JWKSURL:       config.OIDCJWKSURL,
+			UsernameClaim: config.OIDCUsernameClaim,
+			GroupsClaim:   config.OIDCGroupsClaim,
+			GroupScopes:   groupScopes,
+		}, logger)
+		if jwtErr != nil {
+			logger.Error("Failed to configure OIDC authentication", "error", jwtErr)
+			os.Exit(1)
+		}
+		serverOpts = append(serverOpts, server.WithAuthenticator(jwtAuthenticator))
+		logger.Info("OIDC authentication enabled", "issuer", config.OIDCIssuerURL, "groups", len(groupScopes))
+	}
+
+	if config.TLSCertFile != "" {
+		clientScopes, scopesErr := auth.ParseGroupScopes(config.TLSClientScopes)
+		if scopesErr != nil {
+			logger.Error("Invalid TLS_CLIENT_SCOPES", "error", scopesErr)
+			os.Exit(1)
+		}
+		serverOpts = append(serverOpts, server.WithTLS(server.TLSConfig{
+			CertFile:          config.TLSCertFile,
+			KeyFile:           config.TLSKeyFile,
+			ClientCAFile:      config.TLSClientCAFile,
+			RequireClientCert: config.TLSRequireClientCert,
+			ClientScopes:      clientScopes,
+			PeerCAFile:        config.TLSPeerCAFile,
+		}))
+		logger.Info("TLS enabled",
+			"client_ca", config.TLSClientCAFile != "",
+			"require_client_cert", config.TLSRequireClientCert)
+	}
+
+	if kubeAuthenticator != nil {
+		serverOpts = append(serverOpts, server.WithAuthenticator(kubeAuthenticator))
+		logger.Info("ServiceAccount token authentication enabled", "audiences", config.ServiceAccountAudiences)
+	} else if config.ServiceAccountAuth {
+		logger.Warn("SERVICE_ACCOUNT_AUTH is ignored when running locally")
+	}
+
+	if len(namedTokens) > 0 {
+		serverOpts = append(serverOpts, server.WithTokens(namedTokens))
+		logger.Info("Named API tokens loaded", "count", len(namedTokens))
+	}
+
+	trustedProxies, err := server.ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		logger.Error("Invalid TRUSTED_PROXIES", "error", err)
+		os.Exit(1)
+	}
+	serverOpts = append(serverOpts, server.WithRateLimit(server.RateLimitConfig{
+		IPRate:           config.RateLimitIPRPS,
+		IPBurst:          config.RateLimitIPBurst,
+		TokenRate:        config.RateLimitTokenRPS,
+		TokenBurst:       config.RateLimitTokenBurst,
+		LockoutThreshold: config.AuthLockoutThreshold,
+		LockoutDuration:  config.AuthLockoutDuration,
+		TrustedProxies:   trustedProxies,
+	}))
+
+	serverOpts = append(serverOpts,
+		server.WithMetrics(metrics),
+		server.WithTokenGracePeriod(config.TokenGracePeriod),
+		server.WithReadinessMaxReconcileAge(config.ReadinessMaxReconcileAge))
+	if kubeClient != nil {
+		serverOpts = append(serverOpts, server.WithTokenRotator(kubeClient))
+	}
+	if elector != nil {
+		serverOpts = append(serverOpts, server.WithLeaderForwarding(elector, peerKey))
+	}
+
+	httpServer, err := server.NewServer(config.HTTPAddr, authToken, reconciler, logger, serverOpts...)
+	if err != nil {
+		logger.Error("Failed to create HTTP server", "error", err)
+		os.Exit(1)
+	}
+
+	// Reload API tokens when the authentication secret changes.
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	if kubeClient != nil {
+		watchErr := kubeClient.WatchAuthSecret(watchCtx, func(tokens kube.AuthSecretTokens) {
+			named := append(slices.Clone(fileTokens), tokens.Named...)
+			if updateErr := httpServer.UpdateTokens(tokens.APIToken, named); updateErr != nil {
+				logger.Error("Failed to apply API tokens from secret, keeping current tokens", "error", updateErr)
+			}
+		})
+		if watchErr != nil {
+			logger.Error("Failed to watch authentication secret", "error", watchErr)
+			os.Exit(1)
+		}
+		logger.Info("Watching authentication secret for token changes",
+			"secret_name", kube.SecretName,
+			"grace_period", config.TokenGracePeriod)
+	}
 
 	// Start HTTP server in a goroutine.
 	serverErr := make(chan error, 1)
@@ -130,10 +406,24 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
+	// Release the Lease so another replica takes over without waiting for it to expire.
+	stopElection()
+	<-electionDone
+
 	// Stop reconciler.
 	reconciler.Stop()
 	logger.Info("Reconciler stopped")
 
+	// Stop watching the authentication secret.
+	stopWatch()
+
+	// Stop webhook notifier after in-flight deliveries finish.
+	stopNotifier()
+	if notifier != nil {
+		notifier.Wait()
+		logger.Info("Webhook notifier stopped")
+	}
+
 	// Shutdown HTTP server.
 	if shutdownErr := httpServer.Shutdown(shutdownCtx); shutdownErr != nil {
 		logger.Error("Failed to shutdown HTTP server gracefully", "error", shutdownErr)
@@ -141,9 +431,23 @@ func main() {
 		logger.Info("HTTP server stopped")
 	}
 
+	// Flush spans from the shutdown.
+	if tracingErr := shutdownTracing(shutdownCtx); tracingErr != nil {
+		logger.Error("Failed to flush traces", "error", tracingErr)
+	}
+
 	logger.Info("cf-switch service shutdown complete")
 }
 
+// advertiseAddress returns the address at which other replicas reach the API served on httpAddr.
+func advertiseAddress(podIP, httpAddr string) (string, error) {
+	_, port, err := net.SplitHostPort(httpAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid HTTP_ADDR %q: %w", httpAddr, err)
+	}
+	return net.JoinHostPort(podIP, port), nil
+}
+
 // generateLocalToken generates a simple token for local development.
 func generateLocalToken() string {
 	bytes := make([]byte, tokenByteLength)
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/meyeringh/cf-switch/internal/cloudflare/cftest"
 )
 
 func TestGenerateLocalToken(t *testing.T) {
@@ -31,3 +39,78 @@ func TestGenerateLocalToken(t *testing.T) {
 	// Test fallback behavior by temporarily disabling randomness.
 	// We can't easily test this without changing the function, so we'll skip it.
 }
+
+func TestAdvertiseAddress(t *testing.T) {
+	for httpAddr, want := range map[string]string{
+		":8080":        "10.0.0.1:8080",
+		"0.0.0.0:9443": "10.0.0.1:9443",
+	} {
+		address, err := advertiseAddress("10.0.0.1", httpAddr)
+		if err != nil || address != want {
+			t.Errorf("advertiseAddress(%q) = %q, %v, want %q", httpAddr, address, err, want)
+		}
+	}
+
+	if _, err := advertiseAddress("10.0.0.1", "8080"); err == nil {
+		t.Error("expected error for an address without port")
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	fakeServer := httptest.NewServer(cftest.NewServer(cftest.NewFake(), cftest.ServerConfig{Token: "good-token"}, logger))
+	defer fakeServer.Close()
+
+	t.Setenv("DEST_HOSTNAMES", "example.com")
+	t.Setenv("CLOUDFLARE_ZONE_ID", "test-zone")
+	t.Setenv("CLOUDFLARE_API_BASE_URL", fakeServer.URL)
+
+	t.Run("valid token", func(t *testing.T) {
+		t.Setenv("CLOUDFLARE_API_TOKEN", "good-token")
+
+		var out bytes.Buffer
+		if code := doctor(context.Background(), &out, logger); code != 0 {
+			t.Errorf("expected exit code 0, got %d:\n%s", code, out.String())
+		}
+		if !strings.Contains(out.String(), "All checks passed.") {
+			t.Errorf("expected a passing report, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("token details not readable", func(t *testing.T) {
+		// A least-privilege token can verify itself and read the zone, but not read its own policies.
+		fakeAPI := cftest.NewServer(cftest.NewFake(), cftest.ServerConfig{Token: "good-token"}, logger)
+		restricted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/user/tokens/") && r.URL.Path != "/user/tokens/verify" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"success": false, "errors": [{"code": 9109, "message": "Unauthorized"}]}`))
+				return
+			}
+			fakeAPI.ServeHTTP(w, r)
+		}))
+		defer restricted.Close()
+		t.Setenv("CLOUDFLARE_API_BASE_URL", restricted.URL)
+		t.Setenv("CLOUDFLARE_API_TOKEN", "good-token")
+
+		var out bytes.Buffer
+		if code := doctor(context.Background(), &out, logger); code != 0 {
+			t.Errorf("expected exit code 0, got %d:\n%s", code, out.String())
+		}
+		if !strings.Contains(out.String(), "unverified") || !strings.Contains(out.String(), "All required checks passed") {
+			t.Errorf("expected the edit check to be unverified, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		t.Setenv("CLOUDFLARE_API_TOKEN", "bad-token")
+
+		var out bytes.Buffer
+		if code := doctor(context.Background(), &out, logger); code != 1 {
+			t.Errorf("expected exit code 1, got %d", code)
+		}
+		if !strings.Contains(out.String(), "lacks required permissions") ||
+			!strings.Contains(out.String(), "Zone > Zone WAF > Edit") {
+			t.Errorf("expected missing permissions in the report, got:\n%s", out.String())
+		}
+	})
+}
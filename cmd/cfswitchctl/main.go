@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/client"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+const (
+	// Default cf-switch API address.
+	defaultServer = "http://localhost:8080"
+	// Default namespace for the cf-switch-auth secret lookup.
+	defaultNamespace = "default"
+
+	outputTable = "table"
+	outputJSON  = "json"
+
+	usage = `Usage: cfswitchctl [flags] <command> [args]
+
+Commands:
+  status                      Show the current rule
+  enable [--for DURATION]     Enable blocking, optionally restoring the previous state after DURATION
+  disable [--for DURATION]    Disable blocking, optionally restoring the previous state after DURATION
+  hosts list                  List the hostnames the rule applies to
+  hosts add HOST...           Add hostnames
+  hosts remove HOST...        Remove hostnames
+  hosts set HOST...           Replace all hostnames
+  watch                       Stream rule changes
+
+Flags:
+`
+)
+
+var (
+	// version is set via ldflags during build.
+	version = "dev"
+)
+
+// errUsage indicates invalid command-line usage.
+var errUsage = errors.New("invalid usage")
+
+// cli holds the global flags and the API client.
+type cli struct {
+	server     string
+	token      string
+	namespace  string
+	kubeconfig string
+	output     string
+	stdout     io.Writer
+	client     client.API
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		stop()
+		os.Exit(1)
+	}
+}
+
+// run parses arguments and executes a command.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	c := &cli{stdout: stdout}
+
+	fs := flag.NewFlagSet("cfswitchctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&c.server, "server", envOrDefault("CF_SWITCH_URL", defaultServer),
+		"cf-switch API URL (env CF_SWITCH_URL)")
+	fs.StringVar(&c.token, "token", "", "API token (env CF_SWITCH_TOKEN, otherwise read from the cf-switch-auth secret)")
+	fs.StringVar(&c.namespace, "namespace", envOrDefault("CF_SWITCH_NAMESPACE", ""),
+		"Namespace of the cf-switch-auth secret (env CF_SWITCH_NAMESPACE, default: kubeconfig context)")
+	fs.StringVar(&c.kubeconfig, "kubeconfig", "", "Path to kubeconfig (default: KUBECONFIG or ~/.kube/config)")
+	fs.StringVar(&c.output, "o", outputTable, "Output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if c.output != outputTable && c.output != outputJSON {
+		fmt.Fprintf(stderr, "unknown output format %q\n", c.output)
+		return errUsage
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	if rest[0] == "version" {
+		fmt.Fprintln(stdout, "cfswitchctl", version)
+		return nil
+	}
+
+	token, err := c.resolveToken(ctx)
+	if err != nil {
+		return err
+	}
+	c.client = client.New(c.server, token, client.WithUserAgent("cfswitchctl/"+version))
+
+	switch rest[0] {
+	case "status":
+		return c.status(ctx)
+	case "enable":
+		return c.toggle(ctx, true, rest[1:], stderr)
+	case "disable":
+		return c.toggle(ctx, false, rest[1:], stderr)
+	case "hosts":
+		return c.hosts(ctx, rest[1:], stderr)
+	case "watch":
+		return c.watch(ctx)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", rest[0])
+		fs.Usage()
+		return errUsage
+	}
+}
+
+// status prints the current rule.
+func (c *cli) status(ctx context.Context) error {
+	rule, err := c.client.GetRule(ctx)
+	if err != nil {
+		return err
+	}
+	return printRule(c.stdout, c.output, rule)
+}
+
+// toggle enables or disables the rule, optionally restoring the previous state later.
+func (c *cli) toggle(ctx context.Context, enabled bool, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("toggle", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	duration := fs.Duration("for", 0, "Restore the previous state after this duration (e.g. 2h)")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+
+	var previous *types.RuleResponse
+	if *duration > 0 {
+		var err error
+		if previous, err = c.client.GetRule(ctx); err != nil {
+			return err
+		}
+	}
+
+	rule, err := c.client.Toggle(ctx, enabled)
+	if err != nil {
+		return err
+	}
+	if err = printRule(c.stdout, c.output, rule); err != nil {
+		return err
+	}
+
+	if *duration <= 0 || previous.Enabled == enabled {
+		return nil
+	}
+
+	fmt.Fprintf(stderr, "Restoring previous state in %s (Ctrl-C restores immediately)...\n", *duration)
+	timer := time.NewTimer(*duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+
+	// Restore even if interrupted.
+	rule, err = c.client.Toggle(context.WithoutCancel(ctx), previous.Enabled)
+	if err != nil {
+		return fmt.Errorf("failed to restore previous state: %w", err)
+	}
+	return printRule(c.stdout, c.output, rule)
+}
+
+// hosts runs the hosts subcommands.
+func (c *cli) hosts(ctx context.Context, args []string, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: cfswitchctl hosts list|add|remove|set [HOST...]")
+		return errUsage
+	}
+
+	if args[0] == "list" {
+		rule, err := c.client.GetRule(ctx)
+		if err != nil {
+			return err
+		}
+		return printHosts(c.stdout, c.output, rule.Hostnames)
+	}
+
+	hostnames := args[1:]
+	if len(hostnames) == 0 {
+		fmt.Fprintf(stderr, "usage: cfswitchctl hosts %s HOST...\n", args[0])
+		return errUsage
+	}
+
+	var updated []string
+	switch args[0] {
+	case "set":
+		updated = hostnames
+	case "add", "remove":
+		rule, err := c.client.GetRule(ctx)
+		if err != nil {
+			return err
+		}
+		updated = applyHostChange(rule.Hostnames, args[0], hostnames)
+		if len(updated) == 0 {
+			return errors.New("refusing to remove every hostname")
+		}
+	default:
+		fmt.Fprintf(stderr, "unknown hosts command %q\n", args[0])
+		return errUsage
+	}
+
+	rule, err := c.client.UpdateHosts(ctx, updated)
+	if err != nil {
+		return err
+	}
+	return printRule(c.stdout, c.output, rule)
+}
+
+// watch prints rule events until interrupted.
+func (c *cli) watch(ctx context.Context) error {
+	err := c.client.Watch(ctx, func(event types.RuleEvent) error {
+		return printEvent(c.stdout, c.output, event)
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// applyHostChange adds or removes hostnames from current.
+func applyHostChange(current []string, op string, hostnames []string) []string {
+	updated := slices.Clone(current)
+	for _, host := range types.ParseHostnames(strings.Join(hostnames, ",")) {
+		if op == "add" {
+			if !slices.Contains(updated, host) {
+				updated = append(updated, host)
+			}
+			continue
+		}
+		updated = slices.DeleteFunc(updated, func(existing string) bool { return existing == host })
+	}
+	return updated
+}
+
+// envOrDefault returns the environment variable value or a default.
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	rule := types.RuleResponse{RuleID: "rule-1", Hostnames: []string{"a.com", "b.com"}, Version: 1}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/rule/enable":
+			var req types.ToggleRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			rule.Enabled = req.Enabled
+		case "/v1/rule/hosts":
+			var req types.UpdateHostsRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			rule.Hostnames = req.Hostnames
+		}
+		json.NewEncoder(w).Encode(rule)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRun(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		contains string
+	}{
+		{
+			name:     "status table",
+			args:     []string{"status"},
+			contains: "rule-1",
+		},
+		{
+			name:     "enable json",
+			args:     []string{"-o", "json", "enable"},
+			contains: `"enabled": true`,
+		},
+		{
+			name:     "hosts add",
+			args:     []string{"hosts", "add", "C.com"},
+			contains: "a.com,b.com,c.com",
+		},
+		{
+			name:     "hosts remove",
+			args:     []string{"hosts", "remove", "a.com"},
+			contains: "b.com,c.com",
+		},
+		{
+			name:     "hosts list",
+			args:     []string{"hosts", "list"},
+			contains: "b.com\nc.com\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-server", server.URL, "-token", "t"}, tt.args...)
+
+			if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+				t.Fatalf("unexpected error: %v (stderr: %s)", err, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.contains) {
+				t.Errorf("expected output to contain %q, got %q", tt.contains, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	for _, args := range [][]string{{}, {"-o", "yaml", "status"}, {"-token", "t", "explode"}} {
+		var stdout, stderr bytes.Buffer
+		if err := run(context.Background(), args, &stdout, &stderr); err == nil {
+			t.Errorf("expected usage error for %v", args)
+		}
+	}
+}
+
+func TestApplyHostChange(t *testing.T) {
+	current := []string{"a.com", "b.com"}
+
+	added := applyHostChange(current, "add", []string{"B.com", "c.com"})
+	if !slices.Equal(added, []string{"a.com", "b.com", "c.com"}) {
+		t.Errorf("unexpected result after add: %v", added)
+	}
+
+	removed := applyHostChange(current, "remove", []string{"a.com"})
+	if !slices.Equal(removed, []string{"b.com"}) {
+		t.Errorf("unexpected result after remove: %v", removed)
+	}
+
+	if !slices.Equal(current, []string{"a.com", "b.com"}) {
+		t.Errorf("input slice was modified: %v", current)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// printRule writes a rule in the requested output format.
+func printRule(w io.Writer, output string, rule *types.RuleResponse) error {
+	if output == outputJSON {
+		return printJSON(w, rule)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:mnd // Column padding.
+	fmt.Fprintln(tw, "RULE ID\tBLOCKING\tVERSION\tHOSTNAMES")
+	fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", rule.RuleID, blockingState(rule.Enabled), rule.Version,
+		strings.Join(rule.Hostnames, ","))
+	return tw.Flush()
+}
+
+// printHosts writes the hostnames in the requested output format.
+func printHosts(w io.Writer, output string, hostnames []string) error {
+	if output == outputJSON {
+		return printJSON(w, hostnames)
+	}
+	for _, host := range hostnames {
+		fmt.Fprintln(w, host)
+	}
+	return nil
+}
+
+// printEvent writes a rule event as a single line.
+func printEvent(w io.Writer, output string, event types.RuleEvent) error {
+	if output == outputJSON {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	line := fmt.Sprintf("%s  %-22s", event.Timestamp.Local().Format(time.RFC3339), event.Type)
+	if event.Rule != nil {
+		line += fmt.Sprintf("  blocking=%s version=%d hosts=%s",
+			blockingState(event.Rule.Enabled), event.Rule.Version, strings.Join(event.Rule.Hostnames, ","))
+	}
+	if event.Actor != "" {
+		line += "  actor=" + event.Actor
+	}
+	if event.Error != "" {
+		line += "  error=" + event.Error
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// printJSON writes v as indented JSON.
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	return nil
+}
+
+// blockingState returns a human-readable rule state.
+func blockingState(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
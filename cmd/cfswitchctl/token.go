@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/meyeringh/cf-switch/internal/kube"
+)
+
+// resolveToken returns the API token from the flag, the environment, or the cf-switch-auth secret.
+func (c *cli) resolveToken(ctx context.Context) (string, error) {
+	if c.token != "" {
+		return c.token, nil
+	}
+	if token := os.Getenv("CF_SWITCH_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	token, err := c.tokenFromSecret(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no token given and secret lookup failed (set CF_SWITCH_TOKEN or --token): %w", err)
+	}
+	return token, nil
+}
+
+// tokenFromSecret reads the API token from the cf-switch-auth secret using the kubeconfig.
+func (c *cli) tokenFromSecret(ctx context.Context) (string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if c.kubeconfig != "" {
+		loadingRules.ExplicitPath = c.kubeconfig
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+
+	namespace := c.namespace
+	if namespace == "" {
+		contextNamespace, _, err := clientConfig.Namespace()
+		if err != nil || contextNamespace == "" {
+			contextNamespace = defaultNamespace
+		}
+		namespace = contextNamespace
+	}
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, kube.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s/%s: %w", namespace, kube.SecretName, err)
+	}
+
+	token := strings.TrimSpace(string(secret.Data[kube.TokenKey]))
+	if token == "" {
+		return "", errors.New("secret " + kube.SecretName + " has no " + kube.TokenKey)
+	}
+	return token, nil
+}
@@ -0,0 +1,88 @@
+// Command fake-cloudflare serves an in-memory emulation of the Cloudflare rulesets API for local development.
+// It also answers the zone and API token endpoints used by "cf-switch doctor" and the startup permission check.
+//
+// Point cf-switch at it with CLOUDFLARE_API_BASE_URL=http://localhost:8787/client/v4.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/cloudflare/cftest"
+)
+
+const (
+	// Default listen address.
+	defaultAddr = ":8787"
+	// Default burst size when rate limiting.
+	defaultRateBurst = 10
+	// Path prefix matching the real Cloudflare API base URL.
+	apiPrefix = "/client/v4"
+	// HTTP server timeouts.
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+func main() {
+	var (
+		addr    = flag.String("addr", defaultAddr, "Listen address")
+		token   = flag.String("token", "", "Required API token (default: accept any token)")
+		rps     = flag.Float64("rate-limit", 0, "Requests per second before answering 429 (0 disables)")
+		burst   = flag.Int("rate-burst", defaultRateBurst, "Requests allowed at once when rate limiting")
+		errRate = flag.Float64("error-rate", 0, "Fraction of requests answered with 500, e.g. 0.1")
+		latency = flag.Duration("latency", 0, "Delay added to every response")
+		debug   = flag.Bool("debug", false, "Log every request")
+	)
+	flag.Parse()
+
+	level := slog.LevelInfo
+	if *debug {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	fakeServer := cftest.NewServer(cftest.NewFake(), cftest.ServerConfig{
+		Token:     *token,
+		RateLimit: *rps,
+		RateBurst: *burst,
+		ErrorRate: *errRate,
+		Latency:   *latency,
+	}, logger)
+
+	mux := http.NewServeMux()
+	mux.Handle(apiPrefix+"/", http.StripPrefix(apiPrefix, fakeServer))
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to shut down", "error", err)
+		}
+	}()
+
+	logger.Info("Serving fake Cloudflare API",
+		"addr", *addr,
+		"base_path", apiPrefix,
+		"failures_path", apiPrefix+cftest.FailuresPath)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Server failed", "error", err)
+		stop()
+		os.Exit(1)
+	}
+}
@@ -67,6 +67,15 @@ Create the name of the service account to use
 {{/*
 Create environment variables from values
 */}}
+{{/*
+Leader election is needed whenever more than one replica may run
+*/}}
+{{- define "cf-switch.leaderElection" -}}
+{{- if or .Values.leaderElection.enabled (gt (int .Values.replicaCount) 1) .Values.autoscaling.enabled -}}
+true
+{{- end }}
+{{- end }}
+
 {{- define "cf-switch.env" -}}
 {{- range $key, $value := .Values.env }}
 - name: {{ $key }}
@@ -83,4 +92,16 @@ Create environment variables from values
       fieldPath: metadata.namespace
 - name: KUBERNETES_SERVICE_ACCOUNT
   value: {{ include "cf-switch.serviceAccountName" . }}
+{{- if include "cf-switch.leaderElection" . }}
+- name: LEADER_ELECTION
+  value: "true"
+- name: POD_NAME
+  valueFrom:
+    fieldRef:
+      fieldPath: metadata.name
+- name: POD_IP
+  valueFrom:
+    fieldRef:
+      fieldPath: status.podIP
+{{- end }}
 {{- end }}
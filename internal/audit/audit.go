@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// contextKey is the type for the audit actor context key.
+type contextKey struct{}
+
+// fieldsKey is the type for the audit fields context key.
+type fieldsKey struct{}
+
+// UnknownActor is reported when no actor is attached to a context.
+const UnknownActor = "unknown"
+
+// WithActor returns a context that attributes changes to actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, contextKey{}, actor)
+}
+
+// Actor returns the actor attached to ctx, or UnknownActor.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(contextKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return UnknownActor
+}
+
+// WithFields returns a context whose audit entries carry the given key-value pairs
+// in addition to any attached earlier.
+func WithFields(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, append(slices.Clip(Fields(ctx)), args...))
+}
+
+// Fields returns the key-value pairs attached to ctx with WithFields.
+func Fields(ctx context.Context) []any {
+	fields, _ := ctx.Value(fieldsKey{}).([]any)
+	return fields
+}
+
+// Record writes an audit log entry for action attributed to the actor in ctx.
+func Record(ctx context.Context, logger *slog.Logger, action string, args ...any) {
+	attrs := append([]any{"audit", true, "action", action, "actor", Actor(ctx)}, Fields(ctx)...)
+	logger.InfoContext(ctx, "Audit", append(attrs, args...)...)
+}
@@ -0,0 +1,45 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestActor(t *testing.T) {
+	ctx := context.Background()
+	if got := Actor(ctx); got != UnknownActor {
+		t.Errorf("expected %q, got %q", UnknownActor, got)
+	}
+
+	ctx = WithActor(ctx, "alertmanager:OriginOverload")
+	if got := Actor(ctx); got != "alertmanager:OriginOverload" {
+		t.Errorf("expected %q, got %q", "alertmanager:OriginOverload", got)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	ctx := WithFields(WithActor(context.Background(), "token:ops"), "alert", "OriginOverload")
+	Record(ctx, logger, "rule.toggle", "enabled", true)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode log entry: %v", err)
+	}
+
+	if entry["audit"] != true || entry["action"] != "rule.toggle" || entry["actor"] != "token:ops" {
+		t.Errorf("unexpected audit entry: %v", entry)
+	}
+	if entry["enabled"] != true {
+		t.Errorf("expected extra attributes to be kept, got %v", entry)
+	}
+	if entry["alert"] != "OriginOverload" {
+		t.Errorf("expected context fields to be recorded, got %v", entry)
+	}
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Scope is a permission granted to an identity.
+type Scope string
+
+// Scopes enforced by the API.
+const (
+	// ScopeRuleRead allows reading the rule and streaming events.
+	ScopeRuleRead Scope = "rule:read"
+	// ScopeRuleToggle allows enabling and disabling the rule and requesting a reconciliation.
+	ScopeRuleToggle Scope = "rule:toggle"
+	// ScopeRuleHosts allows changing the rule hostnames.
+	ScopeRuleHosts Scope = "rule:hosts"
+	// ScopeAdmin allows administrative operations such as token rotation.
+	ScopeAdmin Scope = "admin"
+)
+
+// Roles are shorthands for common scope sets.
+const (
+	RoleReadOnly = "readonly"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var (
+	// ErrNoCredentials indicates the request carries no credentials for an authenticator.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrMalformedCredentials indicates credentials that could not be parsed.
+	ErrMalformedCredentials = errors.New("malformed credentials")
+	// ErrInvalidCredentials indicates credentials that were rejected.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// AllScopes returns every scope known to the API.
+func AllScopes() []Scope {
+	return []Scope{ScopeRuleRead, ScopeRuleToggle, ScopeRuleHosts, ScopeAdmin}
+}
+
+// RoleScopes returns the scopes granted by a role, or false if the role is unknown.
+func RoleScopes(role string) ([]Scope, bool) {
+	switch role {
+	case RoleReadOnly:
+		return []Scope{ScopeRuleRead}, true
+	case RoleOperator:
+		return []Scope{ScopeRuleRead, ScopeRuleToggle}, true
+	case RoleAdmin:
+		return AllScopes(), true
+	default:
+		return nil, false
+	}
+}
+
+// ValidScope reports whether scope is known to the API.
+func ValidScope(scope Scope) bool {
+	return slices.Contains(AllScopes(), scope)
+}
+
+// Identity is an authenticated caller.
+type Identity struct {
+	// Name identifies the caller in logs and events, e.g. "token:grafana".
+	Name string
+	// Scopes lists the permissions granted to the caller.
+	Scopes []Scope
+	// Authorize, if set, decides scopes that are not listed in Scopes when a request needs them.
+	Authorize func(ctx context.Context, scope Scope) (bool, error)
+}
+
+// HasScope reports whether the identity was granted scope.
+func (i *Identity) HasScope(scope Scope) bool {
+	return slices.Contains(i.Scopes, scope)
+}
+
+// Authorized reports whether the identity holds scope, asking Authorize if Scopes does not list it.
+func (i *Identity) Authorized(ctx context.Context, scope Scope) (bool, error) {
+	if i.HasScope(scope) || i.Authorize == nil {
+		return i.HasScope(scope), nil
+	}
+	return i.Authorize(ctx, scope)
+}
+
+// Authenticator identifies the caller of an HTTP request.
+type Authenticator interface {
+	// Authenticate returns the caller identity. It returns an error wrapping ErrNoCredentials
+	// if the request carries no credentials it understands.
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// contextKey is the type for auth context keys.
+type contextKey struct{}
+
+// WithIdentity returns a context carrying identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity attached to ctx, if any.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
+
+// BearerToken extracts the bearer token from the Authorization header.
+func BearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", ErrNoCredentials
+	}
+
+	const bearerPrefix = "Bearer "
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return "", ErrMalformedCredentials
+	}
+	return strings.TrimPrefix(header, bearerPrefix), nil
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/x509"
+	"net/http"
+	"slices"
+)
+
+// certActorPrefix prefixes certificate subjects in identities.
+const certActorPrefix = "cert:"
+
+// CertificateAuthenticator authenticates verified TLS client certificates.
+// Scopes are granted by matching the certificate subject against "cn:<common name>",
+// "ou:<organizational unit>", and "o:<organization>" keys. Certificates that match no key
+// are ignored, so the request can still authenticate with other credentials.
+type CertificateAuthenticator struct {
+	subjectScopes map[string][]Scope
+}
+
+// NewCertificateAuthenticator creates an authenticator mapping certificate subjects to scopes.
+// The map has the same format as the one returned by ParseGroupScopes.
+func NewCertificateAuthenticator(subjectScopes map[string][]Scope) *CertificateAuthenticator {
+	return &CertificateAuthenticator{subjectScopes: subjectScopes}
+}
+
+// Authenticate implements Authenticator.
+func (a *CertificateAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+
+	var scopes []Scope
+	for _, key := range subjectKeys(cert) {
+		for _, scope := range a.subjectScopes[key] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	name := cert.Subject.CommonName
+	if name == "" {
+		name = cert.Subject.String()
+	}
+	return &Identity{Name: certActorPrefix + name, Scopes: scopes}, nil
+}
+
+// subjectKeys returns the mapping keys for a certificate subject.
+func subjectKeys(cert *x509.Certificate) []string {
+	var keys []string
+	if cert.Subject.CommonName != "" {
+		keys = append(keys, "cn:"+cert.Subject.CommonName)
+	}
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		keys = append(keys, "ou:"+ou)
+	}
+	for _, o := range cert.Subject.Organization {
+		keys = append(keys, "o:"+o)
+	}
+	return keys
+}
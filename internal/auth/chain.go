@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ChainAuthenticator tries several authenticators in order and accepts the first success.
+type ChainAuthenticator struct {
+	authenticators []Authenticator
+}
+
+// NewChainAuthenticator creates an authenticator that tries authenticators in order.
+func NewChainAuthenticator(authenticators ...Authenticator) *ChainAuthenticator {
+	return &ChainAuthenticator{authenticators: authenticators}
+}
+
+// Authenticate implements Authenticator.
+// If every authenticator fails, the most specific error is returned: rejected credentials
+// take precedence over malformed ones, which take precedence over missing ones.
+func (c *ChainAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	var best error
+	for _, authenticator := range c.authenticators {
+		identity, err := authenticator.Authenticate(r)
+		if err == nil {
+			return identity, nil
+		}
+		if best == nil || errorRank(err) > errorRank(best) {
+			best = err
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoCredentials
+	}
+	return nil, best
+}
+
+// errorRank orders authentication errors by how much they reveal about the request.
+func errorRank(err error) int {
+	switch {
+	case errors.Is(err, ErrNoCredentials):
+		return 0
+	case errors.Is(err, ErrMalformedCredentials):
+		return 1
+	default:
+		return 2 //nolint:mnd // Rejected and unexpected errors rank highest.
+	}
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	// How long fetched signing keys are used before they are refreshed.
+	jwksCacheTTL = time.Hour
+	// Minimum time between refreshes triggered by unknown key IDs.
+	jwksMinRefreshInterval = time.Minute
+	// HTTP timeout for JWKS and discovery requests.
+	jwksFetchTimeout = 10 * time.Second
+	// Maximum accepted size of a JWKS or discovery document.
+	jwksMaxBytes = 1 << 20
+)
+
+// errUnknownKey indicates a token signed with a key that is not in the key set.
+var errUnknownKey = errors.New("unknown signing key")
+
+// keySet fetches and caches the signing keys published at a JWKS URL.
+// Keys are refreshed after jwksCacheTTL, or earlier when a token references an unknown key ID,
+// so key rotation at the identity provider is picked up without a restart.
+// Only one fetch runs at a time, and cached keys stay usable while it does.
+type keySet struct {
+	url        string
+	httpClient *http.Client
+	logger     *slog.Logger
+
+	mu          sync.Mutex
+	keys        map[string]jose.JSONWebKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	refreshing  chan struct{} // Closed when the fetch in flight finishes.
+	refreshErr  error
+}
+
+// newKeySet creates a key set for url. Keys are fetched on first use.
+func newKeySet(url string, httpClient *http.Client, logger *slog.Logger) *keySet {
+	return &keySet{
+		url:        url,
+		httpClient: httpClient,
+		logger:     logger,
+	}
+}
+
+// key returns the public key with the given ID, refreshing the key set if needed.
+func (s *keySet) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	s.mu.Lock()
+	key, found := s.keys[kid]
+	expired := time.Since(s.fetchedAt) > jwksCacheTTL
+	canRefresh := s.refreshing != nil || time.Since(s.lastAttempt) >= jwksMinRefreshInterval
+	s.mu.Unlock()
+
+	if found {
+		if expired && canRefresh {
+			// Keep serving the cached key while the key set refreshes. Failures are logged by refresh.
+			go func() { _ = s.refresh(context.WithoutCancel(ctx)) }()
+		}
+		return &key, nil
+	}
+
+	// An unknown key ID usually means the provider rotated its keys.
+	if !canRefresh {
+		return nil, fmt.Errorf("%w %q", errUnknownKey, kid)
+	}
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, found = s.keys[kid]; found {
+		return &key, nil
+	}
+	return nil, fmt.Errorf("%w %q", errUnknownKey, kid)
+}
+
+// refresh fetches the key set, or waits for the fetch already in flight.
+func (s *keySet) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	if done := s.refreshing; done != nil {
+		s.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for JWKS: %w", ctx.Err())
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.refreshErr
+	}
+	done := make(chan struct{})
+	s.refreshing = done
+	s.lastAttempt = time.Now()
+	s.mu.Unlock()
+
+	// The fetch is shared with other requests, so it must outlive the one that started it.
+	keys, err := s.fetch(context.WithoutCancel(ctx))
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+		s.fetchedAt = time.Now()
+	}
+	s.refreshErr = err
+	s.refreshing = nil
+	s.mu.Unlock()
+	close(done)
+
+	return err
+}
+
+// fetch downloads the key set and returns its signing keys by key ID.
+func (s *keySet) fetch(ctx context.Context) (map[string]jose.JSONWebKey, error) {
+	var doc struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := fetchJSON(ctx, s.httpClient, s.url, &doc); err != nil {
+		s.logger.WarnContext(ctx, "Failed to fetch JWKS", "url", s.url, "error", err)
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]jose.JSONWebKey, len(doc.Keys))
+	for _, raw := range doc.Keys {
+		var key jose.JSONWebKey
+		if err := key.UnmarshalJSON(raw); err != nil {
+			s.logger.WarnContext(ctx, "Skipping unsupported JWK", "error", err)
+			continue
+		}
+		if (key.Use != "" && key.Use != "sig") || !key.IsPublic() {
+			continue
+		}
+		keys[key.KeyID] = key
+	}
+
+	s.logger.DebugContext(ctx, "Fetched JWKS", "url", s.url, "keys", len(keys))
+	return keys, nil
+}
+
+// discoverJWKSURL reads the jwks_uri from the issuer's OpenID configuration.
+func discoverJWKSURL(ctx context.Context, httpClient *http.Client, issuer string) (string, error) {
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := fetchJSON(ctx, httpClient, issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return "", fmt.Errorf("failed to fetch OpenID configuration: %w", err)
+	}
+	if doc.Issuer != issuer {
+		return "", fmt.Errorf("OpenID configuration issuer %q does not match %q", doc.Issuer, issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", errors.New("OpenID configuration has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+// fetchJSON GETs url and decodes the JSON response into out.
+func fetchJSON(ctx context.Context, httpClient *http.Client, url string, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err = json.NewDecoder(io.LimitReader(resp.Body, jwksMaxBytes)).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
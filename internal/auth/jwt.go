@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	// Default claim holding the caller's name.
+	defaultUsernameClaim = "sub"
+	// Default claim holding the caller's groups.
+	defaultGroupsClaim = "groups"
+	// Tolerated clock difference when checking exp and nbf.
+	jwtClockSkew = time.Minute
+	// Prefix for identities authenticated by JWT.
+	jwtActorPrefix = "oidc:"
+)
+
+// JWTConfig configures JWT bearer authentication.
+type JWTConfig struct {
+	// Issuer is the required "iss" claim. If JWKSURL is empty, keys are discovered from the issuer.
+	Issuer string
+	// Audience must be contained in the "aud" claim.
+	Audience string
+	// JWKSURL is the URL of the issuer's signing keys.
+	JWKSURL string
+	// UsernameClaim names the claim identifying the caller (default "sub").
+	UsernameClaim string
+	// GroupsClaim names the claim listing the caller's groups (default "groups").
+	GroupsClaim string
+	// GroupScopes maps group names to the scopes granted to their members.
+	GroupScopes map[string][]Scope
+}
+
+// JWTAuthenticator authenticates JWT bearer tokens signed by an OIDC provider.
+type JWTAuthenticator struct {
+	config JWTConfig
+	keys   *keySet
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewJWTAuthenticator creates a JWT authenticator, discovering the JWKS URL from the issuer if needed.
+func NewJWTAuthenticator(ctx context.Context, config JWTConfig, logger *slog.Logger) (*JWTAuthenticator, error) {
+	if config.Issuer == "" {
+		return nil, errors.New("JWT issuer is required")
+	}
+	if config.Audience == "" {
+		return nil, errors.New("JWT audience is required")
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = defaultUsernameClaim
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = defaultGroupsClaim
+	}
+
+	httpClient := &http.Client{Timeout: jwksFetchTimeout}
+	if config.JWKSURL == "" {
+		jwksURL, err := discoverJWKSURL(ctx, httpClient, config.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		config.JWKSURL = jwksURL
+	}
+
+	return &JWTAuthenticator{
+		config: config,
+		keys:   newKeySet(config.JWKSURL, httpClient, logger),
+		logger: logger,
+		now:    time.Now,
+	}, nil
+}
+
+// ParseGroupScopes parses a comma-separated list of group=role or group=scope mappings.
+// A group may appear several times to combine roles and scopes.
+func ParseGroupScopes(value string) (map[string][]Scope, error) {
+	result := make(map[string][]Scope)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		group, grant, ok := strings.Cut(item, "=")
+		group, grant = strings.TrimSpace(group), strings.TrimSpace(grant)
+		if !ok || group == "" || grant == "" {
+			return nil, fmt.Errorf("invalid group mapping %q, expected group=role or group=scope", item)
+		}
+
+		scopes, isRole := RoleScopes(grant)
+		if !isRole {
+			if !ValidScope(Scope(grant)) {
+				return nil, fmt.Errorf("group %s: unknown role or scope %q", group, grant)
+			}
+			scopes = []Scope{Scope(grant)}
+		}
+
+		for _, scope := range scopes {
+			if !slices.Contains(result[group], scope) {
+				result[group] = append(result[group], scope)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Authenticate implements Authenticator.
+// Bearer tokens that are not shaped like a JWT are reported as ErrNoCredentials so that
+// other authenticators in a chain can handle them.
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token, err := BearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Count(token, ".") != 2 { //nolint:mnd // A JWS has three dot-separated parts.
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.verify(r.Context(), token)
+	if err != nil {
+		a.logger.DebugContext(r.Context(), "Rejected JWT", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	username, _ := claims[a.config.UsernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidCredentials, a.config.UsernameClaim)
+	}
+
+	var scopes []Scope
+	for _, group := range stringList(claims[a.config.GroupsClaim]) {
+		for _, scope := range a.config.GroupScopes[group] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return &Identity{Name: jwtActorPrefix + username, Scopes: scopes}, nil
+}
+
+// verify checks the signature and standard claims of a compact JWS and returns its claims.
+func (a *JWTAuthenticator) verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parsed, err := jwt.ParseSigned(token, signatureAlgorithms())
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	header := parsed.Headers[0]
+
+	key, err := a.keys.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkKey(key, header.Algorithm); err != nil {
+		return nil, err
+	}
+
+	var (
+		standard jwt.Claims
+		claims   map[string]interface{}
+	)
+	if err = parsed.Claims(key.Key, &standard, &claims); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	if standard.Expiry == nil {
+		return nil, errors.New("missing exp claim")
+	}
+	expected := jwt.Expected{
+		Issuer:      a.config.Issuer,
+		AnyAudience: jwt.Audience{a.config.Audience},
+		Time:        a.now(),
+	}
+	if err = standard.ValidateWithLeeway(expected, jwtClockSkew); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	return claims, nil
+}
+
+// signatureAlgorithms returns the JWS algorithms accepted for tokens.
+func signatureAlgorithms() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{jose.RS256, jose.RS384, jose.RS512, jose.ES256, jose.ES384, jose.ES512}
+}
+
+// checkKey verifies that key may check signatures made with alg: the key must not be limited to
+// another algorithm, and ECDSA keys must use the curve the algorithm is defined for.
+func checkKey(key *jose.JSONWebKey, alg string) error {
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return fmt.Errorf("key %q is for algorithm %s, not %s", key.KeyID, key.Algorithm, alg)
+	}
+
+	switch k := key.Key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %s does not match RSA key %q", alg, key.KeyID)
+		}
+	case *ecdsa.PublicKey:
+		curves := map[string]elliptic.Curve{
+			string(jose.ES256): elliptic.P256(),
+			string(jose.ES384): elliptic.P384(),
+			string(jose.ES512): elliptic.P521(),
+		}
+		if curves[alg] != k.Curve {
+			return fmt.Errorf("algorithm %s does not match curve %s of key %q", alg, k.Params().Name, key.KeyID)
+		}
+	default:
+		return fmt.Errorf("unsupported type %T of key %q", key.Key, key.KeyID)
+	}
+	return nil
+}
+
+// stringList reads a claim that may be a single string or a list of strings.
+func stringList(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
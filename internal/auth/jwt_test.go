@@ -0,0 +1,378 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "cf-switch"
+)
+
+// testIdP serves a JWKS document and signs tokens with its current keys.
+type testIdP struct {
+	mu     sync.Mutex
+	rsaKey *rsa.PrivateKey
+	rsaKid string
+	ecKey  *ecdsa.PrivateKey
+	server *httptest.Server
+	hits   int
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	idp := &testIdP{}
+	idp.rotate(t, "rsa-1")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	idp.ecKey = ecKey
+
+	idp.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.hits++
+
+		ecPoint, _ := idp.ecKey.PublicKey.Bytes()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": idp.rsaKid, "use": "sig",
+				"n": b64(idp.rsaKey.N.Bytes()),
+				"e": b64(big.NewInt(int64(idp.rsaKey.E)).Bytes()),
+			},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecPoint[1:33]), "y": b64(ecPoint[33:])},
+		}})
+	}))
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// rotate replaces the RSA signing key.
+func (idp *testIdP) rotate(t *testing.T, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	idp.mu.Lock()
+	idp.rsaKey, idp.rsaKid = key, kid
+	idp.mu.Unlock()
+}
+
+// sign creates a compact JWS with the given algorithm ("RS256" or "ES256").
+func (idp *testIdP) sign(t *testing.T, alg string, claims map[string]interface{}) string {
+	t.Helper()
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	kid := idp.rsaKid
+	if alg == "ES256" {
+		kid = "ec-1"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	if alg == "ES256" {
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, idp.ecKey, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, idp.rsaKey, crypto.SHA256, digest[:])
+	}
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed + "." + b64(signature)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":    testIssuer,
+		"aud":    []string{testAudience, "other"},
+		"sub":    "alice",
+		"groups": []string{"sre", "everyone"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func newTestJWTAuthenticator(t *testing.T, idp *testIdP) *JWTAuthenticator {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	a, err := NewJWTAuthenticator(context.Background(), JWTConfig{
+		Issuer:   testIssuer,
+		Audience: testAudience,
+		JWKSURL:  idp.server.URL,
+		GroupScopes: map[string][]Scope{
+			"sre":      {ScopeRuleRead, ScopeRuleToggle},
+			"everyone": {ScopeRuleRead},
+		},
+	}, logger)
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	return a
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/v1/rule", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWTAuthenticator_Authenticate(t *testing.T) {
+	idp := newTestIdP(t)
+	a := newTestJWTAuthenticator(t, idp)
+
+	tests := []struct {
+		name    string
+		alg     string
+		mutate  func(claims map[string]interface{})
+		wantErr bool
+	}{
+		{"valid RS256", "RS256", func(map[string]interface{}) {}, false},
+		{"valid ES256", "ES256", func(map[string]interface{}) {}, false},
+		{"single audience string", "RS256", func(c map[string]interface{}) { c["aud"] = testAudience }, false},
+		{"wrong issuer", "RS256", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, true},
+		{"wrong audience", "RS256", func(c map[string]interface{}) { c["aud"] = "other" }, true},
+		{"expired", "RS256", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, true},
+		{"missing exp", "RS256", func(c map[string]interface{}) { delete(c, "exp") }, true},
+		{"not yet valid", "RS256", func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() }, true},
+		{"missing subject", "RS256", func(c map[string]interface{}) { delete(c, "sub") }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.mutate(claims)
+
+			identity, err := a.Authenticate(bearerRequest(idp.sign(t, tt.alg, claims)))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Errorf("expected ErrInvalidCredentials, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if identity.Name != "oidc:alice" {
+				t.Errorf("expected identity oidc:alice, got %s", identity.Name)
+			}
+			if !slices.Equal(identity.Scopes, []Scope{ScopeRuleRead, ScopeRuleToggle}) {
+				t.Errorf("unexpected scopes %v", identity.Scopes)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticator_RejectsTampering(t *testing.T) {
+	idp := newTestIdP(t)
+	a := newTestJWTAuthenticator(t, idp)
+
+	token := idp.sign(t, "RS256", validClaims())
+	claims := validClaims()
+	claims["groups"] = []string{"admins"}
+	payload, _ := json.Marshal(claims)
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + b64(payload) + "." + parts[2]
+
+	if _, err := a.Authenticate(bearerRequest(tampered)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected tampered token to be rejected, got %v", err)
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "none", "kid": "rsa-1"})
+	unsigned := b64(header) + "." + parts[1] + "."
+	if _, err := a.Authenticate(bearerRequest(unsigned)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected unsigned token to be rejected, got %v", err)
+	}
+
+	if _, err := a.Authenticate(bearerRequest("opaque-api-token")); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected non-JWT bearer token to be left to other authenticators, got %v", err)
+	}
+}
+
+func TestJWTAuthenticator_KeyRotation(t *testing.T) {
+	idp := newTestIdP(t)
+	a := newTestJWTAuthenticator(t, idp)
+
+	if _, err := a.Authenticate(bearerRequest(idp.sign(t, "RS256", validClaims()))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Cached keys are reused.
+	if _, err := a.Authenticate(bearerRequest(idp.sign(t, "RS256", validClaims()))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if idp.hits != 1 {
+		t.Errorf("expected 1 JWKS fetch, got %d", idp.hits)
+	}
+
+	// A token signed with a new key triggers a refresh.
+	idp.rotate(t, "rsa-2")
+	a.keys.lastAttempt = time.Time{}
+	if _, err := a.Authenticate(bearerRequest(idp.sign(t, "RS256", validClaims()))); err != nil {
+		t.Fatalf("expected rotated key to be accepted, got %v", err)
+	}
+	if idp.hits != 2 {
+		t.Errorf("expected 2 JWKS fetches, got %d", idp.hits)
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name    string
+		key     jose.JSONWebKey
+		alg     string
+		wantErr bool
+	}{
+		{"ES256 on P-256", jose.JSONWebKey{Key: &p256.PublicKey}, "ES256", false},
+		{"ES384 on P-384", jose.JSONWebKey{Key: &p384.PublicKey}, "ES384", false},
+		{"ES256 on P-384", jose.JSONWebKey{Key: &p384.PublicKey}, "ES256", true},
+		{"RS256 on EC key", jose.JSONWebKey{Key: &p256.PublicKey}, "RS256", true},
+		{"RS256 on RSA key", jose.JSONWebKey{Key: &rsaKey.PublicKey, Algorithm: "RS256"}, "RS256", false},
+		{"key limited to RS512", jose.JSONWebKey{Key: &rsaKey.PublicKey, Algorithm: "RS512"}, "RS256", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkKey(&tt.key, tt.alg); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeySet_RefreshDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Write([]byte(`{"keys": []}`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	keys := newKeySet(server.URL, server.Client(), logger)
+
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keys.keys = map[string]jose.JSONWebKey{"cached": {Key: &p256.PublicKey, KeyID: "cached"}}
+	keys.fetchedAt = time.Now().Add(-2 * jwksCacheTTL)
+
+	// Unknown key IDs wait for a single shared fetch.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := keys.key(context.Background(), "unknown"); !errors.Is(err, errUnknownKey) {
+				t.Errorf("expected errUnknownKey, got %v", err)
+			}
+		}()
+	}
+
+	// Cached keys are served while the fetch is in flight.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := keys.key(context.Background(), "cached"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cached key lookup blocked on the JWKS fetch")
+	}
+
+	close(release)
+	wg.Wait()
+
+	if hits.Load() != 1 {
+		t.Errorf("expected 1 JWKS fetch, got %d", hits.Load())
+	}
+}
+
+func TestParseGroupScopes(t *testing.T) {
+	scopes, err := ParseGroupScopes("sre=operator, deployers=rule:hosts, deployers=readonly")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(scopes["sre"], []Scope{ScopeRuleRead, ScopeRuleToggle}) {
+		t.Errorf("unexpected sre scopes %v", scopes["sre"])
+	}
+	if !slices.Equal(scopes["deployers"], []Scope{ScopeRuleHosts, ScopeRuleRead}) {
+		t.Errorf("unexpected deployers scopes %v", scopes["deployers"])
+	}
+
+	for _, invalid := range []string{"sre", "sre=root", "=admin"} {
+		if _, err = ParseGroupScopes(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestChainAuthenticator(t *testing.T) {
+	idp := newTestIdP(t)
+	jwtAuth := newTestJWTAuthenticator(t, idp)
+	tokenAuth, err := NewTokenAuthenticator([]Token{{Name: "ci", Token: "static-token", Role: RoleAdmin}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chain := NewChainAuthenticator(jwtAuth, tokenAuth)
+
+	identity, err := chain.Authenticate(bearerRequest(idp.sign(t, "RS256", validClaims())))
+	if err != nil || identity.Name != "oidc:alice" {
+		t.Errorf("expected JWT identity, got %v, %v", identity, err)
+	}
+
+	identity, err = chain.Authenticate(bearerRequest("static-token"))
+	if err != nil || identity.Name != "token:ci" {
+		t.Errorf("expected static token fallback, got %v, %v", identity, err)
+	}
+
+	if _, err = chain.Authenticate(bearerRequest("wrong")); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	if _, err = chain.Authenticate(httptest.NewRequest(http.MethodGet, "/v1/rule", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tokenActorPrefix prefixes token names in identities.
+const tokenActorPrefix = "token:"
+
+// Token is a named API token and the scopes it grants.
+type Token struct {
+	Name   string  `json:"name"`
+	Token  string  `json:"token"`
+	Role   string  `json:"role,omitempty"`
+	Scopes []Scope `json:"scopes,omitempty"`
+}
+
+// TokensFile is the format of the tokens config file and the tokens secret key.
+type TokensFile struct {
+	Tokens []Token `json:"tokens"`
+}
+
+// LoadTokensFile reads named tokens from a JSON file.
+func LoadTokensFile(path string) ([]Token, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- Path comes from operator configuration.
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+	return ParseTokens(data)
+}
+
+// ParseTokens parses named tokens from JSON.
+func ParseTokens(data []byte) ([]Token, error) {
+	var file TokensFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tokens: %w", err)
+	}
+	return file.Tokens, nil
+}
+
+// TokenAuthenticator authenticates bearer tokens against a set of named tokens.
+// The set can be replaced at runtime; replaced token values stay valid for a grace period.
+type TokenAuthenticator struct {
+	// mu serializes updates; reads use the atomic pointer.
+	mu    sync.Mutex
+	state atomic.Pointer[tokenSet]
+	now   func() time.Time
+}
+
+// tokenSet is an immutable snapshot of accepted tokens.
+type tokenSet struct {
+	// configs are the currently configured tokens.
+	configs []Token
+	// tokens holds the configured tokens followed by retired ones.
+	tokens []storedToken
+}
+
+// storedToken is a token digest with the identity it authenticates.
+type storedToken struct {
+	digest   [sha256.Size]byte
+	identity *Identity
+	// expires is set for retired tokens that are only valid during a grace period.
+	expires time.Time
+}
+
+// NewTokenAuthenticator validates tokens and creates an authenticator for them.
+// Each token's role is expanded into scopes; names and token values must be unique.
+func NewTokenAuthenticator(tokens []Token) (*TokenAuthenticator, error) {
+	stored, err := buildTokens(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &TokenAuthenticator{now: time.Now}
+	a.state.Store(&tokenSet{configs: slices.Clone(tokens), tokens: stored})
+	return a, nil
+}
+
+// Update atomically replaces the accepted tokens. Token values that are no longer configured
+// remain valid for grace; a zero grace revokes them immediately.
+func (a *TokenAuthenticator) Update(tokens []Token, grace time.Duration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.update(tokens, grace)
+}
+
+// Rotate replaces the value of the named token, keeping the old value valid for grace.
+func (a *TokenAuthenticator) Rotate(name, token string, grace time.Duration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	configs := slices.Clone(a.state.Load().configs)
+	index := slices.IndexFunc(configs, func(t Token) bool { return t.Name == name })
+	if index < 0 {
+		return fmt.Errorf("unknown token %q", name)
+	}
+	configs[index].Token = token
+	return a.update(configs, grace)
+}
+
+// update replaces the accepted tokens. a.mu must be held.
+func (a *TokenAuthenticator) update(tokens []Token, grace time.Duration) error {
+	stored, err := buildTokens(tokens)
+	if err != nil {
+		return err
+	}
+
+	now := a.now()
+	retireAt := now.Add(grace)
+	for _, old := range a.state.Load().tokens {
+		if old.expired(now) || grace <= 0 || containsDigest(stored, old.digest) {
+			continue
+		}
+		if old.expires.IsZero() || old.expires.After(retireAt) {
+			old.expires = retireAt
+		}
+		stored = append(stored, old)
+	}
+
+	a.state.Store(&tokenSet{configs: slices.Clone(tokens), tokens: stored})
+	return nil
+}
+
+// Authenticate implements Authenticator.
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token, err := BearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compare against every token so timing does not reveal which one matched.
+	now := a.now()
+	digest := sha256.Sum256([]byte(token))
+	var match *Identity
+	for _, stored := range a.state.Load().tokens {
+		if subtle.ConstantTimeCompare(digest[:], stored.digest[:]) == 1 && !stored.expired(now) {
+			match = stored.identity
+		}
+	}
+
+	if match == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return match, nil
+}
+
+// buildTokens validates tokens and computes their digests.
+func buildTokens(tokens []Token) ([]storedToken, error) {
+	stored := make([]storedToken, 0, len(tokens))
+	names := make(map[string]bool, len(tokens))
+	digests := make(map[[sha256.Size]byte]bool, len(tokens))
+
+	for _, token := range tokens {
+		if token.Name == "" {
+			return nil, errors.New("token name is required")
+		}
+		if names[token.Name] {
+			return nil, fmt.Errorf("duplicate token name %q", token.Name)
+		}
+		if token.Token == "" {
+			return nil, fmt.Errorf("token %s: token value is required", token.Name)
+		}
+
+		scopes, err := token.resolveScopes()
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %w", token.Name, err)
+		}
+
+		digest := sha256.Sum256([]byte(token.Token))
+		if digests[digest] {
+			return nil, fmt.Errorf("token %s: token value is already used by another token", token.Name)
+		}
+
+		names[token.Name] = true
+		digests[digest] = true
+		stored = append(stored, storedToken{
+			digest:   digest,
+			identity: &Identity{Name: tokenActorPrefix + token.Name, Scopes: scopes},
+		})
+	}
+
+	return stored, nil
+}
+
+// containsDigest reports whether tokens contains a token with digest.
+func containsDigest(tokens []storedToken, digest [sha256.Size]byte) bool {
+	return slices.ContainsFunc(tokens, func(t storedToken) bool { return t.digest == digest })
+}
+
+// expired reports whether a retired token's grace period has ended.
+func (t storedToken) expired(now time.Time) bool {
+	return !t.expires.IsZero() && !now.Before(t.expires)
+}
+
+// resolveScopes combines the role and explicit scopes of a token.
+func (t Token) resolveScopes() ([]Scope, error) {
+	var scopes []Scope
+	if t.Role != "" {
+		roleScopes, ok := RoleScopes(t.Role)
+		if !ok {
+			return nil, fmt.Errorf("unknown role %q", t.Role)
+		}
+		scopes = append(scopes, roleScopes...)
+	}
+
+	for _, scope := range t.Scopes {
+		if !ValidScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return nil, errors.New("role or scopes is required")
+	}
+	return scopes, nil
+}
@@ -0,0 +1,168 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTokens(t *testing.T) {
+	tokens, err := ParseTokens([]byte(`{"tokens":[
+		{"name":"grafana","token":"abc","role":"readonly"},
+		{"name":"deploy","token":"def","scopes":["rule:hosts"]}
+	]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].Role != RoleReadOnly || tokens[1].Scopes[0] != ScopeRuleHosts {
+		t.Errorf("unexpected tokens: %+v", tokens)
+	}
+
+	if _, err = ParseTokens([]byte(`not json`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestNewTokenAuthenticator_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  []Token
+		wantErr bool
+	}{
+		{"valid", []Token{{Name: "a", Token: "1", Role: RoleOperator}}, false},
+		{"missing name", []Token{{Token: "1", Role: RoleAdmin}}, true},
+		{"missing token", []Token{{Name: "a", Role: RoleAdmin}}, true},
+		{"no scopes", []Token{{Name: "a", Token: "1"}}, true},
+		{"unknown role", []Token{{Name: "a", Token: "1", Role: "root"}}, true},
+		{"unknown scope", []Token{{Name: "a", Token: "1", Scopes: []Scope{"rule:delete"}}}, true},
+		{"duplicate name", []Token{
+			{Name: "a", Token: "1", Role: RoleAdmin},
+			{Name: "a", Token: "2", Role: RoleAdmin},
+		}, true},
+		{"duplicate token", []Token{
+			{Name: "a", Token: "1", Role: RoleAdmin},
+			{Name: "b", Token: "1", Role: RoleReadOnly},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTokenAuthenticator(tt.tokens)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTokenAuthenticator_Authenticate(t *testing.T) {
+	authenticator, err := NewTokenAuthenticator([]Token{
+		{Name: "oncall", Token: "operator-token", Role: RoleOperator, Scopes: []Scope{ScopeRuleRead}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr error
+	}{
+		{"valid token", "Bearer operator-token", nil},
+		{"missing header", "", ErrNoCredentials},
+		{"basic auth", "Basic dGVzdA==", ErrMalformedCredentials},
+		{"unknown token", "Bearer other", ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/rule", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			identity, authErr := authenticator.Authenticate(req)
+			if !errors.Is(authErr, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, authErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if identity.Name != "token:oncall" {
+				t.Errorf("expected identity token:oncall, got %s", identity.Name)
+			}
+			if !slices.Equal(identity.Scopes, []Scope{ScopeRuleRead, ScopeRuleToggle}) {
+				t.Errorf("expected deduplicated operator scopes, got %v", identity.Scopes)
+			}
+			if identity.HasScope(ScopeRuleHosts) {
+				t.Error("operator must not have rule:hosts")
+			}
+		})
+	}
+}
+
+func TestTokenAuthenticator_GracePeriod(t *testing.T) {
+	authenticator, err := NewTokenAuthenticator([]Token{
+		{Name: "api-token", Token: "old", Role: RoleAdmin},
+		{Name: "grafana", Token: "viewer", Role: RoleReadOnly},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now()
+	authenticator.now = func() time.Time { return now }
+
+	accepts := func(token string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/v1/rule", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		_, authErr := authenticator.Authenticate(req)
+		return authErr == nil
+	}
+
+	if err = authenticator.Rotate("api-token", "new", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !accepts("old") || !accepts("new") || !accepts("viewer") {
+		t.Fatal("expected old, new, and unchanged tokens to be accepted during the grace period")
+	}
+
+	// Re-applying the same tokens, e.g. from the secret watch, must not extend the grace period.
+	now = now.Add(30 * time.Second)
+	if err = authenticator.Update([]Token{
+		{Name: "api-token", Token: "new", Role: RoleAdmin},
+		{Name: "grafana", Token: "viewer", Role: RoleReadOnly},
+	}, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now = now.Add(31 * time.Second)
+	if accepts("old") {
+		t.Error("expected old token to be rejected after the grace period")
+	}
+	if !accepts("new") {
+		t.Error("expected new token to be accepted")
+	}
+
+	// Without a grace period, removed tokens are revoked immediately.
+	if err = authenticator.Update([]Token{{Name: "api-token", Token: "new", Role: RoleAdmin}}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if accepts("viewer") {
+		t.Error("expected removed token to be rejected without a grace period")
+	}
+
+	if err = authenticator.Rotate("unknown", "x", time.Minute); err == nil {
+		t.Error("expected error rotating an unknown token")
+	}
+	if err = authenticator.Update([]Token{{Name: "api-token", Role: RoleAdmin}}, time.Minute); err == nil {
+		t.Error("expected error for invalid tokens")
+	}
+	if !accepts("new") {
+		t.Error("expected a failed update to keep the current tokens")
+	}
+}
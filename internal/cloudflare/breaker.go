@@ -0,0 +1,174 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the client's circuit breaker.
+type CircuitState string
+
+// Circuit breaker states.
+const (
+	// CircuitClosed lets requests through.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen fails requests fast with ErrCircuitOpen.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single probe request through to test whether the API recovered.
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// ErrCircuitOpen is matched by CircuitOpenError via errors.Is.
+var ErrCircuitOpen = errors.New("cloudflare API circuit breaker is open")
+
+// CircuitOpenError is returned without contacting Cloudflare while the circuit breaker is open.
+type CircuitOpenError struct {
+	// RetryAfter is how long until the breaker lets a probe request through,
+	// or zero while a probe request is in flight.
+	RetryAfter time.Duration
+
+	reason string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return ErrCircuitOpen.Error() + " " + e.reason
+}
+
+// Unwrap makes errors.Is(err, ErrCircuitOpen) match.
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitBreakerConfig configures the client's circuit breaker.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed requests that opens the circuit.
+	// Requests fail after exhausting their retries on network errors, 429, or 5xx responses.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a probe request is let through.
+	OpenTimeout time.Duration
+	// OnStateChange is called on every transition, e.g. to record metrics. It must not call the client.
+	OnStateChange func(state CircuitState)
+}
+
+// WithCircuitBreaker fails requests fast after config.FailureThreshold consecutive failures.
+// A FailureThreshold of zero disables the circuit breaker, which is the default.
+func WithCircuitBreaker(config CircuitBreakerConfig) Option {
+	return func(c *Client) {
+		if config.FailureThreshold <= 0 {
+			c.breaker = nil
+			return
+		}
+		c.breaker = &circuitBreaker{
+			config: config,
+			logger: c.logger,
+			now:    time.Now,
+			state:  CircuitClosed,
+		}
+	}
+}
+
+// CircuitState returns the state of the circuit breaker, or "" if it is disabled.
+func (c *Client) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return ""
+	}
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+	return c.breaker.state
+}
+
+// circuitBreaker tracks consecutive request failures.
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// probing is set while the single half-open probe request is in flight.
+	probing bool
+}
+
+// allow returns a *CircuitOpenError if a request must not be sent.
+func (b *circuitBreaker) allow(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		remaining := b.config.OpenTimeout - b.now().Sub(b.openedAt)
+		if remaining > 0 {
+			return &CircuitOpenError{
+				RetryAfter: remaining,
+				reason: fmt.Sprintf("after %d consecutive failures, next attempt in %s",
+					b.failures, remaining.Round(time.Second)),
+			}
+		}
+		b.transition(ctx, CircuitHalfOpen)
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{reason: "while a probe request is in flight"}
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of an allowed request that was sent to Cloudflare.
+// Requests canceled by the caller and permanent transport errors, such as an untrusted
+// certificate, say nothing about the API's health and count as neither successes nor failures.
+func (b *circuitBreaker) record(ctx context.Context, resp *http.Response, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	switch {
+	case ctx.Err() != nil:
+		return
+	case retryReason(ctx, resp, err) != "":
+		b.failures++
+		if b.state == CircuitHalfOpen || b.failures >= b.config.FailureThreshold {
+			b.openedAt = b.now()
+			b.transition(ctx, CircuitOpen)
+		}
+	case err != nil:
+		return
+	default:
+		b.failures = 0
+		b.transition(ctx, CircuitClosed)
+	}
+}
+
+// transition changes the state and reports changes. b.mu must be held.
+func (b *circuitBreaker) transition(ctx context.Context, state CircuitState) {
+	if b.state == state {
+		return
+	}
+
+	switch state {
+	case CircuitOpen:
+		b.logger.WarnContext(ctx, "Cloudflare API circuit breaker opened",
+			"consecutive_failures", b.failures,
+			"open_timeout", b.config.OpenTimeout)
+	case CircuitHalfOpen:
+		b.logger.InfoContext(ctx, "Cloudflare API circuit breaker half-open, probing")
+	case CircuitClosed:
+		b.logger.InfoContext(ctx, "Cloudflare API circuit breaker closed")
+	}
+
+	b.state = state
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(state)
+	}
+}
@@ -0,0 +1,140 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// breakerTestServer answers with the status stored in status.
+func breakerTestServer(t *testing.T, status *atomic.Int32, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte(`{"success": true, "result": {"id": "ruleset", "rules": []}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	var status, requests atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	server := breakerTestServer(t, &status, &requests)
+
+	var transitions []CircuitState
+	client := newRetryTestClient(server.URL,
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+		WithCircuitBreaker(CircuitBreakerConfig{
+			FailureThreshold: 3,
+			OpenTimeout:      time.Minute,
+			OnStateChange:    func(state CircuitState) { transitions = append(transitions, state) },
+		}))
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := client.GetEntrypointRuleset(ctx, "zone", "phase"); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected the circuit to be closed, got %v", err)
+		}
+	}
+	if client.CircuitState() != CircuitOpen {
+		t.Fatalf("expected the circuit to open after 3 failures, got %q", client.CircuitState())
+	}
+
+	_, err := client.GetEntrypointRuleset(ctx, "zone", "phase")
+	var circuitErr *CircuitOpenError
+	if !errors.As(err, &circuitErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	} else if circuitErr.RetryAfter != time.Minute {
+		t.Errorf("expected to retry after a minute, got %s", circuitErr.RetryAfter)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("expected the open circuit to fail fast, got %d requests", requests.Load())
+	}
+
+	// A failed probe reopens the circuit.
+	now = now.Add(time.Minute)
+	if _, err = client.GetEntrypointRuleset(ctx, "zone", "phase"); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected a probe request, got %v", err)
+	}
+	if client.CircuitState() != CircuitOpen {
+		t.Errorf("expected the failed probe to reopen the circuit, got %q", client.CircuitState())
+	}
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	status.Store(http.StatusOK)
+	if _, err = client.GetEntrypointRuleset(ctx, "zone", "phase"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.CircuitState() != CircuitClosed {
+		t.Errorf("expected the circuit to close, got %q", client.CircuitState())
+	}
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("expected transitions %v, got %v", want, transitions)
+			break
+		}
+	}
+}
+
+func TestClient_CircuitBreakerIgnoresClientErrors(t *testing.T) {
+	var status, requests atomic.Int32
+	status.Store(http.StatusBadRequest)
+	server := breakerTestServer(t, &status, &requests)
+
+	client := newRetryTestClient(server.URL,
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute}))
+
+	for range 3 {
+		if _, err := client.GetEntrypointRuleset(context.Background(), "zone", "phase"); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected client errors not to open the circuit, got %v", err)
+		}
+	}
+
+	// Canceled requests do not count either.
+	status.Store(http.StatusServiceUnavailable)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetEntrypointRuleset(ctx, "zone", "phase"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if client.CircuitState() != CircuitClosed {
+		t.Errorf("expected the circuit to stay closed, got %q", client.CircuitState())
+	}
+}
+
+func TestClient_CircuitBreakerIgnoresLocalErrors(t *testing.T) {
+	client := newRetryTestClient("http://invalid host",
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute}))
+
+	for range 3 {
+		_, err := client.GetEntrypointRuleset(context.Background(), "zone", "phase")
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected a request error, got %v", err)
+		}
+	}
+	if client.CircuitState() != CircuitClosed {
+		t.Errorf("expected requests that were never sent not to open the circuit, got %q", client.CircuitState())
+	}
+}
+
+func TestClient_CircuitBreakerDisabled(t *testing.T) {
+	client := newRetryTestClient("http://localhost", WithCircuitBreaker(CircuitBreakerConfig{}))
+	if client.CircuitState() != "" {
+		t.Errorf("expected no circuit state, got %q", client.CircuitState())
+	}
+}
@@ -0,0 +1,306 @@
+// Package cftest provides an in-memory implementation of cloudflare.RulesetAPI for tests.
+package cftest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// OperationDeleteRule is recorded for DeleteRule, which the Cloudflare client does not offer.
+const OperationDeleteRule = "delete_rule"
+
+var (
+	// ErrNotFound is returned for unknown rulesets and rules, like a Cloudflare 404 response.
+	ErrNotFound = cloudflare.ErrNotFound
+	// ErrBadRequest is returned for invalid rules and updates, like a Cloudflare 400 response.
+	ErrBadRequest = cloudflare.ErrInvalidRequest
+)
+
+// Fake is an in-memory cloudflare.RulesetAPI that behaves like the Cloudflare rulesets API.
+// Rules get sequential IDs and their version is incremented on every update.
+// It is safe for concurrent use.
+type Fake struct {
+	mu          sync.Mutex
+	rulesets    map[string]*zoneRuleset
+	entrypoints map[entrypointKey]string
+	nextID      int
+	err         error
+	failures    map[string][]error
+	calls       []string
+}
+
+// zoneRuleset is a stored ruleset and the zone it belongs to.
+type zoneRuleset struct {
+	zoneID  string
+	ruleset types.CloudflareRuleset
+}
+
+// entrypointKey identifies the entrypoint ruleset of a zone phase.
+type entrypointKey struct {
+	zoneID string
+	phase  string
+}
+
+// Fake must satisfy cloudflare.RulesetAPI.
+var _ cloudflare.RulesetAPI = (*Fake)(nil)
+
+// NewFake creates a Fake without any rulesets.
+func NewFake() *Fake {
+	return &Fake{
+		rulesets:    make(map[string]*zoneRuleset),
+		entrypoints: make(map[entrypointKey]string),
+		failures:    make(map[string][]error),
+	}
+}
+
+// SetError makes every subsequent call return err until it is reset with nil.
+func (f *Fake) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// FailNext makes the next call of operation, e.g. cloudflare.OperationUpdateRule, return err.
+// Repeated calls queue further failures.
+func (f *Fake) FailNext(operation string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[operation] = append(f.failures[operation], err)
+}
+
+// ClearFailures removes the error set with SetError and all queued failures.
+func (f *Fake) ClearFailures() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = nil
+	clear(f.failures)
+}
+
+// Calls returns the operations called so far, in order.
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// Ruleset returns a copy of a stored ruleset.
+func (f *Fake) Ruleset(rulesetID string) (*types.CloudflareRuleset, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored, ok := f.rulesets[rulesetID]
+	if !ok {
+		return nil, false
+	}
+	return cloneRuleset(stored.ruleset), true
+}
+
+// Rule returns a copy of a stored rule.
+func (f *Fake) Rule(rulesetID, ruleID string) (*types.CloudflareRule, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored, ok := f.rulesets[rulesetID]
+	if !ok {
+		return nil, false
+	}
+	index := ruleIndex(stored.ruleset.Rules, ruleID)
+	if index < 0 {
+		return nil, false
+	}
+	rule := stored.ruleset.Rules[index]
+	return &rule, true
+}
+
+// GetEntrypointRuleset returns the entrypoint ruleset of a zone phase or cloudflare.ErrEntrypointNotFound.
+func (f *Fake) GetEntrypointRuleset(ctx context.Context, zoneID, phase string) (*types.CloudflareRuleset, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(ctx, cloudflare.OperationGetEntrypointRuleset); err != nil {
+		return nil, err
+	}
+	rulesetID, ok := f.entrypoints[entrypointKey{zoneID: zoneID, phase: phase}]
+	if !ok {
+		return nil, cloudflare.ErrEntrypointNotFound
+	}
+	return cloneRuleset(f.rulesets[rulesetID].ruleset), nil
+}
+
+// CreateEntrypointRuleset creates an empty entrypoint ruleset for a zone phase.
+// Like Cloudflare, it fails if the phase already has an entrypoint.
+func (f *Fake) CreateEntrypointRuleset(ctx context.Context, zoneID, phase string) (*types.CloudflareRuleset, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(ctx, cloudflare.OperationCreateEntrypointRuleset); err != nil {
+		return nil, err
+	}
+	key := entrypointKey{zoneID: zoneID, phase: phase}
+	if _, ok := f.entrypoints[key]; ok {
+		return nil, fmt.Errorf("%w: phase %s already has an entrypoint ruleset", ErrBadRequest, phase)
+	}
+
+	ruleset := types.CloudflareRuleset{
+		ID:    f.newID("ruleset"),
+		Name:  phase + " entrypoint",
+		Kind:  "zone",
+		Phase: phase,
+		Rules: []types.CloudflareRule{},
+	}
+	f.rulesets[ruleset.ID] = &zoneRuleset{zoneID: zoneID, ruleset: ruleset}
+	f.entrypoints[key] = ruleset.ID
+	return cloneRuleset(ruleset), nil
+}
+
+// AddRule appends a rule to a ruleset, assigning its ID and version 1.
+func (f *Fake) AddRule(
+	ctx context.Context,
+	zoneID, rulesetID string,
+	rule types.CloudflareRule,
+) (*types.CloudflareRule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(ctx, cloudflare.OperationAddRule); err != nil {
+		return nil, err
+	}
+	stored, err := f.ruleset(zoneID, rulesetID)
+	if err != nil {
+		return nil, err
+	}
+	if rule.Action == "" || rule.Expression == "" {
+		return nil, fmt.Errorf("%w: action and expression are required", ErrBadRequest)
+	}
+
+	rule.ID = f.newID("rule")
+	rule.Version = 1
+	stored.ruleset.Rules = append(stored.ruleset.Rules, rule)
+	return &rule, nil
+}
+
+// UpdateRule applies updates to a rule and increments its version.
+// Supported fields are "action", "expression", "description", and "enabled".
+func (f *Fake) UpdateRule(
+	ctx context.Context,
+	zoneID, rulesetID, ruleID string,
+	updates map[string]interface{},
+) (*types.CloudflareRule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(ctx, cloudflare.OperationUpdateRule); err != nil {
+		return nil, err
+	}
+	stored, err := f.ruleset(zoneID, rulesetID)
+	if err != nil {
+		return nil, err
+	}
+	index := ruleIndex(stored.ruleset.Rules, ruleID)
+	if index < 0 {
+		return nil, fmt.Errorf("%w: rule %s", ErrNotFound, ruleID)
+	}
+
+	rule := stored.ruleset.Rules[index]
+	if err = applyUpdates(&rule, updates); err != nil {
+		return nil, err
+	}
+	rule.Version++
+	stored.ruleset.Rules[index] = rule
+	return &rule, nil
+}
+
+// DeleteRule removes a rule from a ruleset, e.g. to simulate a change made in the dashboard.
+func (f *Fake) DeleteRule(ctx context.Context, zoneID, rulesetID, ruleID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(ctx, OperationDeleteRule); err != nil {
+		return err
+	}
+	stored, err := f.ruleset(zoneID, rulesetID)
+	if err != nil {
+		return err
+	}
+	index := ruleIndex(stored.ruleset.Rules, ruleID)
+	if index < 0 {
+		return fmt.Errorf("%w: rule %s", ErrNotFound, ruleID)
+	}
+	stored.ruleset.Rules = slices.Delete(stored.ruleset.Rules, index, index+1)
+	return nil
+}
+
+// call records a call of an operation that does not touch rulesets and returns the injected or
+// context error, if any.
+func (f *Fake) call(ctx context.Context, operation string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.begin(ctx, operation)
+}
+
+// begin records a call and returns the injected or context error, if any. f.mu must be held.
+func (f *Fake) begin(ctx context.Context, operation string) error {
+	f.calls = append(f.calls, operation)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if queued := f.failures[operation]; len(queued) > 0 {
+		f.failures[operation] = queued[1:]
+		return queued[0]
+	}
+	return f.err
+}
+
+// ruleset returns a stored ruleset of a zone. f.mu must be held.
+func (f *Fake) ruleset(zoneID, rulesetID string) (*zoneRuleset, error) {
+	stored, ok := f.rulesets[rulesetID]
+	if !ok || stored.zoneID != zoneID {
+		return nil, fmt.Errorf("%w: ruleset %s", ErrNotFound, rulesetID)
+	}
+	return stored, nil
+}
+
+// newID returns a unique ID with the given prefix. f.mu must be held.
+func (f *Fake) newID(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s-%d", prefix, f.nextID)
+}
+
+// applyUpdates sets the updated fields on rule.
+func applyUpdates(rule *types.CloudflareRule, updates map[string]interface{}) error {
+	for field, value := range updates {
+		var ok bool
+		switch field {
+		case "action":
+			rule.Action, ok = value.(string)
+		case "expression":
+			rule.Expression, ok = value.(string)
+		case "description":
+			rule.Description, ok = value.(string)
+		case "enabled":
+			rule.Enabled, ok = value.(bool)
+		default:
+			return fmt.Errorf("%w: unknown field %q", ErrBadRequest, field)
+		}
+		if !ok {
+			return fmt.Errorf("%w: invalid value for %s: %v", ErrBadRequest, field, value)
+		}
+	}
+	return nil
+}
+
+// ruleIndex returns the index of the rule with ruleID, or -1.
+func ruleIndex(rules []types.CloudflareRule, ruleID string) int {
+	return slices.IndexFunc(rules, func(rule types.CloudflareRule) bool { return rule.ID == ruleID })
+}
+
+// cloneRuleset copies a ruleset so callers cannot mutate the stored rules.
+func cloneRuleset(ruleset types.CloudflareRuleset) *types.CloudflareRuleset {
+	ruleset.Rules = slices.Clone(ruleset.Rules)
+	return &ruleset
+}
@@ -0,0 +1,95 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package cftest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+func TestFake_RulesetOperations(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	phase := types.HTTPRequestFirewallCustomPhase
+
+	if _, err := fake.GetEntrypointRuleset(ctx, "zone", phase); !errors.Is(err, cloudflare.ErrEntrypointNotFound) {
+		t.Fatalf("expected ErrEntrypointNotFound, got %v", err)
+	}
+
+	ruleset, err := fake.CreateEntrypointRuleset(ctx, "zone", phase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = fake.CreateEntrypointRuleset(ctx, "zone", phase); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected ErrBadRequest for a second entrypoint, got %v", err)
+	}
+
+	rule, err := fake.AddRule(ctx, "zone", ruleset.ID, types.CloudflareRule{Action: "block", Expression: "true"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.ID == "" || rule.Version.Int() != 1 {
+		t.Errorf("expected an ID and version 1, got %+v", rule)
+	}
+	if _, err = fake.AddRule(ctx, "other-zone", ruleset.ID, *rule); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a ruleset of another zone, got %v", err)
+	}
+
+	updated, err := fake.UpdateRule(ctx, "zone", ruleset.ID, rule.ID, map[string]interface{}{"enabled": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !updated.Enabled || updated.Expression != "true" || updated.Version.Int() != 2 {
+		t.Errorf("expected enabled rule at version 2 with unchanged expression, got %+v", updated)
+	}
+	_, err = fake.UpdateRule(ctx, "zone", ruleset.ID, rule.ID, map[string]interface{}{"enabled": "yes"})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected ErrBadRequest for an invalid value, got %v", err)
+	}
+
+	if err = fake.DeleteRule(ctx, "zone", ruleset.ID, rule.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = fake.UpdateRule(ctx, "zone", ruleset.ID, rule.ID, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted rule, got %v", err)
+	}
+
+	stored, ok := fake.Ruleset(ruleset.ID)
+	if !ok || len(stored.Rules) != 0 {
+		t.Errorf("expected an empty ruleset, got %+v", stored)
+	}
+}
+
+func TestFake_Failures(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	phase := types.HTTPRequestFirewallCustomPhase
+	injected := errors.New("injected")
+
+	fake.FailNext(cloudflare.OperationCreateEntrypointRuleset, injected)
+	if _, err := fake.CreateEntrypointRuleset(ctx, "zone", phase); !errors.Is(err, injected) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+	if _, err := fake.CreateEntrypointRuleset(ctx, "zone", phase); err != nil {
+		t.Errorf("expected the failure to be used up, got %v", err)
+	}
+
+	fake.SetError(injected)
+	if _, err := fake.GetEntrypointRuleset(ctx, "zone", phase); !errors.Is(err, injected) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+	fake.SetError(nil)
+
+	want := []string{
+		cloudflare.OperationCreateEntrypointRuleset,
+		cloudflare.OperationCreateEntrypointRuleset,
+		cloudflare.OperationGetEntrypointRuleset,
+	}
+	if calls := fake.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+}
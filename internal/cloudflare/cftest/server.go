@@ -0,0 +1,304 @@
+package cftest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/pkg/types"
+	"golang.org/x/time/rate"
+)
+
+// Cloudflare error codes returned by the Server.
+const (
+	codeAuthentication = 10000
+	codeNotFound       = 10003
+	codeBadRequest     = 10001
+	codeRateLimited    = 10429
+	codeInternal       = 10002
+)
+
+const (
+	// FailuresPath is the Server endpoint for injecting failures at runtime.
+	FailuresPath = "/_fake/failures"
+	// FakeAccountID is the account of every zone served by the Server.
+	FakeAccountID = "fake-account"
+	// Largest valid HTTP status code.
+	maxStatusCode = 599
+)
+
+// StatusError is an injected failure that the Server answers with StatusCode and a Cloudflare error.
+type StatusError struct {
+	StatusCode int
+	Code       int
+	Message    string
+}
+
+// newStatusError creates a StatusError.
+func newStatusError(statusCode, code int, message string) *StatusError {
+	return &StatusError{StatusCode: statusCode, Code: code, Message: message}
+}
+
+// Error implements error.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Failure is the request body of FailuresPath.
+type Failure struct {
+	// Operation is e.g. cloudflare.OperationUpdateRule; empty fails every call until failures are cleared.
+	Operation  string `json:"operation"`
+	StatusCode int    `json:"status_code"`
+	Message    string `json:"message,omitempty"`
+	// Count is the number of failing calls of Operation (default 1).
+	Count int `json:"count"`
+}
+
+// ServerConfig configures a Server.
+type ServerConfig struct {
+	// Token is the required API token; empty accepts any request.
+	Token string
+	// RateLimit is the sustained number of requests per second; zero disables rate limiting.
+	RateLimit float64
+	// RateBurst is the number of requests allowed at once.
+	RateBurst int
+	// ErrorRate is the fraction of requests answered with 500 Internal Server Error.
+	ErrorRate float64
+	// Latency delays every response.
+	Latency time.Duration
+}
+
+// Server serves the Cloudflare rulesets, zone, and API token endpoints used by cloudflare.Client from a Fake.
+// Its routes are relative to the API base URL, e.g. https://api.cloudflare.com/client/v4.
+type Server struct {
+	fake    *Fake
+	config  ServerConfig
+	limiter *rate.Limiter
+	logger  *slog.Logger
+	mux     *http.ServeMux
+}
+
+// NewServer creates a Server for fake.
+func NewServer(fake *Fake, config ServerConfig, logger *slog.Logger) *Server {
+	s := &Server{
+		fake:   fake,
+		config: config,
+		logger: logger,
+		mux:    http.NewServeMux(),
+	}
+	if config.RateLimit > 0 {
+		s.limiter = rate.NewLimiter(rate.Limit(config.RateLimit), max(config.RateBurst, 1))
+	}
+
+	s.mux.HandleFunc("GET /user/tokens/verify", s.verifyToken)
+	s.mux.HandleFunc("GET /user/tokens/{token}", s.getToken)
+	s.mux.HandleFunc("GET /accounts/{account}/tokens/verify", s.verifyToken)
+	s.mux.HandleFunc("GET /accounts/{account}/tokens/{token}", s.getToken)
+	s.mux.HandleFunc("GET /zones/{zone}", s.getZone)
+	s.mux.HandleFunc("GET /zones/{zone}/rulesets/phases/{phase}/entrypoint", s.getEntrypointRuleset)
+	s.mux.HandleFunc("POST /zones/{zone}/rulesets", s.createEntrypointRuleset)
+	s.mux.HandleFunc("POST /zones/{zone}/rulesets/{ruleset}/rules", s.addRule)
+	s.mux.HandleFunc("PATCH /zones/{zone}/rulesets/{ruleset}/rules/{rule}", s.updateRule)
+	s.mux.HandleFunc("DELETE /zones/{zone}/rulesets/{ruleset}/rules/{rule}", s.deleteRule)
+	s.mux.HandleFunc("POST "+FailuresPath, s.injectFailure)
+	s.mux.HandleFunc("DELETE "+FailuresPath, s.clearFailures)
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Fake Cloudflare request", "method", r.Method, "path", r.URL.Path)
+
+	if s.config.Latency > 0 {
+		select {
+		case <-time.After(s.config.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if r.URL.Path != FailuresPath {
+		if s.config.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.config.Token {
+			writeError(w, newStatusError(http.StatusForbidden, codeAuthentication, "Authentication error"))
+			return
+		}
+		if s.limiter != nil && !s.limiter.Allow() {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, newStatusError(http.StatusTooManyRequests, codeRateLimited, "Rate limited"))
+			return
+		}
+		//nolint:gosec // Random failures only need to be unpredictable enough to exercise retries.
+		if s.config.ErrorRate > 0 && rand.Float64() < s.config.ErrorRate {
+			writeError(w, newStatusError(http.StatusInternalServerError, codeInternal, "Injected internal error"))
+			return
+		}
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// verifyToken handles GET /user/tokens/verify and GET /accounts/{account}/tokens/verify.
+// Invalid tokens are rejected by ServeHTTP.
+func (s *Server) verifyToken(w http.ResponseWriter, r *http.Request) {
+	err := s.fake.call(r.Context(), cloudflare.OperationVerifyToken)
+	writeResult(w, types.CloudflareTokenStatus{ID: "fake-token", Status: "active"}, err)
+}
+
+// getToken handles GET /user/tokens/{token} and GET /accounts/{account}/tokens/{token}.
+// The token may edit the rulesets of every zone.
+func (s *Server) getToken(w http.ResponseWriter, r *http.Request) {
+	err := s.fake.call(r.Context(), cloudflare.OperationGetToken)
+	writeResult(w, types.CloudflareToken{
+		ID:     r.PathValue("token"),
+		Status: "active",
+		Policies: []types.CloudflareTokenPolicy{{
+			Effect:           "allow",
+			PermissionGroups: []types.CloudflarePermissionGroup{{Name: "Zone WAF Write"}},
+			Resources:        map[string]json.RawMessage{"com.cloudflare.api.account.zone.*": json.RawMessage(`"*"`)},
+		}},
+	}, err)
+}
+
+// getZone handles GET /zones/{zone}. Every zone ID exists and belongs to FakeAccountID.
+func (s *Server) getZone(w http.ResponseWriter, r *http.Request) {
+	err := s.fake.call(r.Context(), cloudflare.OperationGetZone)
+	zoneID := r.PathValue("zone")
+	zone := types.CloudflareZone{ID: zoneID, Name: zoneID, Status: "active"}
+	zone.Account.ID = FakeAccountID
+	writeResult(w, zone, err)
+}
+
+// getEntrypointRuleset handles GET /zones/{zone}/rulesets/phases/{phase}/entrypoint.
+func (s *Server) getEntrypointRuleset(w http.ResponseWriter, r *http.Request) {
+	ruleset, err := s.fake.GetEntrypointRuleset(r.Context(), r.PathValue("zone"), r.PathValue("phase"))
+	writeResult(w, ruleset, err)
+}
+
+// createEntrypointRuleset handles POST /zones/{zone}/rulesets.
+func (s *Server) createEntrypointRuleset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Kind  string `json:"kind"`
+		Phase string `json:"phase"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Kind != "zone" || req.Phase == "" {
+		writeError(w, newStatusError(http.StatusBadRequest, codeBadRequest, "kind zone and phase are required"))
+		return
+	}
+
+	ruleset, err := s.fake.CreateEntrypointRuleset(r.Context(), r.PathValue("zone"), req.Phase)
+	writeResult(w, ruleset, err)
+}
+
+// addRule handles POST /zones/{zone}/rulesets/{ruleset}/rules.
+func (s *Server) addRule(w http.ResponseWriter, r *http.Request) {
+	var rule types.CloudflareRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeError(w, newStatusError(http.StatusBadRequest, codeBadRequest, "invalid rule"))
+		return
+	}
+
+	created, err := s.fake.AddRule(r.Context(), r.PathValue("zone"), r.PathValue("ruleset"), rule)
+	writeResult(w, created, err)
+}
+
+// updateRule handles PATCH /zones/{zone}/rulesets/{ruleset}/rules/{rule}.
+func (s *Server) updateRule(w http.ResponseWriter, r *http.Request) {
+	var updates map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		writeError(w, newStatusError(http.StatusBadRequest, codeBadRequest, "invalid rule"))
+		return
+	}
+	// The rule ID may be sent in the body but cannot be changed.
+	delete(updates, "id")
+
+	updated, err := s.fake.UpdateRule(
+		r.Context(), r.PathValue("zone"), r.PathValue("ruleset"), r.PathValue("rule"), updates)
+	writeResult(w, updated, err)
+}
+
+// deleteRule handles DELETE /zones/{zone}/rulesets/{ruleset}/rules/{rule}.
+func (s *Server) deleteRule(w http.ResponseWriter, r *http.Request) {
+	err := s.fake.DeleteRule(r.Context(), r.PathValue("zone"), r.PathValue("ruleset"), r.PathValue("rule"))
+	writeResult(w, map[string]string{"id": r.PathValue("rule")}, err)
+}
+
+// injectFailure handles POST FailuresPath.
+func (s *Server) injectFailure(w http.ResponseWriter, r *http.Request) {
+	var failure Failure
+	if err := json.NewDecoder(r.Body).Decode(&failure); err != nil {
+		writeError(w, newStatusError(http.StatusBadRequest, codeBadRequest, "invalid failure"))
+		return
+	}
+	if failure.StatusCode < http.StatusBadRequest || failure.StatusCode > maxStatusCode {
+		writeError(w, newStatusError(http.StatusBadRequest, codeBadRequest, "status_code must be an error status"))
+		return
+	}
+
+	err := &StatusError{StatusCode: failure.StatusCode, Code: codeInternal, Message: failure.Message}
+	if err.Message == "" {
+		err.Message = "Injected failure"
+	}
+
+	if failure.Operation == "" {
+		s.fake.SetError(err)
+	} else {
+		for range max(failure.Count, 1) {
+			s.fake.FailNext(failure.Operation, err)
+		}
+	}
+
+	s.logger.Info("Injected fake Cloudflare failure",
+		"operation", failure.Operation, "status_code", failure.StatusCode, "count", failure.Count)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearFailures handles DELETE FailuresPath.
+func (s *Server) clearFailures(w http.ResponseWriter, _ *http.Request) {
+	s.fake.ClearFailures()
+	s.logger.Info("Cleared fake Cloudflare failures")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeResult writes result in a Cloudflare response envelope, or the error matching err.
+func writeResult(w http.ResponseWriter, result interface{}, err error) {
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, types.CloudflareAPIResponse{
+			Success: true,
+			Errors:  []types.CloudflareAPIError{},
+			Result:  result,
+		})
+	case errors.As(err, &statusErr):
+		writeError(w, statusErr)
+	case errors.Is(err, ErrNotFound), errors.Is(err, cloudflare.ErrEntrypointNotFound):
+		writeError(w, newStatusError(http.StatusNotFound, codeNotFound, err.Error()))
+	case errors.Is(err, ErrBadRequest):
+		writeError(w, newStatusError(http.StatusBadRequest, codeBadRequest, err.Error()))
+	default:
+		writeError(w, newStatusError(http.StatusInternalServerError, codeInternal, err.Error()))
+	}
+}
+
+// writeError writes a Cloudflare error response.
+func writeError(w http.ResponseWriter, err *StatusError) {
+	writeJSON(w, err.StatusCode, types.CloudflareAPIResponse{
+		Success: false,
+		Errors:  []types.CloudflareAPIError{{Code: err.Code, Message: err.Message}},
+	})
+}
+
+// writeJSON writes body as JSON with status.
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		//nolint:sloglint // Global logger acceptable for JSON encoding errors after response started
+		slog.Warn("Failed to encode fake Cloudflare response", "error", err)
+	}
+}
@@ -0,0 +1,211 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package cftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+func newTestServer(t *testing.T, config ServerConfig) *httptest.Server {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	server := httptest.NewServer(NewServer(NewFake(), config, logger))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServer_ClientRoundTrip(t *testing.T) {
+	server := newTestServer(t, ServerConfig{Token: "test-token"})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	client := cloudflare.NewClient("test-token", logger, cloudflare.WithBaseURL(server.URL+"/"))
+	ctx := context.Background()
+	phase := types.HTTPRequestFirewallCustomPhase
+
+	if _, err := client.GetEntrypointRuleset(ctx, "zone", phase); !errors.Is(err, cloudflare.ErrEntrypointNotFound) {
+		t.Fatalf("expected ErrEntrypointNotFound, got %v", err)
+	}
+
+	ruleset, err := client.CreateEntrypointRuleset(ctx, "zone", phase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rule, err := client.AddRule(ctx, "zone", ruleset.ID, types.CloudflareRule{
+		Action:      types.BlockAction,
+		Expression:  types.BuildExpression([]string{"a.example.com"}),
+		Description: types.RuleDescription,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := client.UpdateRule(ctx, "zone", ruleset.ID, rule.ID, map[string]interface{}{"enabled": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !updated.Enabled || updated.Version.Int() != 2 || updated.Expression != rule.Expression {
+		t.Errorf("expected enabled rule at version 2, got %+v", updated)
+	}
+
+	fetched, err := client.GetEntrypointRuleset(ctx, "zone", phase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found := cloudflare.FindRuleByDescription(fetched, types.RuleDescription); found == nil || !found.Enabled {
+		t.Errorf("expected the enabled rule in the ruleset, got %+v", fetched)
+	}
+
+	if _, err = client.UpdateRule(ctx, "zone", ruleset.ID, "missing", nil); err == nil ||
+		!strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+
+	wrongToken := cloudflare.NewClient("wrong-token", logger, cloudflare.WithBaseURL(server.URL))
+	if _, err = wrongToken.GetEntrypointRuleset(ctx, "zone", phase); err == nil {
+		t.Error("expected an error for a wrong token")
+	}
+}
+
+func TestServer_InjectedFailures(t *testing.T) {
+	server := newTestServer(t, ServerConfig{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	// Disable retries so that every injected failure reaches the caller.
+	client := cloudflare.NewClient("test-token", logger, cloudflare.WithBaseURL(server.URL),
+		cloudflare.WithRetryPolicy(cloudflare.RetryPolicy{MaxAttempts: 1}))
+	ctx := context.Background()
+
+	injectFailure(t, server, Failure{Operation: cloudflare.OperationCreateEntrypointRuleset, StatusCode: 503})
+	if _, err := client.CreateEntrypointRuleset(ctx, "zone", "phase"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected a 503 error, got %v", err)
+	}
+	if _, err := client.CreateEntrypointRuleset(ctx, "zone", "phase"); err != nil {
+		t.Errorf("expected the failure to be used up, got %v", err)
+	}
+
+	injectFailure(t, server, Failure{StatusCode: 500})
+	if _, err := client.GetEntrypointRuleset(ctx, "zone", "phase"); err == nil {
+		t.Error("expected every call to fail")
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodDelete, server.URL+FailuresPath, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to clear failures: %v", err)
+	}
+	resp.Body.Close()
+	if _, err = client.GetEntrypointRuleset(ctx, "zone", "phase"); err != nil {
+		t.Errorf("expected failures to be cleared, got %v", err)
+	}
+}
+
+func TestServer_PermissionChecks(t *testing.T) {
+	server := newTestServer(t, ServerConfig{Token: "test-token"})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	client := cloudflare.NewClient("test-token", logger, cloudflare.WithBaseURL(server.URL),
+		cloudflare.WithRetryPolicy(cloudflare.RetryPolicy{MaxAttempts: 1}))
+	ctx := context.Background()
+
+	statuses := func(report cloudflare.PermissionReport) []cloudflare.CheckStatus {
+		result := make([]cloudflare.CheckStatus, 0, len(report))
+		for _, check := range report {
+			result = append(result, check.Status)
+		}
+		return result
+	}
+	ok := cloudflare.CheckOK
+
+	tests := []struct {
+		name    string
+		failure *Failure
+		want    []cloudflare.CheckStatus
+	}{
+		{name: "user token", want: []cloudflare.CheckStatus{ok, ok, ok, ok}},
+		{
+			// The user endpoint rejects account-owned tokens, which are verified through the zone's account.
+			name:    "account-owned token",
+			failure: &Failure{Operation: cloudflare.OperationVerifyToken, StatusCode: http.StatusUnauthorized},
+			want:    []cloudflare.CheckStatus{ok, ok, ok, ok},
+		},
+		{
+			name:    "token details not readable",
+			failure: &Failure{Operation: cloudflare.OperationGetToken, StatusCode: http.StatusForbidden},
+			want:    []cloudflare.CheckStatus{ok, ok, ok, cloudflare.CheckUnverified},
+		},
+		{
+			name:    "zone not readable",
+			failure: &Failure{Operation: cloudflare.OperationGetZone, StatusCode: http.StatusForbidden},
+			want:    []cloudflare.CheckStatus{ok, cloudflare.CheckMissing, ok, cloudflare.CheckError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.failure != nil {
+				injectFailure(t, server, *tt.failure)
+			}
+			if got := statuses(client.CheckPermissions(ctx, "zone")); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	server := newTestServer(t, ServerConfig{RateLimit: 1, RateBurst: 1})
+	url := server.URL + "/zones/zone/rulesets/phases/phase/entrypoint"
+
+	statuses := make([]int, 0, 2)
+	for range 2 {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get("Retry-After") == "" {
+			t.Error("expected Retry-After on 429")
+		}
+	}
+
+	if statuses[0] != http.StatusNotFound || statuses[1] != http.StatusTooManyRequests {
+		t.Errorf("expected 404 then 429, got %v", statuses)
+	}
+}
+
+func injectFailure(t *testing.T, server *httptest.Server, failure Failure) {
+	t.Helper()
+
+	body, _ := json.Marshal(failure)
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+FailuresPath,
+		bytes.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to inject failure: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+}
@@ -9,10 +9,16 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/meyeringh/cf-switch/pkg/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ErrEntrypointNotFound indicates that the requested entrypoint ruleset does not exist.
@@ -21,68 +27,124 @@ var ErrEntrypointNotFound = errors.New("entrypoint ruleset not found")
 const (
 	// HTTP timeout for Cloudflare API requests.
 	defaultTimeout = 30 * time.Second
-	// Maximum number of retry attempts for rate limited requests.
-	maxRetries = 3
-	// Maximum wait time for rate limit retry in seconds.
-	maxRetryWaitSeconds = 60
+	// DefaultBaseURL is the Cloudflare API v4 base URL.
+	DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+	// Number of items requested per page from paginated endpoints.
+	listPageSize = 50
+	// Name of the tracer for Cloudflare API spans.
+	tracerName = "github.com/meyeringh/cf-switch/internal/cloudflare"
 )
 
+// Operations reported to instrumentation.
+const (
+	OperationListRulesets            = "list_rulesets"
+	OperationGetEntrypointRuleset    = "get_entrypoint_ruleset"
+	OperationCreateEntrypointRuleset = "create_entrypoint_ruleset"
+	OperationAddRule                 = "add_rule"
+	OperationUpdateRule              = "update_rule"
+)
+
+// RulesetAPI is the set of Cloudflare ruleset operations used by the reconciler,
+// implemented by Client and cftest.Fake.
+type RulesetAPI interface {
+	GetEntrypointRuleset(ctx context.Context, zoneID, phase string) (*types.CloudflareRuleset, error)
+	CreateEntrypointRuleset(ctx context.Context, zoneID, phase string) (*types.CloudflareRuleset, error)
+	AddRule(ctx context.Context, zoneID, rulesetID string, rule types.CloudflareRule) (*types.CloudflareRule, error)
+	UpdateRule(
+		ctx context.Context,
+		zoneID, rulesetID, ruleID string,
+		updates map[string]interface{},
+	) (*types.CloudflareRule, error)
+}
+
+// Client must satisfy RulesetAPI.
+var _ RulesetAPI = (*Client)(nil)
+
 // Client represents a Cloudflare API client.
 type Client struct {
 	httpClient *http.Client
 	baseURL    string
 	apiToken   string
 	logger     *slog.Logger
+	instrument Instrumentation
+	retry      RetryPolicy
+	breaker    *circuitBreaker
+}
+
+// RequestInfo describes a completed Cloudflare API request attempt.
+type RequestInfo struct {
+	// Operation names the client method, e.g. OperationUpdateRule.
+	Operation string
+	Method    string
+	// StatusCode is zero if no response was received.
+	StatusCode int
+	// ErrorCodes are the Cloudflare error codes in an error response.
+	ErrorCodes []int
+	Duration   time.Duration
+	// Err is set if no response was received.
+	Err error
+}
+
+// Instrumentation is called after every Cloudflare API request attempt.
+type Instrumentation func(ctx context.Context, info RequestInfo)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithInstrumentation reports every request attempt to hook, e.g. to record metrics.
+func WithInstrumentation(hook Instrumentation) Option {
+	return func(c *Client) {
+		c.instrument = hook
+	}
+}
+
+// WithBaseURL sends requests to baseURL instead of DefaultBaseURL, e.g. to a fake API server.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
 }
 
 // NewClient creates a new Cloudflare API client.
-func NewClient(apiToken string, logger *slog.Logger) *Client {
-	return &Client{
+func NewClient(apiToken string, logger *slog.Logger, opts ...Option) *Client {
+	c := &Client{
 		httpClient: &http.Client{
 			Timeout: defaultTimeout,
 		},
-		baseURL:  "https://api.cloudflare.com/client/v4",
-		apiToken: apiToken,
-		logger:   logger,
+		baseURL:    DefaultBaseURL,
+		apiToken:   apiToken,
+		logger:     logger,
+		instrument: func(context.Context, RequestInfo) {},
+		retry:      DefaultRetryPolicy(),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-// GetEntrypointRuleset gets the entrypoint ruleset for the given zone and phase.
-func (c *Client) GetEntrypointRuleset(ctx context.Context, zoneID, phase string) (*types.CloudflareRuleset, error) {
-	url := fmt.Sprintf("%s/zones/%s/rulesets/phases/%s/entrypoint", c.baseURL, zoneID, phase)
+// ListRulesets returns all rulesets of the given zone, without their rules.
+func (c *Client) ListRulesets(ctx context.Context, zoneID string) ([]types.CloudflareRuleset, error) {
+	url := fmt.Sprintf("%s/zones/%s/rulesets", c.baseURL, zoneID)
 
-	resp, err := c.makeRequest(ctx, http.MethodGet, url, nil)
+	rulesets, err := list[types.CloudflareRuleset](ctx, c, OperationListRulesets, url, listPageSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get entrypoint ruleset: %w", err)
-	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			c.logger.Warn("Failed to close response body", "error", closeErr)
-		}
-	}()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, ErrEntrypointNotFound
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to list rulesets: %w", err)
 	}
 
-	var apiResp types.CloudflareAPIResponse
-	if decodeErr := json.NewDecoder(resp.Body).Decode(&apiResp); decodeErr != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
-	}
+	return rulesets, nil
+}
 
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %v", apiResp.Errors)
-	}
+// GetEntrypointRuleset gets the entrypoint ruleset for the given zone and phase.
+func (c *Client) GetEntrypointRuleset(ctx context.Context, zoneID, phase string) (*types.CloudflareRuleset, error) {
+	url := fmt.Sprintf("%s/zones/%s/rulesets/phases/%s/entrypoint", c.baseURL, zoneID, phase)
 
-	var ruleset types.CloudflareRuleset
-	if resultBytes, marshalErr := json.Marshal(apiResp.Result); marshalErr != nil {
-		return nil, fmt.Errorf("failed to marshal result: %w", marshalErr)
-	} else if unmarshalErr := json.Unmarshal(resultBytes, &ruleset); unmarshalErr != nil {
-		return nil, fmt.Errorf("failed to unmarshal ruleset: %w", unmarshalErr)
+	ruleset, err := do[types.CloudflareRuleset](ctx, c, OperationGetEntrypointRuleset, http.MethodGet, url, nil)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrEntrypointNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get entrypoint ruleset: %w", err)
 	}
 
 	return &ruleset, nil
@@ -99,35 +161,10 @@ func (c *Client) CreateEntrypointRuleset(ctx context.Context, zoneID, phase stri
 		"description": fmt.Sprintf("Managed by cf-switch for %s phase", phase),
 	}
 
-	resp, err := c.makeRequest(ctx, http.MethodPost, url, payload)
+	ruleset, err := do[types.CloudflareRuleset](ctx, c, OperationCreateEntrypointRuleset, http.MethodPost, url, payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create entrypoint ruleset: %w", err)
 	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			c.logger.Warn("Failed to close response body", "error", closeErr)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	var apiResp types.CloudflareAPIResponse
-	if decodeErr := json.NewDecoder(resp.Body).Decode(&apiResp); decodeErr != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %v", apiResp.Errors)
-	}
-
-	var ruleset types.CloudflareRuleset
-	if resultBytes, marshalErr := json.Marshal(apiResp.Result); marshalErr != nil {
-		return nil, fmt.Errorf("failed to marshal result: %w", marshalErr)
-	} else if unmarshalErr := json.Unmarshal(resultBytes, &ruleset); unmarshalErr != nil {
-		return nil, fmt.Errorf("failed to unmarshal ruleset: %w", unmarshalErr)
-	}
 
 	return &ruleset, nil
 }
@@ -140,35 +177,10 @@ func (c *Client) AddRule(
 ) (*types.CloudflareRule, error) {
 	url := fmt.Sprintf("%s/zones/%s/rulesets/%s/rules", c.baseURL, zoneID, rulesetID)
 
-	resp, err := c.makeRequest(ctx, http.MethodPost, url, rule)
+	createdRule, err := do[types.CloudflareRule](ctx, c, OperationAddRule, http.MethodPost, url, rule)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add rule: %w", err)
 	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			c.logger.Warn("Failed to close response body", "error", closeErr)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	var apiResp types.CloudflareAPIResponse
-	if decodeErr := json.NewDecoder(resp.Body).Decode(&apiResp); decodeErr != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %v", apiResp.Errors)
-	}
-
-	var createdRule types.CloudflareRule
-	if resultBytes, marshalErr := json.Marshal(apiResp.Result); marshalErr != nil {
-		return nil, fmt.Errorf("failed to marshal result: %w", marshalErr)
-	} else if unmarshalErr := json.Unmarshal(resultBytes, &createdRule); unmarshalErr != nil {
-		return nil, fmt.Errorf("failed to unmarshal rule: %w", unmarshalErr)
-	}
 
 	c.logger.DebugContext(ctx, "Cloudflare API response for created rule",
 		"rule_id", createdRule.ID,
@@ -186,49 +198,10 @@ func (c *Client) UpdateRule(
 ) (*types.CloudflareRule, error) {
 	url := fmt.Sprintf("%s/zones/%s/rulesets/%s/rules/%s", c.baseURL, zoneID, rulesetID, ruleID)
 
-	resp, err := c.makeRequest(ctx, http.MethodPatch, url, updates)
+	updatedRule, err := do[types.CloudflareRule](ctx, c, OperationUpdateRule, http.MethodPatch, url, updates)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update rule: %w", err)
 	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			c.logger.Warn("Failed to close response body", "error", closeErr)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		// Read response body for error details
-		body, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return nil, fmt.Errorf(
-				"unexpected status code: %d (failed to read error response: %w)",
-				resp.StatusCode,
-				readErr,
-			)
-		}
-		c.logger.ErrorContext(ctx, "Cloudflare API error response",
-			"status_code", resp.StatusCode,
-			"response_body", string(body),
-			"url", url,
-			"updates", updates)
-		return nil, fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, string(body))
-	}
-
-	var apiResp types.CloudflareAPIResponse
-	if decodeErr := json.NewDecoder(resp.Body).Decode(&apiResp); decodeErr != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %v", apiResp.Errors)
-	}
-
-	var updatedRule types.CloudflareRule
-	if resultBytes, marshalErr := json.Marshal(apiResp.Result); marshalErr != nil {
-		return nil, fmt.Errorf("failed to marshal result: %w", marshalErr)
-	} else if unmarshalErr := json.Unmarshal(resultBytes, &updatedRule); unmarshalErr != nil {
-		return nil, fmt.Errorf("failed to unmarshal rule: %w", unmarshalErr)
-	}
 
 	return &updatedRule, nil
 }
@@ -243,80 +216,228 @@ func FindRuleByDescription(ruleset *types.CloudflareRuleset, description string)
 	return nil
 }
 
-// makeRequest makes an HTTP request to the Cloudflare API with retry logic.
-//
-//nolint:gocognit // Complex retry logic with rate limiting requires multiple conditions
-func (c *Client) makeRequest(ctx context.Context, method, url string, payload interface{}) (*http.Response, error) {
-	var body io.Reader
+// makeRequest makes an HTTP request to the Cloudflare API, retrying according to the client's RetryPolicy.
+// The request is rebuilt for every attempt so that its body can be replayed.
+func (c *Client) makeRequest(
+	ctx context.Context,
+	operation, method, url string,
+	payload interface{},
+) (*http.Response, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "cloudflare."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("cloudflare.operation", operation),
+			semconv.HTTPRequestMethodKey.String(method),
+		))
+	resp, err := c.doRequest(ctx, span, operation, method, url, payload)
+	endSpan(span, resp, err)
+	return resp, err
+}
+
+// doRequest implements makeRequest within its span, guarded by the circuit breaker.
+// Local failures, such as an invalid payload, are returned before the breaker is consulted.
+func (c *Client) doRequest(
+	ctx context.Context,
+	span trace.Span,
+	operation, method, url string,
+	payload interface{},
+) (*http.Response, error) {
+	var body []byte
 	if payload != nil {
-		jsonData, err := json.Marshal(payload)
-		if err != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
 			return nil, fmt.Errorf("failed to marshal payload: %w", err)
 		}
-		body = bytes.NewReader(jsonData)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	// Add request ID for logging.
+	reqID := fmt.Sprintf("cf-%d", time.Now().UnixNano())
+	span.SetAttributes(attribute.String("cloudflare.request_id", reqID))
+
+	req, err := c.newRequest(ctx, method, url, body, reqID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	// Add request ID for logging.
-	reqID := fmt.Sprintf("cf-%d", time.Now().UnixNano())
-	req.Header.Set("X-Request-ID", reqID)
+	if c.breaker == nil {
+		return c.sendWithRetries(ctx, span, operation, req, body)
+	}
+	if err = c.breaker.allow(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := c.sendWithRetries(ctx, span, operation, req, body)
+	c.breaker.record(ctx, resp, err)
+	return resp, err
+}
 
+// sendWithRetries sends req, retrying according to the client's RetryPolicy.
+// Every retry sends a copy of req with a fresh reader over body.
+func (c *Client) sendWithRetries(
+	ctx context.Context,
+	span trace.Span,
+	operation string,
+	req *http.Request,
+	body []byte,
+) (*http.Response, error) {
+	reqID := req.Header.Get("X-Request-ID")
 	start := time.Now()
-
-	// Simple retry logic for rate limiting.
-	var resp *http.Response
-	for attempt := range maxRetries {
-		resp, err = c.httpClient.Do(req)
-		if err != nil {
-			if attempt == maxRetries-1 {
-				return nil, fmt.Errorf("request failed after %d attempts: %w", maxRetries, err)
+	maxAttempts := max(c.retry.MaxAttempts, 1)
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			req = req.Clone(ctx)
+			if body != nil {
+				req.Body = io.NopCloser(bytes.NewReader(body))
 			}
-			time.Sleep(time.Duration(attempt+1) * time.Second)
-			continue
 		}
 
-		// Handle rate limiting.
-		//nolint:nestif // Rate limiting logic requires nested conditions for proper retry handling
-		if resp.StatusCode == http.StatusTooManyRequests {
-			retryAfter := resp.Header.Get("Retry-After")
-			if retryAfter != "" {
-				if seconds, parseErr := strconv.Atoi(retryAfter); parseErr == nil {
-					if seconds <= maxRetryWaitSeconds { // Don't wait more than 60 seconds.
-						if closeErr := resp.Body.Close(); closeErr != nil {
-							c.logger.WarnContext(ctx, "Failed to close response body", "error", closeErr)
-						}
-						c.logger.WarnContext(ctx, "Rate limited, retrying",
-							"attempt", attempt+1,
-							"retry_after", seconds,
-							"request_id", reqID)
-						time.Sleep(time.Duration(seconds) * time.Second)
-						continue
-					}
+		resp, err = c.attempt(ctx, operation, attempt, req)
+		reason := retryReason(ctx, resp, err)
+		if reason == "" || attempt == maxAttempts {
+			break
+		}
+
+		wait := c.retry.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if retryAfter > c.retry.MaxRetryAfter {
+					// Waiting would take too long; let the caller handle the response.
+					break
 				}
+				wait = retryAfter
 			}
-			if closeErr := resp.Body.Close(); closeErr != nil {
-				c.logger.WarnContext(ctx, "Failed to close response body", "error", closeErr)
-			}
-			return nil, errors.New("rate limited and retry would take too long")
+			c.closeBody(ctx, resp)
+		}
+
+		c.logger.WarnContext(ctx, "Cloudflare API request failed, retrying",
+			"attempt", attempt,
+			"reason", reason,
+			"wait", wait,
+			"request_id", reqID,
+			"error", err)
+		addRetryEvent(span, attempt, wait, reason)
+		if sleepErr := sleep(ctx, wait); sleepErr != nil {
+			return nil, fmt.Errorf("request canceled while waiting to retry: %w", sleepErr)
 		}
+	}
 
-		break
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 
 	duration := time.Since(start)
 	c.logger.DebugContext(ctx, "Cloudflare API request completed",
-		"method", method,
-		"url", url,
+		"method", req.Method,
+		"url", req.URL.String(),
 		"status", resp.StatusCode,
 		"duration_ms", duration.Milliseconds(),
 		"request_id", reqID)
 
 	return resp, nil
 }
+
+// newRequest creates an authenticated API request with body.
+func (c *Client) newRequest(ctx context.Context, method, url string, body []byte, reqID string) (*http.Request, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.apiToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", reqID)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, nil
+}
+
+// closeBody closes the body of a response that is discarded.
+func (c *Client) closeBody(ctx context.Context, resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		c.logger.WarnContext(ctx, "Failed to close response body", "error", err)
+	}
+}
+
+// attempt sends a single request and reports it to the instrumentation hook and the current span.
+func (c *Client) attempt(ctx context.Context, operation string, attempt int, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	info := RequestInfo{
+		Operation: operation,
+		Method:    req.Method,
+		Duration:  time.Since(start),
+		Err:       err,
+	}
+
+	if resp != nil {
+		info.StatusCode = resp.StatusCode
+		if resp.StatusCode >= http.StatusBadRequest {
+			info.ErrorCodes = c.peekErrorCodes(ctx, resp)
+		}
+	}
+
+	attrs := []attribute.KeyValue{attribute.Int("attempt", attempt)}
+	if err != nil {
+		attrs = append(attrs, attribute.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(info.StatusCode))
+	}
+	trace.SpanFromContext(ctx).AddEvent("attempt", trace.WithAttributes(attrs...))
+
+	c.instrument(ctx, info)
+	return resp, err
+}
+
+// addRetryEvent records on span that the given attempt is retried after wait.
+func addRetryEvent(span trace.Span, attempt int, wait time.Duration, reason string) {
+	span.AddEvent("retry", trace.WithAttributes(
+		attribute.Int("attempt", attempt),
+		attribute.String("reason", reason),
+		attribute.Float64("wait_seconds", wait.Seconds()),
+	))
+}
+
+// endSpan records the outcome of a request on span and ends it.
+func endSpan(span trace.Span, resp *http.Response, err error) {
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case resp.StatusCode >= http.StatusBadRequest:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	default:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+	span.End()
+}
+
+// peekErrorCodes reads the Cloudflare error codes from an error response and restores its body.
+func (c *Client) peekErrorCodes(ctx context.Context, resp *http.Response) []int {
+	body, err := io.ReadAll(resp.Body)
+	if closeErr := resp.Body.Close(); closeErr != nil {
+		c.logger.WarnContext(ctx, "Failed to close response body", "error", closeErr)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var apiResp apiResponse[json.RawMessage]
+	if json.Unmarshal(body, &apiResp) != nil {
+		return nil
+	}
+	codes := make([]int, 0, len(apiResp.Errors))
+	for _, apiErr := range apiResp.Errors {
+		codes = append(codes, apiErr.Code)
+	}
+	return codes
+}
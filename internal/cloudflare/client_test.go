@@ -8,9 +8,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/meyeringh/cf-switch/pkg/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestNewClient(t *testing.T) {
@@ -369,3 +375,121 @@ func TestFindRuleByDescription(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_Instrumentation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"success": true, "result": {"id": "test-ruleset", "rules": []}}`))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"success": false, "errors": [{"code": 10000, "message": "Authentication error"}]}`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	var requests []RequestInfo
+	client := NewClient("test-token", logger, WithInstrumentation(func(_ context.Context, info RequestInfo) {
+		requests = append(requests, info)
+	}))
+	client.baseURL = server.URL
+
+	if _, err := client.GetEntrypointRuleset(context.Background(), "test-zone", "test-phase"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := client.UpdateRule(context.Background(), "test-zone", "test-ruleset", "test-rule", map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "Authentication error") {
+		t.Errorf("expected the Cloudflare error message to still be returned, got %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 instrumented requests, got %d", len(requests))
+	}
+
+	get := requests[0]
+	if get.Operation != OperationGetEntrypointRuleset || get.Method != http.MethodGet ||
+		get.StatusCode != http.StatusOK || len(get.ErrorCodes) != 0 || get.Err != nil {
+		t.Errorf("unexpected request info: %+v", get)
+	}
+
+	update := requests[1]
+	if update.Operation != OperationUpdateRule || update.Method != http.MethodPatch ||
+		update.StatusCode != http.StatusForbidden {
+		t.Errorf("unexpected request info: %+v", update)
+	}
+	if len(update.ErrorCodes) != 1 || update.ErrorCodes[0] != 10000 {
+		t.Errorf("expected error code 10000, got %v", update.ErrorCodes)
+	}
+	if update.Duration <= 0 {
+		t.Errorf("expected a positive duration, got %v", update.Duration)
+	}
+}
+
+func TestClient_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	var attempts int
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		traceparent = r.Header.Get("Traceparent")
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"success": true, "result": {"id": "test-ruleset", "rules": []}}`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	client := NewClient("test-token", logger)
+	client.baseURL = server.URL
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	if _, err := client.GetEntrypointRuleset(ctx, "test-zone", "test-phase"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "cloudflare."+OperationGetEntrypointRuleset {
+		t.Errorf("unexpected span name %q", span.Name())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the Cloudflare span to be a child of the caller's span")
+	}
+
+	var events []string
+	for _, event := range span.Events() {
+		events = append(events, event.Name)
+	}
+	if strings.Join(events, ",") != "attempt,retry,attempt" {
+		t.Errorf("expected attempt, retry, attempt events, got %v", events)
+	}
+
+	if !strings.Contains(traceparent, span.SpanContext().TraceID().String()) {
+		t.Errorf("expected traceparent with trace ID %s, got %q", span.SpanContext().TraceID(), traceparent)
+	}
+}
@@ -0,0 +1,88 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// Sentinel errors matched by APIError via errors.Is.
+var (
+	// ErrAuthentication indicates a missing, invalid, or insufficiently privileged API token.
+	ErrAuthentication = errors.New("cloudflare authentication failed")
+	// ErrNotFound indicates that the zone, ruleset, or rule does not exist.
+	ErrNotFound = errors.New("cloudflare resource not found")
+	// ErrInvalidRequest indicates that Cloudflare rejected the request, e.g. an invalid rule expression.
+	ErrInvalidRequest = errors.New("cloudflare rejected the request")
+	// ErrRateLimited indicates that the API token exceeded the Cloudflare rate limit.
+	ErrRateLimited = errors.New("cloudflare rate limit exceeded")
+	// ErrUnavailable indicates a Cloudflare server error.
+	ErrUnavailable = errors.New("cloudflare API unavailable")
+)
+
+// APIError is returned by Client methods for unsuccessful Cloudflare API responses.
+type APIError struct {
+	// Operation names the client method, e.g. OperationUpdateRule.
+	Operation  string
+	StatusCode int
+	// Errors are the Cloudflare error codes and messages of the response, if any.
+	Errors []types.CloudflareAPIError
+	// RequestID is the X-Request-ID sent with the request.
+	RequestID string
+	// RayID is the Cloudflare ray ID of the response, useful for Cloudflare support.
+	RayID string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cloudflare API error: %s: %d %s", e.Operation, e.StatusCode, http.StatusText(e.StatusCode))
+	for i, apiErr := range e.Errors {
+		if i == 0 {
+			b.WriteString(":")
+		} else {
+			b.WriteString(";")
+		}
+		fmt.Fprintf(&b, " %d %s", apiErr.Code, apiErr.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+// Unwrap returns the sentinel error matching the status code, if any.
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrAuthentication
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrUnavailable
+	case e.StatusCode >= http.StatusBadRequest:
+		return ErrInvalidRequest
+	default:
+		return nil
+	}
+}
+
+// Retryable reports whether the request may succeed if repeated later.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		(e.StatusCode >= http.StatusInternalServerError && e.StatusCode != http.StatusNotImplemented)
+}
+
+// Codes returns the Cloudflare error codes of the response.
+func (e *APIError) Codes() []int {
+	codes := make([]int, 0, len(e.Errors))
+	for _, apiErr := range e.Errors {
+		codes = append(codes, apiErr.Code)
+	}
+	return codes
+}
@@ -0,0 +1,152 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		statusCode    int
+		wantSentinel  error
+		wantRetryable bool
+	}{
+		{statusCode: http.StatusBadRequest, wantSentinel: ErrInvalidRequest},
+		{statusCode: http.StatusUnauthorized, wantSentinel: ErrAuthentication},
+		{statusCode: http.StatusForbidden, wantSentinel: ErrAuthentication},
+		{statusCode: http.StatusNotFound, wantSentinel: ErrNotFound},
+		{statusCode: http.StatusConflict, wantSentinel: ErrInvalidRequest},
+		{statusCode: http.StatusTooManyRequests, wantSentinel: ErrRateLimited, wantRetryable: true},
+		{statusCode: http.StatusNotImplemented, wantSentinel: ErrUnavailable},
+		{statusCode: http.StatusServiceUnavailable, wantSentinel: ErrUnavailable, wantRetryable: true},
+		{statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			err := &APIError{StatusCode: tt.statusCode}
+			if tt.wantSentinel != nil && !errors.Is(err, tt.wantSentinel) {
+				t.Errorf("expected %v to match %v", err, tt.wantSentinel)
+			}
+			if tt.wantSentinel == nil && err.Unwrap() != nil {
+				t.Errorf("expected no sentinel, got %v", err.Unwrap())
+			}
+			if err.Retryable() != tt.wantRetryable {
+				t.Errorf("expected Retryable() = %v", tt.wantRetryable)
+			}
+		})
+	}
+
+	err := &APIError{
+		Operation:  OperationUpdateRule,
+		StatusCode: http.StatusBadRequest,
+		Errors: []types.CloudflareAPIError{
+			{Code: 20120, Message: "invalid expression"},
+			{Code: 20121, Message: "invalid action"},
+		},
+		RequestID: "cf-1",
+	}
+	want := "cloudflare API error: update_rule: 400 Bad Request: 20120 invalid expression; 20121 invalid action (request cf-1)"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+	if codes := err.Codes(); len(codes) != 2 || codes[0] != 20120 || codes[1] != 20121 {
+		t.Errorf("unexpected codes %v", codes)
+	}
+}
+
+func TestClient_APIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		response   string
+		call       func(*Client) error
+		wantStatus int
+		wantCodes  []int
+	}{
+		{
+			name:       "forbidden",
+			statusCode: http.StatusForbidden,
+			response:   `{"success": false, "errors": [{"code": 10000, "message": "Authentication error"}]}`,
+			call: func(c *Client) error {
+				_, err := c.CreateEntrypointRuleset(context.Background(), "zone", "phase")
+				return err
+			},
+			wantStatus: http.StatusForbidden,
+			wantCodes:  []int{10000},
+		},
+		{
+			name:       "unsuccessful 200",
+			statusCode: http.StatusOK,
+			response:   `{"success": false, "errors": [{"code": 20120, "message": "invalid expression"}]}`,
+			call: func(c *Client) error {
+				_, err := c.AddRule(context.Background(), "zone", "ruleset", types.CloudflareRule{})
+				return err
+			},
+			wantStatus: http.StatusOK,
+			wantCodes:  []int{20120},
+		},
+		{
+			name:       "body without envelope",
+			statusCode: http.StatusBadRequest,
+			response:   `bad request`,
+			call: func(c *Client) error {
+				_, err := c.UpdateRule(context.Background(), "zone", "ruleset", "rule", map[string]interface{}{})
+				return err
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCodes:  []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Cf-Ray", "ray-1")
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			err := tt.call(newRetryTestClient(server.URL))
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected *APIError, got %v", err)
+			}
+			if apiErr.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, apiErr.StatusCode)
+			}
+			if codes := apiErr.Codes(); len(codes) != len(tt.wantCodes) || (len(codes) > 0 && codes[0] != tt.wantCodes[0]) {
+				t.Errorf("expected codes %v, got %v", tt.wantCodes, codes)
+			}
+			if !strings.HasPrefix(apiErr.RequestID, "cf-") || apiErr.RayID != "ray-1" {
+				t.Errorf("expected request and ray IDs, got %q and %q", apiErr.RequestID, apiErr.RayID)
+			}
+		})
+	}
+}
+
+func TestClient_EntrypointNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"success": false, "errors": [{"code": 10003, "message": "not found"}]}`))
+	}))
+	defer server.Close()
+
+	_, err := newRetryTestClient(server.URL).GetEntrypointRuleset(context.Background(), "zone", "phase")
+	if !errors.Is(err, ErrEntrypointNotFound) {
+		t.Errorf("expected ErrEntrypointNotFound, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a 404 *APIError, got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// Operations used by CheckPermissions.
+const (
+	OperationVerifyToken = "verify_token"
+	OperationGetToken    = "get_token"
+	OperationGetZone     = "get_zone"
+)
+
+const (
+	// tokenStatusActive is the status of a usable API token.
+	tokenStatusActive = "active"
+	// Name of the permission group that allows editing zone rulesets, shown as "Zone WAF Edit" in the dashboard.
+	permissionGroupZoneWAFWrite = "Zone WAF Write"
+	// Prefixes of token policy resource keys.
+	resourceAccountPrefix = "com.cloudflare.api.account."
+	resourceZonePrefix    = "com.cloudflare.api.account.zone."
+)
+
+// CheckStatus is the outcome of a PermissionCheck.
+type CheckStatus string
+
+// Permission check outcomes.
+const (
+	// CheckOK means the token has the permission.
+	CheckOK CheckStatus = "ok"
+	// CheckMissing means Cloudflare denied the request for lack of permission.
+	CheckMissing CheckStatus = "missing"
+	// CheckError means the permission could not be checked, e.g. because Cloudflare was unreachable.
+	CheckError CheckStatus = "error"
+	// CheckUnverified means the check needs a permission the token does not have to have.
+	// It does not fail the report.
+	CheckUnverified CheckStatus = "unverified"
+)
+
+// PermissionCheck is the result of checking one permission of the API token.
+type PermissionCheck struct {
+	Name string
+	// Permission is the Cloudflare API token permission the check requires.
+	Permission string
+	Status     CheckStatus
+	Detail     string
+}
+
+// PermissionReport lists the results of CheckPermissions.
+type PermissionReport []PermissionCheck
+
+// OK reports whether every check passed or could not be verified.
+func (r PermissionReport) OK() bool {
+	for _, check := range r {
+		if check.Status != CheckOK && check.Status != CheckUnverified {
+			return false
+		}
+	}
+	return true
+}
+
+// Unverified reports whether any check could not be verified.
+func (r PermissionReport) Unverified() bool {
+	for _, check := range r {
+		if check.Status == CheckUnverified {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing reports whether Cloudflare denied any check, as opposed to checks that failed with errors.
+func (r PermissionReport) Missing() bool {
+	for _, check := range r {
+		if check.Status == CheckMissing {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyToken returns the status of the client's API token. With an accountID the token is
+// verified as a token owned by that account, otherwise as a user token.
+func (c *Client) VerifyToken(ctx context.Context, accountID string) (*types.CloudflareTokenStatus, error) {
+	url := c.tokensURL(accountID) + "/verify"
+
+	status, err := do[types.CloudflareTokenStatus](ctx, c, OperationVerifyToken, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify token: %w", err)
+	}
+	return &status, nil
+}
+
+// GetToken returns the API token with the given ID and its policies, owned by accountID or the user
+// if accountID is empty. Reading a token requires the "API Tokens Read" permission.
+func (c *Client) GetToken(ctx context.Context, accountID, tokenID string) (*types.CloudflareToken, error) {
+	url := c.tokensURL(accountID) + "/" + tokenID
+
+	token, err := do[types.CloudflareToken](ctx, c, OperationGetToken, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	return &token, nil
+}
+
+// tokensURL returns the URL of the API tokens of accountID, or of the user if accountID is empty.
+func (c *Client) tokensURL(accountID string) string {
+	if accountID == "" {
+		return c.baseURL + "/user/tokens"
+	}
+	return c.baseURL + "/accounts/" + accountID + "/tokens"
+}
+
+// GetZone returns the zone with the given ID.
+func (c *Client) GetZone(ctx context.Context, zoneID string) (*types.CloudflareZone, error) {
+	url := fmt.Sprintf("%s/zones/%s", c.baseURL, zoneID)
+
+	zone, err := do[types.CloudflareZone](ctx, c, OperationGetZone, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+	return &zone, nil
+}
+
+// CheckPermissions checks that the API token can manage the custom firewall rule of zoneID.
+// It only reads: edit access is checked against the policies of the token. Tokens without the
+// "API Tokens Read" permission cannot read their policies, so that check is reported as unverified.
+func (c *Client) CheckPermissions(ctx context.Context, zoneID string) PermissionReport {
+	report := make(PermissionReport, 0, 4) //nolint:mnd // One entry per check below.
+
+	zone, zoneErr := c.GetZone(ctx, zoneID)
+
+	token, accountID, err := c.verifyOwnToken(ctx, zone)
+	check := newPermissionCheck("API token is active", "a valid API token", err)
+	if err == nil {
+		check.Detail = "token " + token.ID + " is " + token.Status
+		if token.Status != tokenStatusActive {
+			check.Status = CheckMissing
+		}
+	}
+	report = append(report, check)
+
+	check = newPermissionCheck("Zone read", "Zone > Zone > Read", zoneErr)
+	switch {
+	case zoneErr == nil:
+		check.Detail = "zone " + zone.Name + " is " + zone.Status
+	case errors.Is(zoneErr, ErrNotFound):
+		check.Status = CheckMissing
+		check.Detail = "zone " + zoneID + " does not exist or is not accessible"
+	}
+	report = append(report, check)
+
+	_, err = c.GetEntrypointRuleset(ctx, zoneID, types.HTTPRequestFirewallCustomPhase)
+	check = newPermissionCheck("Ruleset read", "Zone > Zone WAF > Read", err)
+	if errors.Is(err, ErrEntrypointNotFound) {
+		check.Status = CheckOK
+		check.Detail = "no custom rules yet; cf-switch will create the entrypoint ruleset"
+	}
+	report = append(report, check)
+
+	return append(report, c.checkRulesetEdit(ctx, token, accountID, zone))
+}
+
+// verifyOwnToken verifies the client's API token as a user token, or as a token owned by the
+// account of zone. It returns the owning account ID, which is empty for user tokens.
+func (c *Client) verifyOwnToken(
+	ctx context.Context,
+	zone *types.CloudflareZone,
+) (*types.CloudflareTokenStatus, string, error) {
+	token, err := c.VerifyToken(ctx, "")
+	if err == nil || !errors.Is(err, ErrAuthentication) || zone == nil || zone.Account.ID == "" {
+		return token, "", err
+	}
+
+	// Account-owned tokens are unknown to the user endpoint.
+	accountToken, accountErr := c.VerifyToken(ctx, zone.Account.ID)
+	if accountErr != nil {
+		return nil, "", err
+	}
+	return accountToken, zone.Account.ID, nil
+}
+
+// checkRulesetEdit checks whether the policies of token, owned by accountID or the user,
+// allow editing the rulesets of zone.
+func (c *Client) checkRulesetEdit(
+	ctx context.Context,
+	status *types.CloudflareTokenStatus,
+	accountID string,
+	zone *types.CloudflareZone,
+) PermissionCheck {
+	check := PermissionCheck{Name: "Ruleset edit", Permission: "Zone > Zone WAF > Edit", Status: CheckError}
+	if status == nil || zone == nil {
+		check.Detail = "requires the token and zone checks to pass"
+		return check
+	}
+
+	token, err := c.GetToken(ctx, accountID, status.ID)
+	switch {
+	case errors.Is(err, ErrAuthentication):
+		// Least-privilege tokens cannot read their own policies; missing edit access shows on the first change.
+		check.Status = CheckUnverified
+		check.Detail = "reading the token's policies requires the API Tokens Read permission"
+		return check
+	case err != nil:
+		check.Detail = err.Error()
+		return check
+	}
+
+	allowed, denied := false, false
+	for _, policy := range token.Policies {
+		if !grantsRulesetEdit(policy) || !coversZone(policy.Resources, zone.ID, zone.Account.ID) {
+			continue
+		}
+		switch policy.Effect {
+		case "allow":
+			allowed = true
+		case "deny":
+			denied = true
+		}
+	}
+
+	if !allowed || denied {
+		check.Status = CheckMissing
+		check.Detail = "no token policy grants " + permissionGroupZoneWAFWrite + " on zone " + zone.Name
+		return check
+	}
+	check.Status = CheckOK
+	return check
+}
+
+// grantsRulesetEdit reports whether policy includes the permission group for editing zone rulesets.
+func grantsRulesetEdit(policy types.CloudflareTokenPolicy) bool {
+	for _, group := range policy.PermissionGroups {
+		if group.Name == permissionGroupZoneWAFWrite {
+			return true
+		}
+	}
+	return false
+}
+
+// coversZone reports whether token policy resources include the zone, directly, through a wildcard,
+// or through its account.
+func coversZone(resources map[string]json.RawMessage, zoneID, accountID string) bool {
+	for key, value := range resources {
+		switch key {
+		case resourceZonePrefix + zoneID, resourceZonePrefix + "*":
+			return true
+		case resourceAccountPrefix + accountID, resourceAccountPrefix + "*":
+			// "*" covers every zone of the account; otherwise the value lists the covered zones.
+			var all string
+			if json.Unmarshal(value, &all) == nil && all == "*" {
+				return true
+			}
+			var nested map[string]json.RawMessage
+			if json.Unmarshal(value, &nested) == nil && coversZone(nested, zoneID, accountID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newPermissionCheck classifies the error of the request made for a check.
+func newPermissionCheck(name, permission string, err error) PermissionCheck {
+	check := PermissionCheck{Name: name, Permission: permission, Status: CheckOK}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrAuthentication):
+		check.Status = CheckMissing
+		check.Detail = err.Error()
+	default:
+		check.Status = CheckError
+		check.Detail = err.Error()
+	}
+	return check
+}
@@ -0,0 +1,198 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package cloudflare
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Token policies returned by permissionTestServer.
+const (
+	zonePolicy = `[{"effect": "allow", "permission_groups": [{"name": "Zone WAF Write"}],
+		"resources": {"com.cloudflare.api.account.zone.zone": "*"}}]`
+	accountPolicy = `[{"effect": "allow", "permission_groups": [{"name": "Zone WAF Write"}],
+		"resources": {"com.cloudflare.api.account.acct": {"com.cloudflare.api.account.zone.*": "*"}}}]`
+	readOnlyPolicy = `[{"effect": "allow", "permission_groups": [{"name": "Zone WAF Read"}],
+		"resources": {"com.cloudflare.api.account.zone.zone": "*"}}]`
+	deniedPolicy = `[{"effect": "allow", "permission_groups": [{"name": "Zone WAF Write"}],
+		"resources": {"com.cloudflare.api.account.zone.*": "*"}},
+		{"effect": "deny", "permission_groups": [{"name": "Zone WAF Write"}],
+		"resources": {"com.cloudflare.api.account.zone.zone": "*"}}]`
+	otherZonePolicy = `[{"effect": "allow", "permission_groups": [{"name": "Zone WAF Write"}],
+		"resources": {"com.cloudflare.api.account.zone.other": "*"}}]`
+)
+
+// permissionTestServer answers the permission check requests with the status configured for their path.
+// The token has the given policies.
+func permissionTestServer(t *testing.T, statuses map[string]int, policies string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("expected only reads, got %s %s", r.Method, r.URL.Path)
+		}
+		key := r.Method + " " + r.URL.Path
+		status, ok := statuses[key]
+		if !ok {
+			t.Errorf("unexpected request %s", key)
+			status = http.StatusInternalServerError
+		}
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte(`{"success": false, "errors": [{"code": 10000, "message": "error"}]}`))
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/verify"):
+			w.Write([]byte(`{"success": true, "result": {"id": "token-1", "status": "active"}}`))
+		case strings.Contains(r.URL.Path, "/tokens/"):
+			w.Write([]byte(`{"success": true, "result": {"id": "token-1", "status": "active", "policies": ` + policies + `}}`))
+		case r.URL.Path == "/zones/zone":
+			w.Write([]byte(`{"success": true, "result": {"id": "zone", "name": "example.com", "status": "active",
+				"account": {"id": "acct"}}}`))
+		default:
+			w.Write([]byte(`{"success": true, "result": {"id": "ruleset", "rules": []}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_CheckPermissions(t *testing.T) {
+	const (
+		verify        = "GET /user/tokens/verify"
+		token         = "GET /user/tokens/token-1"
+		accountVerify = "GET /accounts/acct/tokens/verify"
+		accountToken  = "GET /accounts/acct/tokens/token-1"
+		zone          = "GET /zones/zone"
+		entrypoint    = "GET /zones/zone/rulesets/phases/http_request_firewall_custom/entrypoint"
+	)
+	readable := map[string]int{verify: http.StatusOK, token: http.StatusOK, zone: http.StatusOK, entrypoint: http.StatusOK}
+
+	tests := []struct {
+		name        string
+		statuses    map[string]int
+		policies    string
+		want        []CheckStatus
+		wantOK      bool
+		wantMissing bool
+	}{
+		{
+			name:     "all permissions",
+			statuses: readable,
+			policies: zonePolicy,
+			want:     []CheckStatus{CheckOK, CheckOK, CheckOK, CheckOK},
+			wantOK:   true,
+		},
+		{
+			name:     "all zones of the account",
+			statuses: readable,
+			policies: accountPolicy,
+			want:     []CheckStatus{CheckOK, CheckOK, CheckOK, CheckOK},
+			wantOK:   true,
+		},
+		{
+			name: "account-owned token",
+			statuses: map[string]int{
+				verify:        http.StatusUnauthorized,
+				accountVerify: http.StatusOK,
+				accountToken:  http.StatusOK,
+				zone:          http.StatusOK,
+				entrypoint:    http.StatusOK,
+			},
+			policies: zonePolicy,
+			want:     []CheckStatus{CheckOK, CheckOK, CheckOK, CheckOK},
+			wantOK:   true,
+		},
+		{
+			name: "no entrypoint ruleset yet",
+			statuses: map[string]int{
+				verify: http.StatusOK, token: http.StatusOK, zone: http.StatusOK, entrypoint: http.StatusNotFound,
+			},
+			policies: zonePolicy,
+			want:     []CheckStatus{CheckOK, CheckOK, CheckOK, CheckOK},
+			wantOK:   true,
+		},
+		{
+			name:        "read only",
+			statuses:    readable,
+			policies:    readOnlyPolicy,
+			want:        []CheckStatus{CheckOK, CheckOK, CheckOK, CheckMissing},
+			wantMissing: true,
+		},
+		{
+			name:        "denied for the zone",
+			statuses:    readable,
+			policies:    deniedPolicy,
+			want:        []CheckStatus{CheckOK, CheckOK, CheckOK, CheckMissing},
+			wantMissing: true,
+		},
+		{
+			name:        "other zone",
+			statuses:    readable,
+			policies:    otherZonePolicy,
+			want:        []CheckStatus{CheckOK, CheckOK, CheckOK, CheckMissing},
+			wantMissing: true,
+		},
+		{
+			name: "policies not readable",
+			statuses: map[string]int{
+				verify: http.StatusOK, token: http.StatusForbidden, zone: http.StatusOK, entrypoint: http.StatusOK,
+			},
+			want:   []CheckStatus{CheckOK, CheckOK, CheckOK, CheckUnverified},
+			wantOK: true,
+		},
+		{
+			name: "policies unavailable",
+			statuses: map[string]int{
+				verify: http.StatusOK, token: http.StatusServiceUnavailable, zone: http.StatusOK, entrypoint: http.StatusOK,
+			},
+			want: []CheckStatus{CheckOK, CheckOK, CheckOK, CheckError},
+		},
+		{
+			name: "invalid token",
+			statuses: map[string]int{
+				verify:     http.StatusUnauthorized,
+				zone:       http.StatusForbidden,
+				entrypoint: http.StatusForbidden,
+			},
+			want:        []CheckStatus{CheckMissing, CheckMissing, CheckMissing, CheckError},
+			wantMissing: true,
+		},
+		{
+			name: "Cloudflare unavailable",
+			statuses: map[string]int{
+				verify:     http.StatusServiceUnavailable,
+				zone:       http.StatusServiceUnavailable,
+				entrypoint: http.StatusServiceUnavailable,
+			},
+			want: []CheckStatus{CheckError, CheckError, CheckError, CheckError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := permissionTestServer(t, tt.statuses, tt.policies)
+			client := newRetryTestClient(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+			report := client.CheckPermissions(context.Background(), "zone")
+
+			if len(report) != len(tt.want) {
+				t.Fatalf("expected %d checks, got %+v", len(tt.want), report)
+			}
+			for i, check := range report {
+				if check.Status != tt.want[i] {
+					t.Errorf("%s: expected %s, got %s (%s)", check.Name, tt.want[i], check.Status, check.Detail)
+				}
+				if check.Permission == "" {
+					t.Errorf("%s: expected the required permission", check.Name)
+				}
+			}
+			if report.OK() != tt.wantOK || report.Missing() != tt.wantMissing {
+				t.Errorf("expected OK() = %v and Missing() = %v", tt.wantOK, tt.wantMissing)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// apiResponse is the Cloudflare API v4 response envelope with a result of type T.
+type apiResponse[T any] struct {
+	Success    bool                       `json:"success"`
+	Errors     []types.CloudflareAPIError `json:"errors"`
+	Result     T                          `json:"result"`
+	ResultInfo *resultInfo                `json:"result_info,omitempty"`
+}
+
+// resultInfo describes the page returned by a paginated endpoint. Endpoints paginate either by
+// page number or, like the rulesets API, by cursor.
+type resultInfo struct {
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+	Count      int    `json:"count"`
+	TotalCount int    `json:"total_count"`
+	TotalPages int    `json:"total_pages"`
+	Cursor     string `json:"cursor,omitempty"`
+}
+
+// do sends a request with retries and decodes the result of a successful response.
+// Unsuccessful responses are returned as *APIError.
+func do[T any](ctx context.Context, c *Client, operation, method, endpoint string, payload interface{}) (T, error) {
+	apiResp, err := doResponse[T](ctx, c, operation, method, endpoint, payload)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return apiResp.Result, nil
+}
+
+// list fetches every page of a paginated GET endpoint, requesting perPage items at a time.
+func list[T any](ctx context.Context, c *Client, operation, endpoint string, perPage int) ([]T, error) {
+	pageURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", endpoint, err)
+	}
+	query := pageURL.Query()
+	query.Set("per_page", strconv.Itoa(perPage))
+	query.Set("page", "1")
+
+	var items []T
+	for page := 1; ; page++ {
+		pageURL.RawQuery = query.Encode()
+
+		apiResp, err := doResponse[[]T](ctx, c, operation, http.MethodGet, pageURL.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, apiResp.Result...)
+
+		info := apiResp.ResultInfo
+		switch {
+		case info == nil || len(apiResp.Result) == 0:
+			return items, nil
+		case info.Cursor != "":
+			query.Del("page")
+			query.Set("cursor", info.Cursor)
+		case page < info.TotalPages:
+			query.Set("page", strconv.Itoa(page+1))
+		default:
+			return items, nil
+		}
+	}
+}
+
+// doResponse sends a request with retries and decodes the response envelope.
+func doResponse[T any](
+	ctx context.Context,
+	c *Client,
+	operation, method, endpoint string,
+	payload interface{},
+) (*apiResponse[T], error) {
+	resp, err := c.makeRequest(ctx, operation, method, endpoint, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeBody(ctx, resp)
+
+	var apiResp apiResponse[T]
+	decodeErr := json.NewDecoder(resp.Body).Decode(&apiResp)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices || !apiResp.Success {
+		apiErr := &APIError{
+			Operation:  operation,
+			StatusCode: resp.StatusCode,
+			RequestID:  resp.Request.Header.Get("X-Request-ID"),
+			RayID:      resp.Header.Get("Cf-Ray"),
+		}
+		if decodeErr == nil {
+			apiErr.Errors = apiResp.Errors
+		}
+		c.logger.DebugContext(ctx, "Cloudflare API error response",
+			"operation", operation,
+			"status_code", resp.StatusCode,
+			"error_codes", apiErr.Codes(),
+			"request_id", apiErr.RequestID,
+			"ray_id", apiErr.RayID)
+		return nil, apiErr
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+	return &apiResp, nil
+}
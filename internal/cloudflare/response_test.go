@@ -0,0 +1,130 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"success": true, "errors": [], "result": {"id": "ruleset-1", "phase": "p", "rules": [
+			{"id": "rule-1", "expression": "true", "enabled": true, "version": "3"}
+		]}}`))
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL)
+	ruleset, err := do[types.CloudflareRuleset](context.Background(), client, "test", http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ruleset.ID != "ruleset-1" || len(ruleset.Rules) != 1 || ruleset.Rules[0].ID != "rule-1" {
+		t.Errorf("unexpected ruleset %+v", ruleset)
+	}
+}
+
+func TestList(t *testing.T) {
+	const totalPages = 3
+
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RawQuery)
+		if r.URL.Query().Get("name") != "example.com" || r.URL.Query().Get("per_page") != "2" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		fmt.Fprintf(w, `{"success": true, "result": [{"id": "zone-%d-a"}, {"id": "zone-%d-b"}],
+			"result_info": {"page": %d, "per_page": 2, "count": 2, "total_count": 6, "total_pages": %d}}`,
+			page, page, page, totalPages)
+	}))
+	defer server.Close()
+
+	type zone struct {
+		ID string `json:"id"`
+	}
+
+	client := newRetryTestClient(server.URL)
+	zones, err := list[zone](context.Background(), client, "list_zones", server.URL+"/zones?name=example.com", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(requested) != totalPages {
+		t.Errorf("expected %d requests, got %v", totalPages, requested)
+	}
+	if len(zones) != 2*totalPages || zones[0].ID != "zone-1-a" || zones[5].ID != "zone-3-b" {
+		t.Errorf("unexpected zones %v", zones)
+	}
+}
+
+func TestList_SinglePage(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.Write([]byte(`{"success": true, "result": [{"id": "a"}]}`))
+	}))
+	defer server.Close()
+
+	items, err := list[struct{ ID string }](context.Background(), newRetryTestClient(server.URL), "test", server.URL, 50)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 1 || len(items) != 1 {
+		t.Errorf("expected 1 request and 1 item, got %d and %d", requests, len(items))
+	}
+}
+
+func TestList_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"success": false, "errors": [{"code": 9109, "message": "Unauthorized"}]}`))
+			return
+		}
+		w.Write([]byte(`{"success": true, "result": [{"id": "a"}], "result_info": {"page": 1, "total_pages": 2}}`))
+	}))
+	defer server.Close()
+
+	_, err := list[struct{ ID string }](context.Background(), newRetryTestClient(server.URL), "test", server.URL, 1)
+	if !errors.Is(err, ErrAuthentication) {
+		t.Errorf("expected ErrAuthentication, got %v", err)
+	}
+}
+
+func TestClient_ListRulesets(t *testing.T) {
+	var cursors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zones/zone/rulesets" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		if cursor == "" {
+			w.Write([]byte(`{"success": true, "result": [{"id": "ruleset-1", "phase": "http_request_firewall_custom"}],
+				"result_info": {"cursor": "next"}}`))
+			return
+		}
+		w.Write([]byte(`{"success": true, "result": [{"id": "ruleset-2", "phase": "http_request_cache_settings"}],
+			"result_info": {}}`))
+	}))
+	defer server.Close()
+
+	rulesets, err := newRetryTestClient(server.URL).ListRulesets(context.Background(), "zone")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cursors) != 2 || cursors[1] != "next" {
+		t.Errorf("expected the second page to be requested by cursor, got %v", cursors)
+	}
+	if len(rulesets) != 2 || rulesets[0].ID != "ruleset-1" || rulesets[1].ID != "ruleset-2" {
+		t.Errorf("unexpected rulesets %+v", rulesets)
+	}
+}
@@ -0,0 +1,136 @@
+package cloudflare
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Default number of attempts per request, including the first.
+	defaultMaxAttempts = 4
+	// Default delay before the first retry, doubled for every further retry.
+	defaultBaseDelay = 500 * time.Millisecond
+	// Default upper bound for the exponential backoff.
+	defaultMaxDelay = 10 * time.Second
+	// Default longest Retry-After delay the client waits for.
+	defaultMaxRetryAfter = 60 * time.Second
+)
+
+// Reasons for retrying a request, recorded on the request span.
+const (
+	retryReasonNetworkError = "network_error"
+	retryReasonRateLimited  = "rate_limited"
+	retryReasonServerError  = "server_error"
+)
+
+// RetryPolicy controls how the client retries failed requests.
+// Network errors, 429 Too Many Requests, and 5xx responses are retried with exponential backoff and jitter;
+// a Retry-After header takes precedence over the backoff.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first; 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled for every further retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff.
+	MaxDelay time.Duration
+	// MaxRetryAfter is the longest Retry-After the client waits for; longer delays return the response.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used by NewClient.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:   defaultMaxAttempts,
+		BaseDelay:     defaultBaseDelay,
+		MaxDelay:      defaultMaxDelay,
+		MaxRetryAfter: defaultMaxRetryAfter,
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// retryReason returns why the result of an attempt should be retried, or "" if it is final.
+func retryReason(ctx context.Context, resp *http.Response, err error) string {
+	switch {
+	case ctx.Err() != nil:
+		return ""
+	case err != nil:
+		if isPermanentError(err) {
+			return ""
+		}
+		return retryReasonNetworkError
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return retryReasonRateLimited
+	case resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented:
+		return retryReasonServerError
+	default:
+		return ""
+	}
+}
+
+// isPermanentError reports whether a transport error cannot be resolved by retrying, e.g. an untrusted certificate.
+func isPermanentError(err error) bool {
+	var (
+		verifyErr *tls.CertificateVerificationError
+		headerErr tls.RecordHeaderError
+	)
+	return errors.As(err, &verifyErr) || errors.As(err, &headerErr)
+}
+
+// backoff returns the jittered delay before the given retry, starting at 1.
+// The delay is drawn uniformly from the upper half of the exponential backoff so that
+// concurrent clients spread out while still backing off.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	//nolint:gosec // Jitter does not need a cryptographically secure source.
+	return half + rand.N(delay-half+1)
+}
+
+// parseRetryAfter parses a Retry-After header in delay-seconds or HTTP-date form.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,207 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries retries quickly so that tests do not wait for the default backoff.
+func fastRetries() Option {
+	return WithRetryPolicy(RetryPolicy{
+		MaxAttempts:   4,
+		BaseDelay:     time.Millisecond,
+		MaxDelay:      5 * time.Millisecond,
+		MaxRetryAfter: time.Minute,
+	})
+}
+
+func newRetryTestClient(serverURL string, opts ...Option) *Client {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	return NewClient("test-token", logger, append([]Option{WithBaseURL(serverURL), fastRetries()}, opts...)...)
+}
+
+func TestClient_RetryReplaysBody(t *testing.T) {
+	var attempts atomic.Int32
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"success": true, "result": {"id": "rule-1", "enabled": true}}`))
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL)
+	rule, err := client.UpdateRule(context.Background(), "zone", "ruleset", "rule-1",
+		map[string]interface{}{"enabled": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !rule.Enabled {
+		t.Error("expected the updated rule")
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != `{"enabled":true}` {
+			t.Errorf("attempt %d: expected the full body, got %q", i+1, body)
+		}
+	}
+}
+
+func TestClient_RetryNetworkError(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) == 1 {
+			// Drop the connection without a response.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("failed to hijack connection: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{"success": true, "result": {"id": "ruleset", "rules": []}}`))
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL)
+	if _, err := client.GetEntrypointRuleset(context.Background(), "zone", "phase"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestClient_RetryStatuses(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		retryAfter   string
+		wantAttempts int32
+	}{
+		{name: "server error", status: http.StatusBadGateway, wantAttempts: 4},
+		{name: "rate limited", status: http.StatusTooManyRequests, retryAfter: "0", wantAttempts: 4},
+		{name: "retry after too long", status: http.StatusTooManyRequests, retryAfter: "3600", wantAttempts: 1},
+		{name: "client error", status: http.StatusBadRequest, wantAttempts: 1},
+		{name: "not implemented", status: http.StatusNotImplemented, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				attempts.Add(1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := newRetryTestClient(server.URL)
+			if _, err := client.GetEntrypointRuleset(context.Background(), "zone", "phase"); err == nil {
+				t.Error("expected error")
+			}
+			if attempts.Load() != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts.Load())
+			}
+		})
+	}
+}
+
+func TestClient_RetryContextCanceled(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.GetEntrypointRuleset(ctx, "zone", "phase")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the wait to be aborted, took %v", elapsed)
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "empty", value: "", wantOK: false},
+		{name: "seconds", value: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "zero", value: "0", want: 0, wantOK: true},
+		{name: "negative", value: "-1", wantOK: false},
+		{name: "HTTP date", value: "Fri, 02 Jan 2026 15:04:35 GMT", want: 30 * time.Second, wantOK: true},
+		{name: "past HTTP date", value: "Fri, 02 Jan 2026 15:00:00 GMT", want: 0, wantOK: true},
+		{name: "invalid", value: "soon", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{retry: 1, want: 100 * time.Millisecond},
+		{retry: 2, want: 200 * time.Millisecond},
+		{retry: 3, want: 400 * time.Millisecond},
+		{retry: 5, want: time.Second},
+		{retry: 100, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			got := policy.backoff(tt.retry)
+			if got < tt.want/2 || got > tt.want {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", tt.retry, got, tt.want/2, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cloudflare
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	// Default limit for dialing and the TLS handshake.
+	defaultConnectTimeout = 10 * time.Second
+	// TCP keep-alive period for API connections.
+	keepAlive = 30 * time.Second
+)
+
+// HTTPConfig configures the HTTP client used for the Cloudflare API.
+type HTTPConfig struct {
+	// ProxyURL routes requests through an HTTP(S) proxy; empty uses HTTPS_PROXY and NO_PROXY.
+	ProxyURL string
+	// CAFile is a PEM bundle trusted in addition to the system roots, e.g. for a TLS-intercepting proxy.
+	CAFile string
+	// Timeout limits each request attempt including reading the response (default 30s).
+	Timeout time.Duration
+	// ConnectTimeout limits dialing and the TLS handshake (default 10s).
+	ConnectTimeout time.Duration
+}
+
+// WithHTTPClient sends requests with httpClient, e.g. one created by NewHTTPClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// NewHTTPClient creates an HTTP client for the Cloudflare API from config.
+func NewHTTPClient(config HTTPConfig) (*http.Client, error) {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.ConnectTimeout <= 0 {
+		config.ConnectTimeout = defaultConnectTimeout
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("default transport is not an *http.Transport")
+	}
+	transport = transport.Clone()
+
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil || (proxyURL.Scheme != "http" && proxyURL.Scheme != "https") || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q: must be an http or https URL", config.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if config.CAFile != "" {
+		pool, err := loadCertPool(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: config.ConnectTimeout, KeepAlive: keepAlive}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = config.ConnectTimeout
+
+	return &http.Client{Transport: transport, Timeout: config.Timeout}, nil
+}
+
+// loadCertPool returns the system roots plus the certificates in caFile.
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile) // #nosec G304 -- Path comes from operator configuration.
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,122 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package cloudflare
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient_CAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	httpClient, err := NewHTTPClient(HTTPConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp, getErr := httpClient.Get(server.URL); getErr == nil {
+		resp.Body.Close()
+		t.Fatal("expected an untrusted certificate to fail")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err = os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("failed to write CA bundle: %v", err)
+	}
+
+	httpClient, err = NewHTTPClient(HTTPConfig{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the CA bundle to be trusted: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		if r.URL.Host != "cloudflare.invalid" {
+			t.Errorf("expected an absolute request for cloudflare.invalid, got %q", r.URL.String())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	httpClient, err := NewHTTPClient(HTTPConfig{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := httpClient.Get("http://cloudflare.invalid/client/v4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if proxied.Load() != 1 {
+		t.Errorf("expected 1 proxied request, got %d", proxied.Load())
+	}
+}
+
+func TestNewHTTPClient_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	httpClient, err := NewHTTPClient(HTTPConfig{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if httpClient.Timeout != 50*time.Millisecond {
+		t.Errorf("expected timeout 50ms, got %v", httpClient.Timeout)
+	}
+	if resp, getErr := httpClient.Get(server.URL); getErr == nil {
+		resp.Body.Close()
+		t.Error("expected the request to time out")
+	}
+}
+
+func TestNewHTTPClient_InvalidConfig(t *testing.T) {
+	invalidCA := filepath.Join(t.TempDir(), "invalid.pem")
+	if err := os.WriteFile(invalidCA, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to write CA bundle: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config HTTPConfig
+	}{
+		{name: "proxy without scheme", config: HTTPConfig{ProxyURL: "proxy.internal:3128"}},
+		{name: "unsupported proxy scheme", config: HTTPConfig{ProxyURL: "socks5://proxy.internal:1080"}},
+		{name: "missing CA bundle", config: HTTPConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+		{name: "CA bundle without certificates", config: HTTPConfig{CAFile: invalidCA}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHTTPClient(tt.config); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
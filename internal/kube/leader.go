@@ -0,0 +1,155 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	// LeaseName is the name of the Lease used for leader election.
+	LeaseName = "cf-switch-leader"
+	// identitySeparator separates the pod name from its address in the Lease holder identity.
+	// Pod names cannot contain it.
+	identitySeparator = "_"
+)
+
+// LeaderElectionConfig configures leader election.
+type LeaderElectionConfig struct {
+	// PodName identifies this replica.
+	PodName string
+	// Address is the host:port at which the other replicas reach this replica's API.
+	Address string
+	// LeaseDuration is how long followers wait before taking over from a leader that stopped renewing.
+	// The leader renews the Lease well before it expires.
+	LeaseDuration time.Duration
+	// OnStartedLeading is called when this replica becomes the leader.
+	OnStartedLeading func(ctx context.Context)
+}
+
+// LeaderElector elects a single leader among the replicas through a Lease.
+// The holder identity of the Lease records the leader's address so followers can forward requests to it.
+type LeaderElector struct {
+	client   *Client
+	config   LeaderElectionConfig
+	elector  *leaderelection.LeaderElector
+	identity string
+	leading  atomic.Bool
+
+	mu     sync.Mutex
+	leader string
+}
+
+// NewLeaderElector creates a leader elector using the Lease LeaseName.
+func (c *Client) NewLeaderElector(config LeaderElectionConfig) (*LeaderElector, error) {
+	if config.PodName == "" || strings.Contains(config.PodName, identitySeparator) {
+		return nil, fmt.Errorf("invalid pod name %q", config.PodName)
+	}
+	if config.Address == "" {
+		return nil, errors.New("the address of this replica is required")
+	}
+
+	e := &LeaderElector{
+		client:   c,
+		config:   config,
+		identity: config.PodName + identitySeparator + config.Address,
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: LeaseName, Namespace: c.namespace},
+			Client:     c.clientset.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: e.identity},
+		},
+		LeaseDuration: config.LeaseDuration,
+		// Renew with time to spare and retry several times per lease.
+		RenewDeadline:   config.LeaseDuration * 2 / 3, //nolint:mnd // Two thirds of the lease.
+		RetryPeriod:     config.LeaseDuration / 5,     //nolint:mnd // Five attempts per lease.
+		ReleaseOnCancel: true,
+		Name:            LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: e.startedLeading,
+			OnStoppedLeading: e.stoppedLeading,
+			OnNewLeader:      e.newLeader,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid leader election config: %w", err)
+	}
+	e.elector = elector
+	return e, nil
+}
+
+// Run takes part in leader election until ctx is canceled. A leader that fails to renew the Lease
+// becomes a follower and campaigns again. The Lease is released when ctx is canceled.
+func (e *LeaderElector) Run(ctx context.Context) {
+	e.client.logger.InfoContext(ctx, "Starting leader election",
+		"lease", LeaseName,
+		"namespace", e.client.namespace,
+		"identity", e.identity)
+
+	for ctx.Err() == nil {
+		e.elector.Run(ctx)
+	}
+}
+
+// IsLeader reports whether this replica currently holds the Lease.
+func (e *LeaderElector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// LeaderName returns the pod name of the current leader, or "" if none is known.
+func (e *LeaderElector) LeaderName() string {
+	name, _ := e.currentLeader()
+	return name
+}
+
+// LeaderAddress returns the address of the current leader, or "" if none is known.
+func (e *LeaderElector) LeaderAddress() string {
+	_, address := e.currentLeader()
+	return address
+}
+
+// currentLeader splits the holder identity of the Lease into pod name and address.
+func (e *LeaderElector) currentLeader() (string, string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	name, address, _ := strings.Cut(e.leader, identitySeparator)
+	return name, address
+}
+
+// startedLeading is called by the elector once this replica acquired the Lease.
+func (e *LeaderElector) startedLeading(ctx context.Context) {
+	e.leading.Store(true)
+	e.client.logger.InfoContext(ctx, "Became leader", "lease", LeaseName)
+	if e.config.OnStartedLeading != nil {
+		e.config.OnStartedLeading(ctx)
+	}
+}
+
+// stoppedLeading is called by the elector whenever Run returns, whether or not this replica led.
+func (e *LeaderElector) stoppedLeading() {
+	if e.leading.Swap(false) {
+		//nolint:sloglint // No context in callback.
+		e.client.logger.Warn("Lost leadership", "lease", LeaseName)
+	}
+}
+
+// newLeader is called by the elector when it observes a new holder of the Lease.
+func (e *LeaderElector) newLeader(identity string) {
+	e.mu.Lock()
+	e.leader = identity
+	e.mu.Unlock()
+
+	name, address, _ := strings.Cut(identity, identitySeparator)
+	//nolint:sloglint // No context in callback.
+	e.client.logger.Info("Leader elected", "leader", name, "address", address, "self", identity == e.identity)
+}
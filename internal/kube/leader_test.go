@@ -0,0 +1,98 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package kube
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaderElector(t *testing.T) {
+	clientset := fake.NewClientset()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	client := &Client{clientset: clientset, namespace: "cf-switch", logger: logger}
+
+	started := make(chan string, 2)
+	newElector := func(name, address string) *LeaderElector {
+		t.Helper()
+		elector, err := client.NewLeaderElector(LeaderElectionConfig{
+			PodName:          name,
+			Address:          address,
+			LeaseDuration:    time.Second,
+			OnStartedLeading: func(context.Context) { started <- name },
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return elector
+	}
+	first := newElector("cf-switch-a", "10.0.0.1:8080")
+	second := newElector("cf-switch-b", "10.0.0.2:8080")
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		first.Run(firstCtx)
+		close(firstDone)
+	}()
+	waitForLeader(t, started, "cf-switch-a")
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	go second.Run(secondCtx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for second.LeaderAddress() != "10.0.0.1:8080" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the follower to observe the leader, got %q", second.LeaderAddress())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !first.IsLeader() || second.IsLeader() || second.LeaderName() != "cf-switch-a" {
+		t.Errorf("expected cf-switch-a to lead, got leaders %v and %v", first.IsLeader(), second.IsLeader())
+	}
+
+	// The leader releases the Lease on shutdown, so the follower takes over without waiting for it to expire.
+	stopFirst()
+	<-firstDone
+	if first.IsLeader() {
+		t.Error("expected the stopped replica to give up leadership")
+	}
+	waitForLeader(t, started, "cf-switch-b")
+	if !second.IsLeader() {
+		t.Error("expected cf-switch-b to lead")
+	}
+}
+
+func TestNewLeaderElector_InvalidConfig(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	client := &Client{clientset: fake.NewClientset(), namespace: "cf-switch", logger: logger}
+
+	for name, config := range map[string]LeaderElectionConfig{
+		"missing pod name": {Address: "10.0.0.1:8080", LeaseDuration: time.Second},
+		"invalid pod name": {PodName: "a_b", Address: "10.0.0.1:8080", LeaseDuration: time.Second},
+		"missing address":  {PodName: "cf-switch-a", LeaseDuration: time.Second},
+		"missing duration": {PodName: "cf-switch-a", Address: "10.0.0.1:8080"},
+	} {
+		if _, err := client.NewLeaderElector(config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// waitForLeader waits until the replica name becomes the leader.
+func waitForLeader(t *testing.T, started <-chan string, name string) {
+	t.Helper()
+	select {
+	case leader := <-started:
+		if leader != name {
+			t.Fatalf("expected %s to become the leader, got %s", name, leader)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s to become the leader", name)
+	}
+}
@@ -0,0 +1,255 @@
+package kube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/meyeringh/cf-switch/internal/auth"
+)
+
+const (
+	// RBACGroup is the API group used in SubjectAccessReviews for cf-switch permissions.
+	RBACGroup = "cf-switch.io"
+	// RBACResource is the resource used in SubjectAccessReviews for cf-switch permissions.
+	RBACResource = "rules"
+
+	// How long TokenReview and SubjectAccessReview results are cached per token.
+	reviewCacheTTL = time.Minute
+	// Maximum number of tokens whose review results are cached.
+	maxReviewCacheEntries = 1024
+	// Prefix for identities authenticated by ServiceAccount token.
+	serviceAccountActorPrefix = "k8s:"
+)
+
+// scopeVerbs maps API scopes to the RBAC verbs checked for them.
+//
+//nolint:gochecknoglobals // Read-only mapping.
+var scopeVerbs = map[auth.Scope]string{
+	auth.ScopeRuleRead:   "get",
+	auth.ScopeRuleToggle: "toggle",
+	auth.ScopeRuleHosts:  "update-hosts",
+	auth.ScopeAdmin:      "admin",
+}
+
+// ServiceAccountAuthenticator authenticates Kubernetes ServiceAccount tokens with a TokenReview
+// and grants scopes according to SubjectAccessReviews, so RBAC decides who may do what.
+// Only tokens from the cluster's issuer are reviewed, and only the scope a request needs is checked.
+type ServiceAccountAuthenticator struct {
+	client    *Client
+	issuer    string
+	audiences []string
+	logger    *slog.Logger
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedReview
+}
+
+// cachedReview is a cached authentication result.
+type cachedReview struct {
+	identity *auth.Identity
+	err      error
+	expires  time.Time
+}
+
+// NewServiceAccountAuthenticator creates an authenticator that reviews tokens through client.
+// Tokens must be issued by issuer, which defaults to the issuer of the pod's own ServiceAccount token.
+// If audiences is non-empty, tokens must be issued for one of them.
+func NewServiceAccountAuthenticator(
+	client *Client,
+	issuer string,
+	audiences []string,
+	logger *slog.Logger,
+) (*ServiceAccountAuthenticator, error) {
+	if issuer == "" {
+		issuer = client.issuer
+	}
+	if issuer == "" {
+		return nil, errors.New("the ServiceAccount token issuer is unknown, set it explicitly")
+	}
+
+	return &ServiceAccountAuthenticator{
+		client:    client,
+		issuer:    issuer,
+		audiences: audiences,
+		logger:    logger,
+		cache:     make(map[[sha256.Size]byte]cachedReview),
+	}, nil
+}
+
+// Authenticate implements auth.Authenticator.
+// Only JWTs from the cluster's issuer are reviewed; other tokens are left to other authenticators.
+func (a *ServiceAccountAuthenticator) Authenticate(r *http.Request) (*auth.Identity, error) {
+	token, err := auth.BearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	if tokenIssuer(token) != a.issuer {
+		return nil, auth.ErrNoCredentials
+	}
+
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	a.mu.Lock()
+	cached, ok := a.cache[key]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.identity, cached.err
+	}
+
+	identity, err := a.review(r.Context(), token)
+	if err != nil && !errors.Is(err, auth.ErrInvalidCredentials) {
+		// Do not cache transient API failures.
+		return nil, err
+	}
+
+	a.mu.Lock()
+	for k, entry := range a.cache {
+		if now.After(entry.expires) {
+			delete(a.cache, k)
+		}
+	}
+	if len(a.cache) >= maxReviewCacheEntries {
+		// Evict an arbitrary entry; map iteration order is random.
+		for k := range a.cache {
+			delete(a.cache, k)
+			break
+		}
+	}
+	a.cache[key] = cachedReview{identity: identity, err: err, expires: now.Add(reviewCacheTTL)}
+	a.mu.Unlock()
+
+	return identity, err
+}
+
+// review authenticates a token. Its scopes are checked with RBAC when a request needs them.
+func (a *ServiceAccountAuthenticator) review(ctx context.Context, token string) (*auth.Identity, error) {
+	user, err := a.client.ReviewToken(ctx, token, a.audiences)
+	if err != nil {
+		return nil, err
+	}
+
+	a.logger.DebugContext(ctx, "Authenticated ServiceAccount token", "user", user.Username)
+	return &auth.Identity{
+		Name:      serviceAccountActorPrefix + user.Username,
+		Authorize: a.authorizer(user),
+	}, nil
+}
+
+// authorizer returns a function that checks scopes of user with SubjectAccessReviews.
+// Decisions are kept for as long as the identity is cached.
+func (a *ServiceAccountAuthenticator) authorizer(
+	user *authenticationv1.UserInfo,
+) func(context.Context, auth.Scope) (bool, error) {
+	var mu sync.Mutex
+	decisions := make(map[auth.Scope]bool)
+
+	return func(ctx context.Context, scope auth.Scope) (bool, error) {
+		verb, ok := scopeVerbs[scope]
+		if !ok {
+			return false, nil
+		}
+
+		mu.Lock()
+		allowed, decided := decisions[scope]
+		mu.Unlock()
+		if decided {
+			return allowed, nil
+		}
+
+		allowed, err := a.client.Authorize(ctx, user, verb)
+		if err != nil {
+			return false, err
+		}
+
+		mu.Lock()
+		decisions[scope] = allowed
+		mu.Unlock()
+
+		a.logger.DebugContext(ctx, "Authorized ServiceAccount", "user", user.Username, "scope", scope, "allowed", allowed)
+		return allowed, nil
+	}
+}
+
+// tokenIssuer returns the unverified "iss" claim of a JWT, or "" if token is not a JWT.
+func tokenIssuer(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 { //nolint:mnd // A JWS has three dot-separated parts.
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Issuer
+}
+
+// ReviewToken authenticates a bearer token with the TokenReview API.
+// It returns an error wrapping auth.ErrInvalidCredentials if the token is rejected.
+func (c *Client) ReviewToken(
+	ctx context.Context,
+	token string,
+	audiences []string,
+) (*authenticationv1.UserInfo, error) {
+	review, err := c.clientset.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token review: %w", err)
+	}
+
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return nil, fmt.Errorf("%w: %s", auth.ErrInvalidCredentials, review.Status.Error)
+		}
+		return nil, auth.ErrInvalidCredentials
+	}
+	return &review.Status.User, nil
+}
+
+// Authorize checks with a SubjectAccessReview whether user may perform verb on cf-switch rules
+// in the client's namespace.
+func (c *Client) Authorize(ctx context.Context, user *authenticationv1.UserInfo, verb string) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+
+	review, err := c.clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: c.namespace,
+				Group:     RBACGroup,
+				Resource:  RBACResource,
+				Verb:      verb,
+			},
+			User:   user.Username,
+			Groups: user.Groups,
+			Extra:  extra,
+			UID:    user.UID,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to create subject access review: %w", err)
+	}
+	return review.Status.Allowed, nil
+}
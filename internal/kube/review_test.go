@@ -0,0 +1,168 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package kube
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/meyeringh/cf-switch/internal/auth"
+)
+
+const testIssuer = "https://kubernetes.default.svc.cluster.local"
+
+// testToken returns an unsigned JWT-shaped token with the given issuer and subject.
+func testToken(issuer, subject string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	payload := fmt.Sprintf(`{"iss":%q,"sub":%q}`, issuer, subject)
+	return encode([]byte(`{"alg":"RS256"}`)) + "." + encode([]byte(payload)) + ".signature"
+}
+
+//nolint:gochecknoglobals // Test fixtures.
+var (
+	testJobToken   = testToken(testIssuer, "job")
+	testOtherToken = testToken(testIssuer, "other")
+)
+
+// newReviewClient returns a client whose API server knows one ServiceAccount that may only toggle.
+// It counts token reviews and subject access reviews.
+func newReviewClient(t *testing.T) (*Client, *int, *int) {
+	t.Helper()
+	clientset := fake.NewClientset()
+	reviews, accessReviews := 0, 0
+
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == testJobToken && slices.Equal(review.Spec.Audiences, []string{"cf-switch"}) {
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: "system:serviceaccount:ops:failover-job",
+					Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:ops"},
+				},
+			}
+		} else {
+			review.Status = authenticationv1.TokenReviewStatus{Error: "token not recognized"}
+		}
+		return true, review, nil
+	})
+
+	clientset.PrependReactor("create", "subjectaccessreviews", func(
+		action k8stesting.Action,
+	) (bool, runtime.Object, error) {
+		accessReviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == "system:serviceaccount:ops:failover-job" &&
+			attrs.Group == RBACGroup && attrs.Resource == RBACResource && attrs.Namespace == "cf-switch" &&
+			(attrs.Verb == "get" || attrs.Verb == "toggle")
+		return true, review, nil
+	})
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	client := &Client{clientset: clientset, namespace: "cf-switch", logger: logger, issuer: testIssuer}
+	return client, &reviews, &accessReviews
+}
+
+func reviewRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/rule/enable", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestServiceAccountAuthenticator(t *testing.T) {
+	client, reviews, accessReviews := newReviewClient(t)
+	a, err := NewServiceAccountAuthenticator(client, "", []string{"cf-switch"}, client.logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	identity, err := a.Authenticate(reviewRequest(testJobToken))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity.Name != "k8s:system:serviceaccount:ops:failover-job" {
+		t.Errorf("unexpected identity name %s", identity.Name)
+	}
+	if *accessReviews != 0 {
+		t.Errorf("expected no access review before a scope is needed, got %d", *accessReviews)
+	}
+
+	// Only the scope a request needs is checked, and the decision is cached.
+	for range 2 {
+		allowed, authErr := identity.Authorized(t.Context(), auth.ScopeRuleToggle)
+		if authErr != nil || !allowed {
+			t.Errorf("expected RBAC to grant toggle, got %v, %v", allowed, authErr)
+		}
+	}
+	if allowed, _ := identity.Authorized(t.Context(), auth.ScopeRuleHosts); allowed {
+		t.Error("expected RBAC to deny hosts")
+	}
+	if *accessReviews != 2 {
+		t.Errorf("expected 2 access reviews, got %d", *accessReviews)
+	}
+
+	// The review result is cached.
+	if _, err = a.Authenticate(reviewRequest(testJobToken)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *reviews != 1 {
+		t.Errorf("expected 1 token review, got %d", *reviews)
+	}
+
+	if _, err = a.Authenticate(reviewRequest(testOtherToken)); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	for _, token := range []string{"opaque-token", testToken("https://idp.example.com", "job")} {
+		if _, err = a.Authenticate(reviewRequest(token)); !errors.Is(err, auth.ErrNoCredentials) {
+			t.Errorf("expected token %q to be skipped, got %v", token, err)
+		}
+	}
+	if *reviews != 2 {
+		t.Errorf("expected foreign tokens not to be reviewed, got %d reviews", *reviews)
+	}
+}
+
+func TestServiceAccountAuthenticator_BoundsCache(t *testing.T) {
+	client, _, _ := newReviewClient(t)
+	a, err := NewServiceAccountAuthenticator(client, "", nil, client.logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := range maxReviewCacheEntries + 10 {
+		token := testToken(testIssuer, fmt.Sprintf("attacker-%d", i))
+		if _, err = a.Authenticate(reviewRequest(token)); !errors.Is(err, auth.ErrInvalidCredentials) {
+			t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+		}
+	}
+	if len(a.cache) > maxReviewCacheEntries {
+		t.Errorf("expected at most %d cached reviews, got %d", maxReviewCacheEntries, len(a.cache))
+	}
+}
+
+func TestNewServiceAccountAuthenticator_RequiresIssuer(t *testing.T) {
+	client, _, _ := newReviewClient(t)
+	client.issuer = ""
+	if _, err := NewServiceAccountAuthenticator(client, "", nil, client.logger); err == nil {
+		t.Error("expected an error without a known issuer")
+	}
+	a, err := NewServiceAccountAuthenticator(client, testIssuer, nil, client.logger)
+	if err != nil || a.issuer != testIssuer {
+		t.Errorf("expected explicit issuer to be used, got %v", err)
+	}
+}
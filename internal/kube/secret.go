@@ -12,6 +12,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 )
 
 const (
@@ -19,15 +20,21 @@ const (
 	SecretName = "cf-switch-auth" // #nosec G101 -- This is a secret name, not a credential.
 	// TokenKey is the key in the secret data containing the token.
 	TokenKey = "apiToken"
+	// TokensKey is the optional key in the secret data containing named, scoped tokens as JSON.
+	TokensKey = "tokens"
+	// PeerKeyKey is the key in the secret data containing the key replicas sign forwarded requests with.
+	PeerKeyKey = "peerKey"
 	// TokenLength is the length of the generated token in bytes.
 	TokenLength = 32
 )
 
 // Client wraps the Kubernetes client for secret management.
 type Client struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
 	namespace string
 	logger    *slog.Logger
+	// issuer is the issuer of the pod's ServiceAccount token, if it could be read.
+	issuer string
 }
 
 // NewClient creates a new Kubernetes client for secret management.
@@ -46,6 +53,7 @@ func NewClient(namespace string, logger *slog.Logger) (*Client, error) {
 		clientset: clientset,
 		namespace: namespace,
 		logger:    logger,
+		issuer:    tokenIssuer(config.BearerToken),
 	}, nil
 }
 
@@ -71,6 +79,50 @@ func (c *Client) EnsureAuthSecret(ctx context.Context) (string, error) {
 	return token, nil
 }
 
+// EnsurePeerKey returns the key shared by all replicas for signing forwarded requests,
+// generating it in the authentication secret on first use. It is not changed by token rotation.
+func (c *Client) EnsurePeerKey(ctx context.Context) ([]byte, error) {
+	var key []byte
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secrets := c.clientset.CoreV1().Secrets(c.namespace)
+		secret, getErr := secrets.Get(ctx, SecretName, metav1.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+		if existing := secret.Data[PeerKeyKey]; len(existing) >= TokenLength {
+			key = existing
+			return nil
+		}
+
+		key = make([]byte, TokenLength)
+		if _, randErr := rand.Read(key); randErr != nil {
+			return fmt.Errorf("failed to generate random bytes: %w", randErr)
+		}
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data[PeerKeyKey] = key
+		_, updateErr := secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		return updateErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure peer key in secret %s: %w", SecretName, err)
+	}
+	return key, nil
+}
+
+// GetNamedTokens returns the raw named-token JSON from the authentication secret, or nil if it has none.
+func (c *Client) GetNamedTokens(ctx context.Context) ([]byte, error) {
+	secret, err := c.clientset.CoreV1().Secrets(c.namespace).Get(ctx, SecretName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get secret %s: %w", SecretName, err)
+	}
+	return secret.Data[TokensKey], nil
+}
+
 // getExistingToken attempts to get an existing secret and extract a valid token.
 func (c *Client) getExistingToken(ctx context.Context) (string, bool) {
 	secret, err := c.clientset.CoreV1().Secrets(c.namespace).Get(ctx, SecretName, metav1.GetOptions{})
@@ -134,6 +186,8 @@ func (c *Client) buildSecretObject(token string) *corev1.Secret {
 // updateSecret updates an existing secret, with fallback to create if it was deleted.
 func (c *Client) updateSecret(ctx context.Context, secretObj *corev1.Secret, existing *corev1.Secret) error {
 	secretObj.ObjectMeta.ResourceVersion = existing.ObjectMeta.ResourceVersion
+	// Keep other keys such as named tokens; StringData overrides the API token.
+	secretObj.Data = existing.Data
 	_, err := c.clientset.CoreV1().Secrets(c.namespace).Update(ctx, secretObj, metav1.UpdateOptions{})
 	if err != nil {
 		// If the secret was deleted between Get() and Update(), create a new one
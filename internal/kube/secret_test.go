@@ -2,9 +2,19 @@
 package kube
 
 import (
+	"bytes"
+	"context"
 	"log/slog"
 	"os"
+	"sync"
 	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestGenerateToken(t *testing.T) {
@@ -77,3 +87,91 @@ func TestEnsureAuthSecretMock(t *testing.T) {
 	// 4. Test token generation
 	// 5. Test error scenarios
 }
+
+func TestRotateTokenAndWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clientset := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: "cf-switch"},
+		Data: map[string][]byte{
+			TokenKey:  []byte("initial"),
+			TokensKey: []byte(`{"tokens":[{"name":"grafana","token":"viewer","role":"readonly"}]}`),
+		},
+	})
+	// The fake clientset drops events sent before the informer's watch is registered.
+	watching := make(chan struct{})
+	var once sync.Once
+	clientset.PrependWatchReactor("secrets", func(k8stesting.Action) (bool, watch.Interface, error) {
+		once.Do(func() { close(watching) })
+		return false, nil, nil
+	})
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	client := &Client{clientset: clientset, namespace: "cf-switch", logger: logger}
+
+	changes := make(chan AuthSecretTokens, 10)
+	if err := client.WatchAuthSecret(ctx, func(tokens AuthSecretTokens) { changes <- tokens }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	initial := waitForTokens(t, changes)
+	if initial.APIToken != "initial" || len(initial.Named) != 1 || initial.Named[0].Name != "grafana" {
+		t.Fatalf("unexpected initial tokens: %+v", initial)
+	}
+
+	<-watching
+	token, err := client.RotateToken(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token == "" || token == "initial" {
+		t.Fatalf("expected a new token, got %q", token)
+	}
+
+	rotated := waitForTokens(t, changes)
+	if rotated.APIToken != token {
+		t.Errorf("expected watched token %q, got %q", token, rotated.APIToken)
+	}
+	if len(rotated.Named) != 1 {
+		t.Errorf("expected named tokens to be kept, got %+v", rotated.Named)
+	}
+}
+
+func TestEnsurePeerKey(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: "cf-switch"},
+		Data:       map[string][]byte{TokenKey: []byte("initial")},
+	})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	client := &Client{clientset: clientset, namespace: "cf-switch", logger: logger}
+
+	key, err := client.EnsurePeerKey(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(key) != TokenLength {
+		t.Fatalf("expected a %d-byte key, got %d bytes", TokenLength, len(key))
+	}
+
+	// Other replicas get the same key, and rotating the API token keeps it.
+	if _, err = client.RotateToken(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, err := client.EnsurePeerKey(t.Context())
+	if err != nil || !bytes.Equal(again, key) {
+		t.Errorf("expected the stored key, got %x, %v", again, err)
+	}
+}
+
+// waitForTokens returns the next tokens delivered by the secret watch.
+func waitForTokens(t *testing.T, changes <-chan AuthSecretTokens) AuthSecretTokens {
+	t.Helper()
+	select {
+	case tokens := <-changes:
+		return tokens
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for secret change")
+		return AuthSecretTokens{}
+	}
+}
@@ -0,0 +1,130 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/meyeringh/cf-switch/internal/auth"
+)
+
+// AuthSecretTokens are the tokens stored in the authentication secret.
+type AuthSecretTokens struct {
+	// APIToken is the default token, granted every scope.
+	APIToken string
+	// Named are the optional named, scoped tokens.
+	Named []auth.Token
+}
+
+// WatchAuthSecret watches the authentication secret and calls onChange with its tokens whenever it is
+// added or updated. Secrets with an empty API token or unparsable named tokens are logged and skipped.
+// It returns once the initial state has been delivered; the watch stops when ctx is canceled.
+func (c *Client) WatchAuthSecret(ctx context.Context, onChange func(AuthSecretTokens)) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0,
+		informers.WithNamespace(c.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", SecretName).String()
+		}))
+	informer := factory.Core().V1().Secrets().Informer()
+
+	handle := func(obj interface{}) {
+		secret, ok := obj.(*corev1.Secret)
+		if !ok || secret.Name != SecretName {
+			return
+		}
+		tokens, err := parseAuthSecret(secret)
+		if err != nil {
+			c.logger.ErrorContext(ctx, "Ignoring invalid authentication secret, keeping current tokens",
+				"secret", SecretName, "error", err)
+			return
+		}
+		c.logger.InfoContext(ctx, "Authentication secret changed, reloading tokens",
+			"secret", SecretName, "named_tokens", len(tokens.Named))
+		onChange(tokens)
+	}
+
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: handle,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSecret, oldOK := oldObj.(*corev1.Secret)
+			newSecret, newOK := newObj.(*corev1.Secret)
+			// Relists report unchanged secrets as updates.
+			if oldOK && newOK && maps.EqualFunc(oldSecret.Data, newSecret.Data, bytes.Equal) {
+				return
+			}
+			handle(newObj)
+		},
+		DeleteFunc: func(interface{}) {
+			c.logger.WarnContext(ctx, "Authentication secret was deleted, keeping current tokens", "secret", SecretName)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch secret %s: %w", SecretName, err)
+	}
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("failed to sync secret %s: %w", SecretName, ctx.Err())
+	}
+	return nil
+}
+
+// RotateToken replaces the default API token in the authentication secret and returns the new token.
+// Other keys in the secret, such as named tokens, are kept.
+func (c *Client) RotateToken(ctx context.Context) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secrets := c.clientset.CoreV1().Secrets(c.namespace)
+		secret, getErr := secrets.Get(ctx, SecretName, metav1.GetOptions{})
+		if apierrors.IsNotFound(getErr) {
+			return c.createSecret(ctx, c.buildSecretObject(token))
+		}
+		if getErr != nil {
+			return getErr
+		}
+
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data[TokenKey] = []byte(token)
+		_, updateErr := secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		return updateErr
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate token in secret %s: %w", SecretName, err)
+	}
+
+	c.logger.InfoContext(ctx, "Rotated API token", "secret", SecretName)
+	return token, nil
+}
+
+// parseAuthSecret reads the tokens stored in the authentication secret.
+func parseAuthSecret(secret *corev1.Secret) (AuthSecretTokens, error) {
+	tokens := AuthSecretTokens{APIToken: string(secret.Data[TokenKey])}
+	if tokens.APIToken == "" {
+		return AuthSecretTokens{}, errors.New("secret has no API token")
+	}
+
+	if data := secret.Data[TokensKey]; data != nil {
+		named, err := auth.ParseTokens(data)
+		if err != nil {
+			return AuthSecretTokens{}, err
+		}
+		tokens.Named = named
+	}
+	return tokens, nil
+}
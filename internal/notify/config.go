@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// Payload formats supported by webhooks.
+const (
+	FormatGeneric = "generic"
+	FormatSlack   = "slack"
+	FormatTeams   = "teams"
+)
+
+const (
+	// Default number of delivery attempts per event and webhook.
+	defaultMaxAttempts = 5
+	// Default delay before the first retry.
+	defaultInitialBackoff = time.Second
+	// Default upper bound for the retry delay.
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// Config holds the notifier configuration loaded from NOTIFY_CONFIG_FILE.
+type Config struct {
+	Webhooks       []WebhookConfig `json:"webhooks"`
+	MaxAttempts    int             `json:"max_attempts"`
+	InitialBackoff Duration        `json:"initial_backoff"`
+	MaxBackoff     Duration        `json:"max_backoff"`
+	DeadLetterFile string          `json:"dead_letter_file"`
+}
+
+// WebhookConfig describes a single webhook endpoint.
+type WebhookConfig struct {
+	Name      string            `json:"name"`
+	URL       string            `json:"url"`
+	Format    string            `json:"format"`
+	Events    []string          `json:"events"`
+	Template  string            `json:"template"`
+	Headers   map[string]string `json:"headers"`
+	Secret    string            `json:"secret"`
+	SecretEnv string            `json:"secret_env"` // Name of an environment variable holding the secret.
+
+	// tmpl is Template, parsed during validation.
+	tmpl *template.Template
+}
+
+// Duration is a time.Duration that unmarshals from a Go duration string.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler for Duration.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// defaultEvents are the event types delivered when a webhook does not list any.
+//
+//nolint:gochecknoglobals // Read-only default set.
+var defaultEvents = []string{
+	types.EventRuleToggled,
+	types.EventHostsUpdated,
+	types.EventDriftCorrected,
+}
+
+// LoadConfig reads and validates a notifier configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- Path comes from operator configuration.
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notify config: %w", err)
+	}
+
+	var config Config
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse notify config: %w", err)
+	}
+
+	if err = config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// validate checks the configuration and fills in defaults.
+func (c *Config) validate() error {
+	if len(c.Webhooks) == 0 {
+		return errors.New("notify config must define at least one webhook")
+	}
+
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = Duration(defaultInitialBackoff)
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = Duration(defaultMaxBackoff)
+	}
+
+	for i := range c.Webhooks {
+		wh := &c.Webhooks[i]
+		if wh.Name == "" {
+			wh.Name = fmt.Sprintf("webhook-%d", i)
+		}
+		parsed, err := url.Parse(wh.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("webhook %s: invalid url %q", wh.Name, wh.URL)
+		}
+		switch wh.Format {
+		case "":
+			wh.Format = FormatGeneric
+		case FormatGeneric, FormatSlack, FormatTeams:
+		default:
+			return fmt.Errorf("webhook %s: unknown format %q", wh.Name, wh.Format)
+		}
+		if len(wh.Events) == 0 {
+			wh.Events = defaultEvents
+		}
+		if wh.Template != "" {
+			if wh.tmpl, err = template.New(wh.Name).Funcs(templateFuncs()).Parse(wh.Template); err != nil {
+				return fmt.Errorf("webhook %s: invalid template: %w", wh.Name, err)
+			}
+		}
+		if wh.SecretEnv != "" && wh.Secret == "" {
+			wh.Secret = os.Getenv(wh.SecretEnv)
+			if wh.Secret == "" {
+				return fmt.Errorf("webhook %s: environment variable %s is empty", wh.Name, wh.SecretEnv)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,363 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+const (
+	// HTTP timeout for a single webhook delivery attempt.
+	deliveryTimeout = 10 * time.Second
+	// Maximum number of response body bytes kept for error messages.
+	maxErrorBodyBytes = 512
+	// Number of events queued per webhook before new events are dead-lettered.
+	queueSize = 64
+
+	// SignatureHeader carries the hex HMAC-SHA256 of "<timestamp>.<body>".
+	SignatureHeader = "X-Cf-Switch-Signature"
+	// TimestampHeader carries the Unix timestamp used in the signature.
+	TimestampHeader = "X-Cf-Switch-Timestamp"
+	// EventHeader carries the event type.
+	EventHeader = "X-Cf-Switch-Event"
+)
+
+var (
+	// errPermanent marks delivery failures that must not be retried.
+	errPermanent = errors.New("permanent delivery failure")
+	// errQueueFull marks events dropped because a webhook fell too far behind.
+	errQueueFull = errors.New("delivery queue full")
+)
+
+// EventSource provides a stream of rule events.
+type EventSource interface {
+	Subscribe(ctx context.Context) <-chan types.RuleEvent
+}
+
+// Notifier delivers rule events to configured webhooks.
+// Each webhook has its own queue and worker, so events reach it in the order they were published.
+type Notifier struct {
+	config     *Config
+	webhooks   []*webhook
+	httpClient *http.Client
+	logger     *slog.Logger
+	deadMutex  sync.Mutex
+	wg         sync.WaitGroup
+}
+
+// webhook is a validated webhook with its delivery queue.
+type webhook struct {
+	WebhookConfig
+
+	queue chan types.RuleEvent
+}
+
+// deadLetter is a record of an event that could not be delivered.
+type deadLetter struct {
+	Webhook  string          `json:"webhook"`
+	URL      string          `json:"url"`
+	Event    types.RuleEvent `json:"event"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failed_at"`
+}
+
+// NewNotifier creates a notifier from a validated configuration.
+func NewNotifier(config *Config, logger *slog.Logger) *Notifier {
+	n := &Notifier{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: deliveryTimeout,
+		},
+		logger: logger,
+	}
+
+	for _, wh := range config.Webhooks {
+		n.webhooks = append(n.webhooks, &webhook{
+			WebhookConfig: wh,
+			queue:         make(chan types.RuleEvent, queueSize),
+		})
+	}
+
+	return n
+}
+
+// Start subscribes to the event source and delivers events until ctx is done.
+// It must be called only once.
+func (n *Notifier) Start(ctx context.Context, source EventSource) {
+	events := source.Subscribe(ctx)
+
+	for _, wh := range n.webhooks {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			for event := range wh.queue {
+				n.deliver(ctx, wh, event)
+			}
+		}()
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		for event := range events {
+			n.dispatch(ctx, event)
+		}
+		for _, wh := range n.webhooks {
+			close(wh.queue)
+		}
+	}()
+
+	n.logger.InfoContext(ctx, "Webhook notifier started", "webhooks", len(n.webhooks))
+}
+
+// Wait blocks until the event source is closed and all queued deliveries have finished.
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// dispatch queues an event for every webhook subscribed to its type.
+// Events for a webhook whose queue is full are dead-lettered.
+func (n *Notifier) dispatch(ctx context.Context, event types.RuleEvent) {
+	for _, wh := range n.webhooks {
+		if !slices.Contains(wh.Events, event.Type) {
+			continue
+		}
+		select {
+		case wh.queue <- event:
+		default:
+			n.deadLetter(ctx, wh, event, 0, errQueueFull)
+		}
+	}
+}
+
+// deliver sends an event to a webhook, retrying with exponential backoff.
+func (n *Notifier) deliver(ctx context.Context, wh *webhook, event types.RuleEvent) {
+	body, err := wh.render(event)
+	if err != nil {
+		n.deadLetter(ctx, wh, event, 0, err)
+		return
+	}
+
+	backoff := time.Duration(n.config.InitialBackoff)
+	for attempt := 1; ; attempt++ {
+		err = n.send(ctx, wh, event, body)
+		if err == nil {
+			n.logger.DebugContext(ctx, "Webhook delivered",
+				"webhook", wh.Name,
+				"event", event.Type,
+				"attempt", attempt)
+			return
+		}
+
+		if errors.Is(err, errPermanent) || attempt >= n.config.MaxAttempts {
+			n.deadLetter(ctx, wh, event, attempt, err)
+			return
+		}
+
+		n.logger.WarnContext(ctx, "Webhook delivery failed, retrying",
+			"webhook", wh.Name,
+			"event", event.Type,
+			"attempt", attempt,
+			"backoff", backoff,
+			"error", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			n.deadLetter(ctx, wh, event, attempt, fmt.Errorf("%w (shutdown before retry)", err))
+			return
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, time.Duration(n.config.MaxBackoff)) //nolint:mnd // Exponential backoff factor.
+	}
+}
+
+// send performs a single delivery attempt.
+func (n *Notifier) send(ctx context.Context, wh *webhook, event types.RuleEvent, body []byte) error {
+	// Deliveries must not be aborted mid-request on shutdown.
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: failed to create request: %w", errPermanent, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cf-switch-notifier")
+	req.Header.Set(EventHeader, event.Type)
+	for key, value := range wh.Headers {
+		req.Header.Set(key, value)
+	}
+
+	if wh.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(wh.Secret, timestamp, body))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			n.logger.WarnContext(ctx, "Failed to close response body", "error", closeErr)
+		}
+	}()
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	statusErr := fmt.Errorf("unexpected status code: %d, response: %s",
+		resp.StatusCode, strings.TrimSpace(string(respBody)))
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return statusErr
+	}
+	return fmt.Errorf("%w: %w", errPermanent, statusErr)
+}
+
+// deadLetter records an undeliverable event in the log and the dead-letter file.
+func (n *Notifier) deadLetter(ctx context.Context, wh *webhook, event types.RuleEvent, attempts int, cause error) {
+	n.logger.ErrorContext(ctx, "Webhook delivery failed permanently",
+		"webhook", wh.Name,
+		"event", event.Type,
+		"attempts", attempts,
+		"error", cause,
+		"dead_letter", true)
+
+	if n.config.DeadLetterFile == "" {
+		return
+	}
+
+	record, err := json.Marshal(deadLetter{
+		Webhook:  wh.Name,
+		URL:      wh.URL,
+		Event:    event,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		n.logger.ErrorContext(ctx, "Failed to encode dead letter", "error", err)
+		return
+	}
+
+	n.deadMutex.Lock()
+	defer n.deadMutex.Unlock()
+
+	f, err := os.OpenFile(n.config.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		n.logger.ErrorContext(ctx, "Failed to open dead letter file", "path", n.config.DeadLetterFile, "error", err)
+		return
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			n.logger.WarnContext(ctx, "Failed to close dead letter file", "error", closeErr)
+		}
+	}()
+
+	if _, err = f.Write(append(record, '\n')); err != nil {
+		n.logger.ErrorContext(ctx, "Failed to write dead letter", "path", n.config.DeadLetterFile, "error", err)
+	}
+}
+
+// render builds the request body for an event.
+func (wh *webhook) render(event types.RuleEvent) ([]byte, error) {
+	if wh.tmpl != nil {
+		var buf bytes.Buffer
+		if err := wh.tmpl.Execute(&buf, event); err != nil {
+			return nil, fmt.Errorf("failed to render template: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	var payload interface{}
+	switch wh.Format {
+	case FormatSlack:
+		payload = map[string]string{"text": Summary(event)}
+	case FormatTeams:
+		payload = map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  "cf-switch " + event.Type,
+			"text":     Summary(event),
+		}
+	default:
+		payload = event
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return body, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" using secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Summary returns a human-readable one-line description of an event.
+func Summary(event types.RuleEvent) string {
+	if event.Rule == nil {
+		if event.Error != "" {
+			return fmt.Sprintf("cf-switch %s: %s", event.Type, event.Error)
+		}
+		return "cf-switch " + event.Type
+	}
+
+	state := "disabled"
+	if event.Rule.Enabled {
+		state = "enabled"
+	}
+
+	hosts := strings.Join(event.Rule.Hostnames, ", ")
+	switch event.Type {
+	case types.EventRuleToggled:
+		return fmt.Sprintf("cf-switch: blocking %s for %s", state, hosts)
+	case types.EventHostsUpdated:
+		return fmt.Sprintf("cf-switch: hostnames changed to %s (blocking %s)", hosts, state)
+	case types.EventDriftCorrected:
+		return fmt.Sprintf("cf-switch: corrected drifted rule for %s (blocking %s)", hosts, state)
+	default:
+		return fmt.Sprintf("cf-switch %s: blocking %s for %s", event.Type, state, hosts)
+	}
+}
+
+// templateFuncs returns helper functions available to payload templates.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"summary": Summary,
+		"join":    strings.Join,
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+}
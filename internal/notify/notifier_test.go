@@ -0,0 +1,331 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError + 1, // Suppress dead letter logs during tests.
+	}))
+}
+
+func testConfig(url string) *Config {
+	config := &Config{
+		Webhooks:       []WebhookConfig{{Name: "test", URL: url}},
+		MaxAttempts:    3,
+		InitialBackoff: Duration(time.Millisecond),
+		MaxBackoff:     Duration(5 * time.Millisecond),
+	}
+	if err := config.validate(); err != nil {
+		panic(err)
+	}
+	return config
+}
+
+func testEvent() types.RuleEvent {
+	return types.RuleEvent{
+		Type: types.EventRuleToggled,
+		Rule: &types.Rule{
+			ID:        "rule-1",
+			Enabled:   true,
+			Hostnames: []string{"a.example.com", "b.example.com"},
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// eventSource is an EventSource backed by a channel.
+type eventSource chan types.RuleEvent
+
+func (s eventSource) Subscribe(context.Context) <-chan types.RuleEvent {
+	return s
+}
+
+// deliverAll runs the notifier over the given events and waits until all deliveries have finished.
+func deliverAll(notifier *Notifier, events ...types.RuleEvent) {
+	source := make(eventSource, len(events))
+	for _, event := range events {
+		source <- event
+	}
+	close(source)
+
+	notifier.Start(context.Background(), source)
+	notifier.Wait()
+}
+
+func TestNotifier_SignsGenericPayload(t *testing.T) {
+	var gotBody []byte
+	var gotSignature, gotTimestamp, gotEvent string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotSignature = r.Header.Get(SignatureHeader)
+		gotTimestamp = r.Header.Get(TimestampHeader)
+		gotEvent = r.Header.Get(EventHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL)
+	config.Webhooks[0].Secret = "s3cret"
+
+	deliverAll(NewNotifier(config, testLogger()), testEvent())
+
+	if gotEvent != types.EventRuleToggled {
+		t.Errorf("expected event header %q, got %q", types.EventRuleToggled, gotEvent)
+	}
+
+	expected := "sha256=" + Sign("s3cret", gotTimestamp, gotBody)
+	if gotSignature != expected {
+		t.Errorf("expected signature %q, got %q", expected, gotSignature)
+	}
+
+	var event types.RuleEvent
+	if err := json.Unmarshal(gotBody, &event); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if event.Rule == nil || event.Rule.ID != "rule-1" {
+		t.Errorf("expected rule-1 in payload, got %+v", event.Rule)
+	}
+}
+
+func TestNotifier_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	deadLetterFile := filepath.Join(t.TempDir(), "dead.jsonl")
+	config := testConfig(server.URL)
+	config.DeadLetterFile = deadLetterFile
+
+	deliverAll(NewNotifier(config, testLogger()), testEvent())
+
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+
+	if _, statErr := os.Stat(deadLetterFile); !os.IsNotExist(statErr) {
+		t.Error("expected no dead letter for successful delivery")
+	}
+}
+
+func TestNotifier_DeadLettersClientErrors(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	deadLetterFile := filepath.Join(t.TempDir(), "dead.jsonl")
+	config := testConfig(server.URL)
+	config.DeadLetterFile = deadLetterFile
+
+	deliverAll(NewNotifier(config, testLogger()), testEvent())
+
+	if calls.Load() != 1 {
+		t.Errorf("expected a single attempt for 4xx response, got %d", calls.Load())
+	}
+
+	data, err := os.ReadFile(deadLetterFile)
+	if err != nil {
+		t.Fatalf("failed to read dead letter file: %v", err)
+	}
+
+	var record deadLetter
+	if err = json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("failed to decode dead letter: %v", err)
+	}
+	if record.Webhook != "test" || record.Attempts != 1 {
+		t.Errorf("unexpected dead letter record: %+v", record)
+	}
+}
+
+func TestNotifier_FiltersEvents(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Snapshots are not part of the default event set.
+	deliverAll(NewNotifier(testConfig(server.URL), testLogger()), types.RuleEvent{Type: types.EventRuleSnapshot})
+
+	if calls.Load() != 0 {
+		t.Errorf("expected no deliveries, got %d", calls.Load())
+	}
+}
+
+func TestNotifier_DeliversInOrder(t *testing.T) {
+	var mutex sync.Mutex
+	var states []bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event types.RuleEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Slow down the first delivery so a concurrent second one would overtake it.
+		if event.Rule.Enabled {
+			time.Sleep(20 * time.Millisecond)
+		}
+		mutex.Lock()
+		states = append(states, event.Rule.Enabled)
+		mutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	enabled := testEvent()
+	disabled := testEvent()
+	disabled.Rule = &types.Rule{ID: "rule-1", Enabled: false}
+
+	deliverAll(NewNotifier(testConfig(server.URL), testLogger()), enabled, disabled)
+
+	if len(states) != 2 || !states[0] || states[1] {
+		t.Errorf("expected enable then disable, got %v", states)
+	}
+}
+
+func TestNotifier_DeadLettersWhenQueueFull(t *testing.T) {
+	deadLetterFile := filepath.Join(t.TempDir(), "dead.jsonl")
+	config := testConfig("https://hooks.example.com/x")
+	config.DeadLetterFile = deadLetterFile
+
+	// Without Start nothing drains the queue.
+	notifier := NewNotifier(config, testLogger())
+	for range queueSize + 1 {
+		notifier.dispatch(context.Background(), testEvent())
+	}
+
+	data, err := os.ReadFile(deadLetterFile)
+	if err != nil {
+		t.Fatalf("failed to read dead letter file: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("expected 1 dead letter, got %d", lines)
+	}
+	if !strings.Contains(string(data), errQueueFull.Error()) {
+		t.Errorf("expected queue full error in dead letter, got %s", data)
+	}
+}
+
+func TestWebhook_Render(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		template string
+		contains string
+	}{
+		{
+			name:     "slack",
+			format:   FormatSlack,
+			contains: `"text":"cf-switch: blocking enabled for a.example.com, b.example.com"`,
+		},
+		{
+			name:     "teams",
+			format:   FormatTeams,
+			contains: `"@type":"MessageCard"`,
+		},
+		{
+			name:     "template",
+			template: `{"state": {{ .Rule.Enabled }}, "msg": {{ json (summary .) }}}`,
+			contains: `{"state": true, "msg": "cf-switch: blocking enabled`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Webhooks: []WebhookConfig{{
+				Name:     "test",
+				URL:      "https://hooks.example.com/x",
+				Format:   tt.format,
+				Template: tt.template,
+			}}}
+			if err := config.validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			body, err := NewNotifier(config, testLogger()).webhooks[0].render(testEvent())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(string(body), tt.contains) {
+				t.Errorf("expected body to contain %q, got %s", tt.contains, body)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "from-env")
+
+	path := filepath.Join(t.TempDir(), "notify.json")
+	content := `{
+		"max_backoff": "10s",
+		"webhooks": [
+			{"url": "https://hooks.slack.com/services/x", "format": "slack", "secret_env": "TEST_WEBHOOK_SECRET"}
+		]
+	}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wh := config.Webhooks[0]
+	if wh.Name != "webhook-0" {
+		t.Errorf("expected default name %q, got %q", "webhook-0", wh.Name)
+	}
+	if wh.Secret != "from-env" {
+		t.Errorf("expected secret from environment, got %q", wh.Secret)
+	}
+	if len(wh.Events) != len(defaultEvents) {
+		t.Errorf("expected default events, got %v", wh.Events)
+	}
+	if time.Duration(config.MaxBackoff) != 10*time.Second {
+		t.Errorf("expected max backoff 10s, got %v", time.Duration(config.MaxBackoff))
+	}
+	if config.MaxAttempts != defaultMaxAttempts {
+		t.Errorf("expected default max attempts %d, got %d", defaultMaxAttempts, config.MaxAttempts)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	if err = os.WriteFile(invalid, []byte(`{"webhooks":[{"url":"ftp://x"}]}`), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, err = LoadConfig(invalid); err == nil {
+		t.Error("expected error for invalid webhook url")
+	}
+}
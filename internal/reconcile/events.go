@@ -0,0 +1,70 @@
+package reconcile
+
+import (
+	"context"
+	"sync"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+const (
+	// Number of events buffered per subscriber before new events are dropped.
+	eventBufferSize = 16
+)
+
+// Broker fans out rule events to any number of subscribers.
+type Broker struct {
+	mutex       sync.Mutex
+	subscribers map[chan types.RuleEvent]struct{}
+}
+
+// NewBroker creates a new event broker.
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[chan types.RuleEvent]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber. The returned channel is closed once ctx is done.
+func (b *Broker) Subscribe(ctx context.Context) <-chan types.RuleEvent {
+	ch := make(chan types.RuleEvent, eventBufferSize)
+
+	b.mutex.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.subscribers, ch)
+		close(ch)
+	}()
+
+	return ch
+}
+
+// Publish delivers an event to all subscribers without blocking.
+// Subscribers whose buffer is full miss the event; Publish returns how many did.
+func (b *Broker) Publish(event types.RuleEvent) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	dropped := 0
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// SubscriberCount returns the number of active subscribers.
+func (b *Broker) SubscriberCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.subscribers)
+}
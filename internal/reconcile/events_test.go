@@ -0,0 +1,103 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package reconcile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+func TestBroker_PublishSubscribe(t *testing.T) {
+	broker := NewBroker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := broker.Subscribe(ctx)
+	second := broker.Subscribe(ctx)
+
+	if broker.SubscriberCount() != 2 {
+		t.Fatalf("expected 2 subscribers, got %d", broker.SubscriberCount())
+	}
+
+	broker.Publish(types.RuleEvent{Type: types.EventRuleToggled})
+
+	for i, ch := range []<-chan types.RuleEvent{first, second} {
+		select {
+		case event := <-ch:
+			if event.Type != types.EventRuleToggled {
+				t.Errorf("subscriber %d: expected event %q, got %q", i, types.EventRuleToggled, event.Type)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("subscriber %d: timed out waiting for event", i)
+		}
+	}
+
+	cancel()
+
+	// Channels are closed once the context is done.
+	for i, ch := range []<-chan types.RuleEvent{first, second} {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Errorf("subscriber %d: expected closed channel", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("subscriber %d: timed out waiting for close", i)
+		}
+	}
+
+	if broker.SubscriberCount() != 0 {
+		t.Errorf("expected 0 subscribers, got %d", broker.SubscriberCount())
+	}
+}
+
+func TestBroker_PublishDoesNotBlock(t *testing.T) {
+	broker := NewBroker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := broker.Subscribe(ctx)
+
+	dropped := 0
+	done := make(chan struct{})
+	go func() {
+		for range eventBufferSize * 2 {
+			dropped += broker.Publish(types.RuleEvent{Type: types.EventRuleRefreshed})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+
+	if dropped != eventBufferSize {
+		t.Errorf("expected %d dropped events, got %d", eventBufferSize, dropped)
+	}
+	if len(ch) != eventBufferSize {
+		t.Errorf("expected %d buffered events, got %d", eventBufferSize, len(ch))
+	}
+}
+
+func TestReconciler_UpdateCurrentRulePublishesOnChange(t *testing.T) {
+	reconciler := NewReconciler(nil, &types.Config{}, nil)
+
+	rule := &types.Rule{ID: "rule-1", Hostnames: []string{"a.com"}, Version: 1}
+	if !reconciler.updateCurrentRule(rule) {
+		t.Error("expected first update to report a change")
+	}
+
+	same := *rule
+	if reconciler.updateCurrentRule(&same) {
+		t.Error("expected identical rule to report no change")
+	}
+
+	changed := same
+	changed.Enabled = true
+	if !reconciler.updateCurrentRule(&changed) {
+		t.Error("expected enabled change to be reported")
+	}
+}
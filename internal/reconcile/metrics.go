@@ -0,0 +1,45 @@
+package reconcile
+
+import (
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// MetricsRecorder receives the reconciler's metrics.
+type MetricsRecorder interface {
+	// ObserveRule records the current state of the rule.
+	ObserveRule(rule *types.Rule)
+	// ObserveReconcile records a completed reconciliation.
+	ObserveReconcile(duration time.Duration, err error)
+	// ObserveDriftCorrection records that the rule was changed back to match the configuration.
+	ObserveDriftCorrection()
+	// ObserveToggle records that the rule was enabled or disabled through the API.
+	ObserveToggle(enabled bool)
+}
+
+// Option configures a Reconciler.
+type Option func(*Reconciler)
+
+// WithMetrics publishes rule state and reconciliation outcomes to recorder.
+func WithMetrics(recorder MetricsRecorder) Option {
+	return func(r *Reconciler) {
+		r.metrics = recorder
+	}
+}
+
+// WithLeadership only changes the rule while leadership reports this replica as the leader.
+// Followers keep reading the rule so they can serve it.
+func WithLeadership(leadership Leadership) Option {
+	return func(r *Reconciler) {
+		r.leadership = leadership
+	}
+}
+
+// nopMetrics discards all metrics.
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRule(*types.Rule)               {}
+func (nopMetrics) ObserveReconcile(time.Duration, error) {}
+func (nopMetrics) ObserveDriftCorrection()               {}
+func (nopMetrics) ObserveToggle(bool)                    {}
@@ -5,39 +5,119 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/meyeringh/cf-switch/internal/audit"
 	"github.com/meyeringh/cf-switch/internal/cloudflare"
 	"github.com/meyeringh/cf-switch/pkg/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	// Timeout for individual reconciliation operations.
 	reconcileTimeout = 60 * time.Second
+	// Actor recorded for changes made by periodic reconciliation.
+	reconcilerActor = "reconciler"
+	// Name of the tracer for reconciler spans.
+	tracerName = "github.com/meyeringh/cf-switch/internal/reconcile"
+	// How long to wait after a trigger for further triggers that are merged into the same reconciliation.
+	triggerCoalesceWindow = time.Second
 )
 
+// Reasons for a reconciliation, as passed to Trigger.
+const (
+	// ReasonInterval is the periodic reconciliation.
+	ReasonInterval = "interval"
+	// ReasonRetry retries a failed reconciliation.
+	ReasonRetry = "retry"
+	// ReasonAPI is a reconciliation requested through POST /v1/reconcile.
+	ReasonAPI = "api"
+	// ReasonLeaderElected corrects drift as soon as this replica becomes the leader.
+	ReasonLeaderElected = "leader_elected"
+	// ReasonForwarded refreshes a follower's cached rule after it forwarded a change to the leader.
+	ReasonForwarded = "forwarded"
+)
+
+// Replica roles reported in the status with leader election.
+const (
+	roleLeader   = "leader"
+	roleFollower = "follower"
+)
+
+var (
+	// ErrRuleNotInitialized is returned until the first reconciliation has found or created the rule.
+	ErrRuleNotInitialized = errors.New("rule not initialized")
+	// ErrNoHostnames is returned by UpdateHosts if no valid hostname remains after normalization.
+	ErrNoHostnames = errors.New("no valid hostnames provided")
+	// ErrNotLeader is returned by changes on replicas that are not the elected leader.
+	ErrNotLeader = errors.New("not the leader")
+)
+
+// circuitReporter is implemented by Cloudflare clients with a circuit breaker.
+type circuitReporter interface {
+	CircuitState() cloudflare.CircuitState
+}
+
+// Leadership reports whether this replica was elected to change the rule.
+type Leadership interface {
+	IsLeader() bool
+	// LeaderName returns the name of the current leader, or "" if none is known.
+	LeaderName() string
+}
+
 // Reconciler manages the Cloudflare WAF Custom Rule.
 type Reconciler struct {
-	cfClient    *cloudflare.Client
+	cfClient    cloudflare.RulesetAPI
 	config      *types.Config
 	logger      *slog.Logger
 	mutex       sync.RWMutex
 	currentRule *types.Rule
 	rulesetID   string
+	events      *Broker
+	metrics     MetricsRecorder
+	leadership  Leadership
 	stopCh      chan struct{}
 	stoppedCh   chan struct{}
+	// triggerCh holds at most one pending trigger; further triggers are coalesced into it.
+	triggerCh chan string
+	// syncedCh signals that a mutation confirmed the rule state, postponing the next periodic reconcile.
+	syncedCh       chan struct{}
+	coalesceWindow time.Duration
+
+	// statusMutex guards status separately so status reads never wait for Cloudflare calls.
+	statusMutex sync.Mutex
+	status      types.ReconcileStatus
 }
 
 // NewReconciler creates a new reconciler.
-func NewReconciler(cfClient *cloudflare.Client, config *types.Config, logger *slog.Logger) *Reconciler {
-	return &Reconciler{
-		cfClient:  cfClient,
-		config:    config,
-		logger:    logger,
-		stopCh:    make(chan struct{}),
-		stoppedCh: make(chan struct{}),
+func NewReconciler(
+	cfClient cloudflare.RulesetAPI,
+	config *types.Config,
+	logger *slog.Logger,
+	opts ...Option,
+) *Reconciler {
+	r := &Reconciler{
+		cfClient:       cfClient,
+		config:         config,
+		logger:         logger,
+		events:         NewBroker(),
+		metrics:        nopMetrics{},
+		stopCh:         make(chan struct{}),
+		stoppedCh:      make(chan struct{}),
+		triggerCh:      make(chan string, 1),
+		syncedCh:       make(chan struct{}, 1),
+		coalesceWindow: triggerCoalesceWindow,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // Start begins the reconciliation process.
@@ -58,13 +138,24 @@ func (r *Reconciler) Stop() {
 	<-r.stoppedCh
 }
 
+// Trigger requests a reconciliation as soon as possible and reports whether it was queued.
+// Triggers arriving while another one is pending are coalesced into it and return false.
+func (r *Reconciler) Trigger(reason string) bool {
+	select {
+	case r.triggerCh <- reason:
+		return true
+	default:
+		return false
+	}
+}
+
 // GetCurrentRule returns the current rule state.
 func (r *Reconciler) GetCurrentRule(_ context.Context) (*types.Rule, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
 	if r.currentRule == nil {
-		return nil, errors.New("no rule available")
+		return nil, ErrRuleNotInitialized
 	}
 
 	// Return a copy to avoid external modifications.
@@ -72,13 +163,50 @@ func (r *Reconciler) GetCurrentRule(_ context.Context) (*types.Rule, error) {
 	return &rule, nil
 }
 
+// Status returns the state of the reconciliation loop.
+func (r *Reconciler) Status() types.ReconcileStatus {
+	r.statusMutex.Lock()
+	status := r.status
+	r.statusMutex.Unlock()
+
+	if breaker, ok := r.cfClient.(circuitReporter); ok {
+		status.CloudflareCircuit = string(breaker.CircuitState())
+	}
+	if r.leadership != nil {
+		status.Role = roleFollower
+		if r.leadership.IsLeader() {
+			status.Role = roleLeader
+		}
+		status.Leader = r.leadership.LeaderName()
+	}
+	return status
+}
+
+// Subscribe returns a channel of rule events that is closed once ctx is done.
+func (r *Reconciler) Subscribe(ctx context.Context) <-chan types.RuleEvent {
+	return r.events.Subscribe(ctx)
+}
+
 // ToggleRule enables or disables the rule.
 func (r *Reconciler) ToggleRule(ctx context.Context, enabled bool) (*types.Rule, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "reconcile.ToggleRule",
+		trace.WithAttributes(attribute.Bool("rule.enabled", enabled)))
+	rule, err := r.toggleRule(ctx, span, enabled)
+	endSpan(span, err)
+	return rule, err
+}
+
+// toggleRule implements ToggleRule within its span.
+func (r *Reconciler) toggleRule(ctx context.Context, span trace.Span, enabled bool) (*types.Rule, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	span.AddEvent("rule lock acquired")
 
+	if !r.isLeader() {
+		return nil, ErrNotLeader
+	}
 	if r.currentRule == nil || r.rulesetID == "" {
-		return nil, errors.New("rule not initialized")
+		return nil, ErrRuleNotInitialized
 	}
 
 	updates := map[string]interface{}{
@@ -109,23 +237,42 @@ func (r *Reconciler) ToggleRule(ctx context.Context, enabled bool) (*types.Rule,
 		"version", r.currentRule.Version,
 		"description", r.currentRule.Description)
 
-	rule := *r.currentRule
-	return &rule, nil
+	audit.Record(ctx, r.logger, "rule.toggle", "rule_id", r.currentRule.ID, "enabled", enabled)
+	r.metrics.ObserveToggle(enabled)
+	r.metrics.ObserveRule(r.currentRule)
+	r.publishRule(ctx, types.EventRuleToggled, r.currentRule)
+	r.markSynced()
+
+	snapshot := *r.currentRule
+	return &snapshot, nil
 }
 
 // UpdateHosts updates the hostnames in the rule.
 func (r *Reconciler) UpdateHosts(ctx context.Context, hostnames []string) (*types.Rule, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "reconcile.UpdateHosts",
+		trace.WithAttributes(attribute.Int("rule.hostnames", len(hostnames))))
+	rule, err := r.updateHosts(ctx, span, hostnames)
+	endSpan(span, err)
+	return rule, err
+}
+
+// updateHosts implements UpdateHosts within its span.
+func (r *Reconciler) updateHosts(ctx context.Context, span trace.Span, hostnames []string) (*types.Rule, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	span.AddEvent("rule lock acquired")
 
+	if !r.isLeader() {
+		return nil, ErrNotLeader
+	}
 	if r.currentRule == nil || r.rulesetID == "" {
-		return nil, errors.New("rule not initialized")
+		return nil, ErrRuleNotInitialized
 	}
 
 	// Normalize hostnames.
-	normalizedHosts := types.ParseHostnames(fmt.Sprintf("%v", hostnames))
+	normalizedHosts := types.ParseHostnames(strings.Join(hostnames, ","))
 	if len(normalizedHosts) == 0 {
-		return nil, errors.New("no valid hostnames provided")
+		return nil, ErrNoHostnames
 	}
 
 	// Build new expression.
@@ -156,34 +303,145 @@ func (r *Reconciler) UpdateHosts(ctx context.Context, hostnames []string) (*type
 		"version", r.currentRule.Version,
 		"description", r.currentRule.Description)
 
-	rule := *r.currentRule
-	return &rule, nil
+	audit.Record(ctx, r.logger, "rule.update_hosts", "rule_id", r.currentRule.ID, "hostnames", normalizedHosts)
+	r.metrics.ObserveRule(r.currentRule)
+	r.publishRule(ctx, types.EventHostsUpdated, r.currentRule)
+	r.markSynced()
+
+	snapshot := *r.currentRule
+	return &snapshot, nil
 }
 
-// reconcileLoop runs the periodic reconciliation.
+// reconcileLoop reconciles every ReconcileInterval and whenever triggered.
+// Failed reconciliations are retried with exponential backoff starting at ReconcileRetryDelay.
 func (r *Reconciler) reconcileLoop() {
 	defer close(r.stoppedCh)
 
-	ticker := time.NewTicker(r.config.ReconcileInterval)
-	defer ticker.Stop()
+	timer := time.NewTimer(r.config.ReconcileInterval)
+	defer timer.Stop()
+	r.scheduleNext(time.Now().Add(r.config.ReconcileInterval))
 
+	failures := 0
 	for {
+		reason := ReasonInterval
+		if failures > 0 {
+			reason = ReasonRetry
+		}
+
 		select {
 		case <-r.stopCh:
-			r.logger.Info("Reconciliation loop stopped")
+			r.stopLoop()
 			return
-		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
-			if err := r.reconcileOnce(ctx); err != nil {
-				r.logger.Error("Reconciliation failed", "error", err)
+		case <-r.syncedCh:
+			// The rule state was just confirmed by a mutation; a pending retry still runs on time.
+			if failures == 0 {
+				timer.Reset(r.config.ReconcileInterval)
+				r.scheduleNext(time.Now().Add(r.config.ReconcileInterval))
+			}
+			continue
+		case <-timer.C:
+		case reason = <-r.triggerCh:
+			if !r.coalesceTriggers() {
+				r.stopLoop()
+				return
 			}
-			cancel()
+			r.logger.Info("Reconciliation triggered", "reason", reason)
 		}
+
+		delay := r.config.ReconcileInterval
+		ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+		if err := r.reconcileOnce(ctx); err != nil {
+			failures++
+			delay = r.retryDelay(failures)
+			r.logger.ErrorContext(ctx, "Reconciliation failed",
+				"reason", reason,
+				"consecutive_failures", failures,
+				"retry_in", delay,
+				"error", err)
+			r.publish(ctx, types.RuleEvent{
+				Type:      types.EventReconcileError,
+				Error:     err.Error(),
+				Actor:     reconcilerActor,
+				Timestamp: time.Now(),
+			})
+		} else {
+			failures = 0
+		}
+		cancel()
+
+		timer.Reset(delay)
+		r.scheduleNext(time.Now().Add(delay))
 	}
 }
 
-// reconcileOnce performs a single reconciliation.
+// coalesceTriggers waits for a burst of triggers to end and drops the trigger it queued.
+// It returns false if the reconciler was stopped while waiting.
+func (r *Reconciler) coalesceTriggers() bool {
+	if r.coalesceWindow > 0 {
+		window := time.NewTimer(r.coalesceWindow)
+		defer window.Stop()
+		select {
+		case <-r.stopCh:
+			return false
+		case <-window.C:
+		}
+	}
+
+	select {
+	case <-r.triggerCh:
+	default:
+	}
+	return true
+}
+
+// stopLoop records that no reconciliation is scheduled anymore.
+func (r *Reconciler) stopLoop() {
+	r.scheduleNext(time.Time{})
+	r.logger.Info("Reconciliation loop stopped")
+}
+
+// retryDelay returns how long to wait after the given number of consecutive failures.
+// The delay starts at ReconcileRetryDelay and doubles up to ReconcileInterval.
+func (r *Reconciler) retryDelay(failures int) time.Duration {
+	interval := r.config.ReconcileInterval
+	delay := r.config.ReconcileRetryDelay
+	if delay <= 0 || delay >= interval {
+		return interval
+	}
+	for range failures - 1 {
+		delay *= 2
+		if delay >= interval {
+			return interval
+		}
+	}
+	return delay
+}
+
+// markSynced postpones the next periodic reconciliation after a successful mutation.
+func (r *Reconciler) markSynced() {
+	select {
+	case r.syncedCh <- struct{}{}:
+	default:
+	}
+}
+
+// reconcileOnce performs a single reconciliation and records its outcome.
 func (r *Reconciler) reconcileOnce(ctx context.Context) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "reconcile")
+	start := time.Now()
+	err := r.reconcile(ctx)
+	endSpan(span, err)
+	r.recordReconcile(start, err)
+	r.metrics.ObserveReconcile(time.Since(start), err)
+	return err
+}
+
+// reconcile ensures the ruleset and rule match the configuration.
+func (r *Reconciler) reconcile(ctx context.Context) error {
+	ctx = audit.WithActor(ctx, reconcilerActor)
+	if !r.isLeader() {
+		return r.observe(ctx)
+	}
 	r.logger.DebugContext(ctx, "Starting reconciliation")
 
 	// Get or create entrypoint ruleset.
@@ -191,10 +449,7 @@ func (r *Reconciler) reconcileOnce(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to ensure entrypoint ruleset: %w", err)
 	}
-
-	r.mutex.Lock()
-	r.rulesetID = ruleset.ID
-	r.mutex.Unlock()
+	r.setRuleset(ruleset.ID)
 
 	// Ensure our rule exists and is up to date.
 	if ensureErr := r.ensureRule(ctx, ruleset); ensureErr != nil {
@@ -205,6 +460,56 @@ func (r *Reconciler) reconcileOnce(ctx context.Context) error {
 	return nil
 }
 
+// observe caches the rule without changing it, leaving corrections to the leader.
+func (r *Reconciler) observe(ctx context.Context) error {
+	r.logger.DebugContext(ctx, "Reading rule as follower")
+
+	ruleset, err := r.cfClient.GetEntrypointRuleset(ctx, r.config.CloudflareZoneID, types.HTTPRequestFirewallCustomPhase)
+	if errors.Is(err, cloudflare.ErrEntrypointNotFound) {
+		r.logger.DebugContext(ctx, "Entrypoint ruleset not created yet, waiting for the leader")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get entrypoint ruleset: %w", err)
+	}
+	r.setRuleset(ruleset.ID)
+
+	existingRule := cloudflare.FindRuleByDescription(ruleset, types.RuleDescription)
+	if existingRule == nil {
+		r.logger.DebugContext(ctx, "Rule not created yet, waiting for the leader")
+		return nil
+	}
+
+	current := &types.Rule{
+		ID:          existingRule.ID,
+		Enabled:     existingRule.Enabled,
+		Expression:  existingRule.Expression,
+		Hostnames:   r.config.DestHostnames,
+		Description: existingRule.Description,
+		Version:     existingRule.Version.Int(),
+	}
+	if r.updateCurrentRule(current) {
+		r.publishRule(ctx, types.EventRuleRefreshed, current)
+	}
+	return nil
+}
+
+// setRuleset records the ID of the entrypoint ruleset.
+func (r *Reconciler) setRuleset(rulesetID string) {
+	r.mutex.Lock()
+	r.rulesetID = rulesetID
+	r.mutex.Unlock()
+
+	r.statusMutex.Lock()
+	r.status.RulesetID = rulesetID
+	r.statusMutex.Unlock()
+}
+
+// isLeader reports whether this replica may change the rule; without leader election it always may.
+func (r *Reconciler) isLeader() bool {
+	return r.leadership == nil || r.leadership.IsLeader()
+}
+
 // ensureEntrypointRuleset ensures the entrypoint ruleset exists.
 func (r *Reconciler) ensureEntrypointRuleset(ctx context.Context) (*types.CloudflareRuleset, error) {
 	phase := types.HTTPRequestFirewallCustomPhase
@@ -279,14 +584,16 @@ func (r *Reconciler) createNewRule(
 		createdRule.Expression = expectedExpression
 	}
 
-	r.updateCurrentRule(&types.Rule{
+	current := &types.Rule{
 		ID:          createdRule.ID,
 		Enabled:     createdRule.Enabled,
 		Expression:  createdRule.Expression,
 		Hostnames:   r.config.DestHostnames,
 		Description: createdRule.Description,
 		Version:     createdRule.Version.Int(),
-	})
+	}
+	r.updateCurrentRule(current)
+	r.publishRule(ctx, types.EventRuleCreated, current)
 
 	r.logger.InfoContext(ctx, "Created new rule",
 		"rule_id", createdRule.ID,
@@ -323,14 +630,17 @@ func (r *Reconciler) updateExistingRule(
 	}
 
 	// No update needed, just cache current state.
-	r.updateCurrentRule(&types.Rule{
+	current := &types.Rule{
 		ID:          existingRule.ID,
 		Enabled:     existingRule.Enabled,
 		Expression:  existingRule.Expression,
 		Hostnames:   r.config.DestHostnames,
 		Description: existingRule.Description,
 		Version:     existingRule.Version.Int(),
-	})
+	}
+	if r.updateCurrentRule(current) {
+		r.publishRule(ctx, types.EventRuleRefreshed, current)
+	}
 
 	r.logger.DebugContext(ctx, "Rule is up to date", "rule_id", existingRule.ID)
 	return nil
@@ -348,14 +658,20 @@ func (r *Reconciler) performRuleUpdate(
 		return fmt.Errorf("failed to update rule: %w", err)
 	}
 
-	r.updateCurrentRule(&types.Rule{
+	current := &types.Rule{
 		ID:          updatedRule.ID,
 		Enabled:     updatedRule.Enabled,
 		Expression:  updatedRule.Expression,
 		Hostnames:   r.config.DestHostnames,
 		Description: updatedRule.Description,
 		Version:     updatedRule.Version.Int(),
-	})
+	}
+	r.updateCurrentRule(current)
+	r.recordDrift(time.Now())
+	trace.SpanFromContext(ctx).AddEvent("drift corrected")
+	r.metrics.ObserveDriftCorrection()
+	audit.Record(ctx, r.logger, "rule.correct_drift", "rule_id", updatedRule.ID, "expression", updatedRule.Expression)
+	r.publishRule(ctx, types.EventDriftCorrected, current)
 
 	r.logger.InfoContext(ctx, "Updated rule",
 		"rule_id", updatedRule.ID,
@@ -365,9 +681,88 @@ func (r *Reconciler) performRuleUpdate(
 	return nil
 }
 
-// updateCurrentRule safely updates the current rule state.
-func (r *Reconciler) updateCurrentRule(rule *types.Rule) {
+// updateCurrentRule safely updates the current rule state and reports whether it changed.
+func (r *Reconciler) updateCurrentRule(rule *types.Rule) bool {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	changed := r.currentRule == nil || !rulesEqual(r.currentRule, rule)
 	r.currentRule = rule
+	r.metrics.ObserveRule(rule)
+
+	r.statusMutex.Lock()
+	r.status.RuleCached = true
+	r.status.RuleID = rule.ID
+	r.statusMutex.Unlock()
+	return changed
+}
+
+// recordReconcile records the outcome of a reconciliation that started at start.
+func (r *Reconciler) recordReconcile(start time.Time, err error) {
+	r.statusMutex.Lock()
+	defer r.statusMutex.Unlock()
+
+	r.status.LastReconcileTime = start
+	if err != nil {
+		r.status.LastError = err.Error()
+		r.status.ConsecutiveFailures++
+		return
+	}
+
+	r.status.LastSuccessTime = start
+	r.status.LastError = ""
+	r.status.ConsecutiveFailures = 0
+	r.status.DriftDetected = !r.status.LastDriftTime.Before(start)
+}
+
+// recordDrift records that drift was corrected at the given time.
+func (r *Reconciler) recordDrift(at time.Time) {
+	r.statusMutex.Lock()
+	defer r.statusMutex.Unlock()
+	r.status.LastDriftTime = at
+}
+
+// scheduleNext records when the next periodic reconciliation runs; zero means none is scheduled.
+func (r *Reconciler) scheduleNext(at time.Time) {
+	r.statusMutex.Lock()
+	defer r.statusMutex.Unlock()
+	r.status.NextReconcileTime = at
+}
+
+// publishRule publishes an event carrying a copy of the given rule.
+func (r *Reconciler) publishRule(ctx context.Context, eventType string, rule *types.Rule) {
+	snapshot := *rule
+	r.publish(ctx, types.RuleEvent{
+		Type:      eventType,
+		Rule:      &snapshot,
+		Actor:     audit.Actor(ctx),
+		Timestamp: time.Now(),
+	})
+}
+
+// publish sends an event to all subscribers and logs subscribers too slow to receive it.
+func (r *Reconciler) publish(ctx context.Context, event types.RuleEvent) {
+	if dropped := r.events.Publish(event); dropped > 0 {
+		r.logger.WarnContext(ctx, "Dropped event for slow subscribers",
+			"event", event.Type,
+			"subscribers", dropped)
+	}
+}
+
+// endSpan records err on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// rulesEqual reports whether two rules describe the same state.
+func rulesEqual(a, b *types.Rule) bool {
+	return a.ID == b.ID &&
+		a.Enabled == b.Enabled &&
+		a.Expression == b.Expression &&
+		a.Description == b.Description &&
+		a.Version == b.Version &&
+		slices.Equal(a.Hostnames, b.Hostnames)
 }
@@ -3,13 +3,16 @@ package reconcile
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"slices"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/internal/cloudflare/cftest"
 	"github.com/meyeringh/cf-switch/pkg/types"
 )
 
@@ -238,3 +241,503 @@ func TestReconciler_StopBehavior(_ *testing.T) {
 
 	// The test should complete without hanging.
 }
+
+func TestReconciler_Status(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	reconciler := NewReconciler(cloudflare.NewClient("test-token", logger), &types.Config{}, logger)
+
+	if status := reconciler.Status(); status.RuleCached || !status.LastReconcileTime.IsZero() {
+		t.Fatalf("expected empty status, got %+v", status)
+	}
+
+	start := time.Now()
+	reconciler.updateCurrentRule(&types.Rule{ID: "rule-1"})
+	reconciler.recordDrift(start.Add(time.Second))
+	reconciler.recordReconcile(start, nil)
+
+	status := reconciler.Status()
+	if !status.RuleCached || status.RuleID != "rule-1" || !status.DriftDetected || !status.LastSuccessTime.Equal(start) {
+		t.Errorf("unexpected status after drift correction: %+v", status)
+	}
+
+	reconciler.recordReconcile(start.Add(time.Minute), errors.New("invalid token"))
+	reconciler.recordReconcile(start.Add(2*time.Minute), errors.New("invalid token"))
+
+	status = reconciler.Status()
+	if status.ConsecutiveFailures != 2 || status.LastError != "invalid token" || !status.LastSuccessTime.Equal(start) {
+		t.Errorf("unexpected status after failures: %+v", status)
+	}
+
+	reconciler.recordReconcile(start.Add(3*time.Minute), nil)
+	status = reconciler.Status()
+	if status.ConsecutiveFailures != 0 || status.LastError != "" || status.DriftDetected {
+		t.Errorf("expected failures and drift to reset, got %+v", status)
+	}
+}
+
+func TestReconciler_StatusCircuit(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	reconciler := NewReconciler(cloudflare.NewClient("test-token", logger), &types.Config{}, logger)
+	if circuit := reconciler.Status().CloudflareCircuit; circuit != "" {
+		t.Errorf("expected no circuit state without a circuit breaker, got %q", circuit)
+	}
+
+	client := cloudflare.NewClient("test-token", logger,
+		cloudflare.WithCircuitBreaker(cloudflare.CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute}))
+	reconciler = NewReconciler(client, &types.Config{}, logger)
+	if circuit := reconciler.Status().CloudflareCircuit; circuit != string(cloudflare.CircuitClosed) {
+		t.Errorf("expected a closed circuit, got %q", circuit)
+	}
+}
+
+// recordingMetrics records the observations made by the reconciler.
+type recordingMetrics struct {
+	nopMetrics
+
+	rules []types.Rule
+}
+
+func (m *recordingMetrics) ObserveRule(rule *types.Rule) {
+	m.rules = append(m.rules, *rule)
+}
+
+func TestReconciler_Metrics(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	metrics := &recordingMetrics{}
+	reconciler := NewReconciler(cloudflare.NewClient("test-token", logger), &types.Config{}, logger,
+		WithMetrics(metrics))
+
+	reconciler.updateCurrentRule(&types.Rule{ID: "test-rule-id", Enabled: true, Hostnames: []string{"test.com"}})
+	if len(metrics.rules) != 1 || !metrics.rules[0].Enabled || len(metrics.rules[0].Hostnames) != 1 {
+		t.Errorf("expected the cached rule to be observed, got %+v", metrics.rules)
+	}
+
+	// No ruleset is cached, so the toggle fails before publishing metrics.
+	if _, err := reconciler.ToggleRule(context.Background(), false); err == nil {
+		t.Error("expected toggle without ruleset to fail")
+	}
+	if len(metrics.rules) != 1 {
+		t.Errorf("expected no observation for a failed toggle, got %+v", metrics.rules)
+	}
+}
+
+// newFakeReconciler creates a reconciler for a.example.com and b.example.com backed by fake.
+func newFakeReconciler(fake *cftest.Fake, opts ...Option) *Reconciler {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	config := &types.Config{
+		CloudflareZoneID: "test-zone",
+		DestHostnames:    []string{"a.example.com", "b.example.com"},
+	}
+	return NewReconciler(fake, config, logger, opts...)
+}
+
+// seedRule creates the entrypoint ruleset with a managed rule matching expression.
+func seedRule(t *testing.T, fake *cftest.Fake, expression string, enabled bool) (string, string) {
+	t.Helper()
+
+	ctx := context.Background()
+	ruleset, err := fake.CreateEntrypointRuleset(ctx, "test-zone", types.HTTPRequestFirewallCustomPhase)
+	if err != nil {
+		t.Fatalf("failed to create ruleset: %v", err)
+	}
+	rule, err := fake.AddRule(ctx, "test-zone", ruleset.ID, types.CloudflareRule{
+		Action:      types.BlockAction,
+		Expression:  expression,
+		Description: types.RuleDescription,
+		Enabled:     enabled,
+	})
+	if err != nil {
+		t.Fatalf("failed to add rule: %v", err)
+	}
+	return ruleset.ID, rule.ID
+}
+
+func TestReconciler_CreatesRulesetAndRule(t *testing.T) {
+	fake := cftest.NewFake()
+	reconciler := newFakeReconciler(fake)
+
+	if err := reconciler.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status := reconciler.Status()
+	ruleset, ok := fake.Ruleset(status.RulesetID)
+	if !ok || ruleset.Phase != types.HTTPRequestFirewallCustomPhase || len(ruleset.Rules) != 1 {
+		t.Fatalf("expected an entrypoint ruleset with one rule, got %+v", ruleset)
+	}
+
+	created := ruleset.Rules[0]
+	wantExpression := types.BuildExpression([]string{"a.example.com", "b.example.com"})
+	if created.Expression != wantExpression || created.Enabled || created.Description != types.RuleDescription {
+		t.Errorf("unexpected rule: %+v", created)
+	}
+
+	rule, err := reconciler.GetCurrentRule(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.ID != created.ID || status.RuleID != created.ID || rule.Version != 1 {
+		t.Errorf("expected cached rule %s at version 1, got %+v", created.ID, rule)
+	}
+}
+
+func TestReconciler_StartPublishesToEarlySubscribers(t *testing.T) {
+	reconciler := newFakeReconciler(cftest.NewFake())
+
+	// Subscribers like the webhook notifier subscribe before Start to see the initial reconcile.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := reconciler.Subscribe(ctx)
+
+	if err := reconciler.Start(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reconciler.Stop()
+
+	select {
+	case event := <-events:
+		if event.Type != types.EventRuleCreated {
+			t.Errorf("expected %s, got %s", types.EventRuleCreated, event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an event from the initial reconcile")
+	}
+}
+
+func TestReconciler_CorrectsDrift(t *testing.T) {
+	fake := cftest.NewFake()
+	rulesetID, ruleID := seedRule(t, fake, `http.host in {"old.example.com"}`, true)
+	reconciler := newFakeReconciler(fake)
+
+	if err := reconciler.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rule, _ := fake.Rule(rulesetID, ruleID)
+	if rule.Expression != types.BuildExpression([]string{"a.example.com", "b.example.com"}) {
+		t.Errorf("expected the expression to be corrected, got %q", rule.Expression)
+	}
+	if !rule.Enabled || rule.Version.Int() != 2 {
+		t.Errorf("expected the rule to stay enabled at version 2, got %+v", rule)
+	}
+	if status := reconciler.Status(); !status.DriftDetected {
+		t.Errorf("expected drift to be reported, got %+v", status)
+	}
+
+	// A second reconcile finds nothing to correct.
+	before := len(fake.Calls())
+	if err := reconciler.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := fake.Calls()[before:]; !slices.Equal(calls, []string{cloudflare.OperationGetEntrypointRuleset}) {
+		t.Errorf("expected only a ruleset read, got %v", calls)
+	}
+	if status := reconciler.Status(); status.DriftDetected {
+		t.Errorf("expected no drift on the second reconcile, got %+v", status)
+	}
+}
+
+func TestReconciler_RecreatesDeletedRule(t *testing.T) {
+	fake := cftest.NewFake()
+	reconciler := newFakeReconciler(fake)
+	ctx := context.Background()
+
+	if err := reconciler.reconcileOnce(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status := reconciler.Status()
+	if err := fake.DeleteRule(ctx, "test-zone", status.RulesetID, status.RuleID); err != nil {
+		t.Fatalf("failed to delete rule: %v", err)
+	}
+
+	if err := reconciler.reconcileOnce(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	recreated := reconciler.Status().RuleID
+	if recreated == status.RuleID {
+		t.Fatalf("expected a new rule, got %s again", recreated)
+	}
+	if _, ok := fake.Rule(status.RulesetID, recreated); !ok {
+		t.Errorf("expected rule %s to exist", recreated)
+	}
+}
+
+func TestReconciler_ToggleRule(t *testing.T) {
+	fake := cftest.NewFake()
+	rulesetID, ruleID := seedRule(t, fake, types.BuildExpression([]string{"a.example.com", "b.example.com"}), false)
+	reconciler := newFakeReconciler(fake)
+	ctx := context.Background()
+
+	if _, err := reconciler.ToggleRule(ctx, true); err == nil {
+		t.Error("expected toggle before the first reconcile to fail")
+	}
+	if err := reconciler.reconcileOnce(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rule, err := reconciler.ToggleRule(ctx, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !rule.Enabled || rule.Version != 2 {
+		t.Errorf("expected enabled rule at version 2, got %+v", rule)
+	}
+
+	stored, _ := fake.Rule(rulesetID, ruleID)
+	if !stored.Enabled || stored.Expression != rule.Expression {
+		t.Errorf("expected the stored rule to be enabled with the same expression, got %+v", stored)
+	}
+
+	// A rule deleted outside cf-switch cannot be toggled until the next reconcile recreates it.
+	if err = fake.DeleteRule(ctx, "test-zone", rulesetID, ruleID); err != nil {
+		t.Fatalf("failed to delete rule: %v", err)
+	}
+	if _, err = reconciler.ToggleRule(ctx, false); !errors.Is(err, cftest.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestReconciler_UpdateHosts(t *testing.T) {
+	fake := cftest.NewFake()
+	reconciler := newFakeReconciler(fake)
+	ctx := context.Background()
+
+	if err := reconciler.reconcileOnce(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rule, err := reconciler.UpdateHosts(ctx, []string{"C.example.com", " d.example.com "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantExpression := types.BuildExpression([]string{"c.example.com", "d.example.com"})
+	if rule.Expression != wantExpression || !slices.Equal(rule.Hostnames, []string{"c.example.com", "d.example.com"}) {
+		t.Errorf("expected normalized hostnames, got %+v", rule)
+	}
+
+	status := reconciler.Status()
+	stored, _ := fake.Rule(status.RulesetID, status.RuleID)
+	if stored.Expression != wantExpression || stored.Version.Int() != 2 {
+		t.Errorf("expected the stored expression to be updated at version 2, got %+v", stored)
+	}
+
+	before := len(fake.Calls())
+	if _, err = reconciler.UpdateHosts(ctx, []string{" "}); err == nil {
+		t.Error("expected an error for empty hostnames")
+	}
+	if len(fake.Calls()) != before {
+		t.Error("expected no Cloudflare call for empty hostnames")
+	}
+}
+
+func TestReconciler_CloudflareFailures(t *testing.T) {
+	fake := cftest.NewFake()
+	reconciler := newFakeReconciler(fake)
+	ctx := context.Background()
+	injected := errors.New("injected")
+
+	fake.FailNext(cloudflare.OperationGetEntrypointRuleset, injected)
+	if err := reconciler.reconcileOnce(ctx); !errors.Is(err, injected) {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+	if status := reconciler.Status(); status.ConsecutiveFailures != 1 || status.RuleCached {
+		t.Errorf("expected one failure and no cached rule, got %+v", status)
+	}
+
+	if err := reconciler.reconcileOnce(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fake.FailNext(cloudflare.OperationUpdateRule, injected)
+	if _, err := reconciler.ToggleRule(ctx, true); !errors.Is(err, injected) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+
+	rule, err := reconciler.GetCurrentRule(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.Enabled || rule.Version != 1 {
+		t.Errorf("expected the cached rule to be unchanged after a failed toggle, got %+v", rule)
+	}
+}
+
+// waitForStatus polls the reconciler status until done returns true.
+func waitForStatus(t *testing.T, reconciler *Reconciler, done func(types.ReconcileStatus) bool) types.ReconcileStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := reconciler.Status()
+		if done(status) {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the reconciler, status %+v", status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// countCalls returns how often operation was called.
+func countCalls(fake *cftest.Fake, operation string) int {
+	count := 0
+	for _, call := range fake.Calls() {
+		if call == operation {
+			count++
+		}
+	}
+	return count
+}
+
+func TestReconciler_TriggerCoalesces(t *testing.T) {
+	fake := cftest.NewFake()
+	reconciler := newFakeReconciler(fake)
+	reconciler.config.ReconcileInterval = time.Hour
+	reconciler.coalesceWindow = 20 * time.Millisecond
+
+	if err := reconciler.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first := reconciler.Status().LastReconcileTime
+
+	go reconciler.reconcileLoop()
+	defer reconciler.Stop()
+
+	if !reconciler.Trigger(ReasonAPI) {
+		t.Error("expected the first trigger to be queued")
+	}
+	for range 5 {
+		reconciler.Trigger(ReasonAPI)
+	}
+
+	status := waitForStatus(t, reconciler, func(s types.ReconcileStatus) bool {
+		return s.LastReconcileTime.After(first)
+	})
+	if status.NextReconcileTime.Before(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("expected the next periodic reconcile a full interval later, got %v", status.NextReconcileTime)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if reads := countCalls(fake, cloudflare.OperationGetEntrypointRuleset); reads != 2 {
+		t.Errorf("expected the burst of triggers to cause a single reconciliation, got %d reads", reads-1)
+	}
+}
+
+func TestReconciler_RetriesWithBackoff(t *testing.T) {
+	fake := cftest.NewFake()
+	reconciler := newFakeReconciler(fake)
+	reconciler.config.ReconcileInterval = time.Hour
+	reconciler.config.ReconcileRetryDelay = time.Millisecond
+	reconciler.coalesceWindow = 0
+
+	if err := reconciler.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	go reconciler.reconcileLoop()
+	defer reconciler.Stop()
+
+	fake.SetError(errors.New("injected"))
+	reconciler.Trigger(ReasonAPI)
+	waitForStatus(t, reconciler, func(s types.ReconcileStatus) bool { return s.ConsecutiveFailures >= 3 })
+
+	fake.ClearFailures()
+	status := waitForStatus(t, reconciler, func(s types.ReconcileStatus) bool { return s.ConsecutiveFailures == 0 })
+	if status.NextReconcileTime.Before(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("expected the interval to apply again after recovery, got %v", status.NextReconcileTime)
+	}
+}
+
+func TestReconciler_RetryDelay(t *testing.T) {
+	reconciler := newFakeReconciler(cftest.NewFake())
+	reconciler.config.ReconcileInterval = time.Minute
+	reconciler.config.ReconcileRetryDelay = 5 * time.Second
+
+	for failures, want := range map[int]time.Duration{
+		1: 5 * time.Second,
+		2: 10 * time.Second,
+		4: 40 * time.Second,
+		5: time.Minute,
+		9: time.Minute,
+	} {
+		if got := reconciler.retryDelay(failures); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+
+	reconciler.config.ReconcileRetryDelay = 0
+	if got := reconciler.retryDelay(1); got != time.Minute {
+		t.Errorf("expected the interval without a retry delay, got %v", got)
+	}
+}
+
+// staticLeadership reports a fixed leadership.
+type staticLeadership struct {
+	leader bool
+}
+
+func (l *staticLeadership) IsLeader() bool { return l.leader }
+
+func (l *staticLeadership) LeaderName() string { return "cf-switch-0" }
+
+func TestReconciler_Follower(t *testing.T) {
+	fake := cftest.NewFake()
+	leadership := &staticLeadership{}
+	reconciler := newFakeReconciler(fake, WithLeadership(leadership))
+	ctx := context.Background()
+
+	// Followers leave creating the ruleset and rule to the leader.
+	if err := reconciler.reconcileOnce(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := fake.Calls(); !slices.Equal(calls, []string{cloudflare.OperationGetEntrypointRuleset}) {
+		t.Errorf("expected only a ruleset read, got %v", calls)
+	}
+	if _, err := reconciler.GetCurrentRule(ctx); !errors.Is(err, ErrRuleNotInitialized) {
+		t.Errorf("expected ErrRuleNotInitialized, got %v", err)
+	}
+
+	// Nor do they correct drift, but they serve the rule as it is.
+	rulesetID, ruleID := seedRule(t, fake, `http.host in {"old.example.com"}`, true)
+	if err := reconciler.reconcileOnce(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rule, err := reconciler.GetCurrentRule(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.ID != ruleID || rule.Expression != `http.host in {"old.example.com"}` || !rule.Enabled {
+		t.Errorf("expected the stored rule to be cached unchanged, got %+v", rule)
+	}
+	if _, err = reconciler.ToggleRule(ctx, false); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("expected ErrNotLeader, got %v", err)
+	}
+	if status := reconciler.Status(); status.Role != "follower" || status.Leader != "cf-switch-0" {
+		t.Errorf("expected a follower of cf-switch-0, got %+v", status)
+	}
+
+	// Once elected, the replica corrects the drift.
+	leadership.leader = true
+	if err = reconciler.reconcileOnce(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored, _ := fake.Rule(rulesetID, ruleID)
+	if stored.Expression != types.BuildExpression([]string{"a.example.com", "b.example.com"}) {
+		t.Errorf("expected the leader to correct the expression, got %q", stored.Expression)
+	}
+	if status := reconciler.Status(); status.Role != "leader" {
+		t.Errorf("expected the leader role, got %q", status.Role)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/audit"
+	"github.com/meyeringh/cf-switch/internal/auth"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// TokenRotator persists a newly generated default API token.
+type TokenRotator interface {
+	// RotateToken stores a new default token and returns it.
+	RotateToken(ctx context.Context) (string, error)
+}
+
+// AdminHandler handles administrative operations.
+type AdminHandler struct {
+	rotator     TokenRotator
+	tokens      *auth.TokenAuthenticator
+	gracePeriod time.Duration
+	logger      *slog.Logger
+}
+
+// NewAdminHandler creates a new admin handler.
+func NewAdminHandler(
+	rotator TokenRotator,
+	tokens *auth.TokenAuthenticator,
+	gracePeriod time.Duration,
+	logger *slog.Logger,
+) *AdminHandler {
+	return &AdminHandler{
+		rotator:     rotator,
+		tokens:      tokens,
+		gracePeriod: gracePeriod,
+		logger:      logger,
+	}
+}
+
+// RotateToken handles POST /v1/admin/rotate-token.
+// The previous default token stays valid for the grace period so clients can switch over.
+func (h *AdminHandler) RotateToken(w http.ResponseWriter, r *http.Request) {
+	token, err := h.rotator.RotateToken(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to rotate API token", "error", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to rotate token")
+		return
+	}
+
+	// Apply the new token right away instead of waiting for the secret watch.
+	if err = h.tokens.Rotate(DefaultTokenName, token, h.gracePeriod); err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to apply rotated API token", "error", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to rotate token")
+		return
+	}
+
+	validUntil := time.Now().Add(h.gracePeriod)
+	h.logger.InfoContext(r.Context(), "Rotated API token",
+		"actor", audit.Actor(r.Context()),
+		"previous_token_valid_until", validUntil)
+
+	writeJSONResponse(w, http.StatusOK, &types.TokenRotationResponse{
+		Token:                   token,
+		GracePeriodSeconds:      int(h.gracePeriod.Seconds()),
+		PreviousTokenValidUntil: validUntil.UTC(),
+	})
+}
@@ -0,0 +1,78 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/auth"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// mockRotator implements TokenRotator for testing.
+type mockRotator struct {
+	token string
+	err   error
+}
+
+func (m *mockRotator) RotateToken(_ context.Context) (string, error) {
+	return m.token, m.err
+}
+
+func TestAdminHandler_RotateToken(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	tokens, err := auth.NewTokenAuthenticator(serverTokens("old-token", nil))
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	authenticates := func(token string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/v1/rule", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		_, authErr := tokens.Authenticate(req)
+		return authErr == nil
+	}
+
+	t.Run("rotator failure", func(t *testing.T) {
+		handler := NewAdminHandler(&mockRotator{err: errors.New("conflict")}, tokens, time.Minute, logger)
+		rr := httptest.NewRecorder()
+		handler.RotateToken(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/rotate-token", nil))
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+		if !authenticates("old-token") {
+			t.Error("expected old token to remain valid after a failed rotation")
+		}
+	})
+
+	t.Run("rotates with grace period", func(t *testing.T) {
+		handler := NewAdminHandler(&mockRotator{token: "new-token"}, tokens, time.Minute, logger)
+		rr := httptest.NewRecorder()
+		handler.RotateToken(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/rotate-token", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response types.TokenRotationResponse
+		if err = json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Token != "new-token" || response.GracePeriodSeconds != 60 {
+			t.Errorf("unexpected response: %+v", response)
+		}
+		if !authenticates("new-token") || !authenticates("old-token") {
+			t.Error("expected both tokens to be valid during the grace period")
+		}
+	})
+}
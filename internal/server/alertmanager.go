@@ -0,0 +1,369 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/audit"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// Actions an alert rule can take on the switch.
+const (
+	AlertActionEnable  = "enable"
+	AlertActionDisable = "disable"
+	AlertActionNone    = "none"
+)
+
+// Alert statuses sent by Alertmanager.
+const (
+	alertStatusFiring   = "firing"
+	alertStatusResolved = "resolved"
+)
+
+// AlertmanagerConfig maps alert labels to switch actions.
+type AlertmanagerConfig struct {
+	Rules []AlertRule `json:"rules"`
+}
+
+// AlertRule describes which alerts a rule matches and what it does.
+type AlertRule struct {
+	Name       string            `json:"name"`
+	Match      map[string]string `json:"match"`
+	MatchRE    map[string]string `json:"match_re"`
+	OnFiring   string            `json:"on_firing"`
+	OnResolved string            `json:"on_resolved"`
+
+	matchRE map[string]*regexp.Regexp
+}
+
+// AlertmanagerPayload is the webhook payload sent by Alertmanager.
+type AlertmanagerPayload struct {
+	Version     string            `json:"version"`
+	GroupKey    string            `json:"groupKey"`
+	Status      string            `json:"status"`
+	Receiver    string            `json:"receiver"`
+	GroupLabels map[string]string `json:"groupLabels"`
+	Alerts      []Alert           `json:"alerts"`
+}
+
+// Alert is a single alert within an Alertmanager payload.
+type Alert struct {
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+	Fingerprint string            `json:"fingerprint"`
+}
+
+// AlertmanagerResponse is returned after processing an Alertmanager payload.
+type AlertmanagerResponse struct {
+	Action string              `json:"action"`
+	Reason string              `json:"reason"`
+	Rule   *types.RuleResponse `json:"rule,omitempty"`
+}
+
+// alertDecision is the outcome of matching a payload against the configured rules.
+type alertDecision struct {
+	action string
+	alert  Alert
+	rule   *AlertRule
+}
+
+// activeAlert is a firing alert whose rule acts when it fires.
+type activeAlert struct {
+	action string
+	endsAt time.Time
+}
+
+// LoadAlertmanagerConfig reads and validates an Alertmanager integration config file.
+func LoadAlertmanagerConfig(path string) (*AlertmanagerConfig, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- Path comes from operator configuration.
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alertmanager config: %w", err)
+	}
+
+	var config AlertmanagerConfig
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse alertmanager config: %w", err)
+	}
+
+	if err = config.compile(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// compile validates the rules and compiles their regular expressions.
+func (c *AlertmanagerConfig) compile() error {
+	if len(c.Rules) == 0 {
+		return errors.New("alertmanager config must define at least one rule")
+	}
+
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i)
+		}
+		if len(rule.Match) == 0 && len(rule.MatchRE) == 0 {
+			return fmt.Errorf("alert rule %s: match or match_re is required", rule.Name)
+		}
+		for _, action := range []*string{&rule.OnFiring, &rule.OnResolved} {
+			switch *action {
+			case "":
+				*action = AlertActionNone
+			case AlertActionEnable, AlertActionDisable, AlertActionNone:
+			default:
+				return fmt.Errorf("alert rule %s: unknown action %q", rule.Name, *action)
+			}
+		}
+
+		rule.matchRE = make(map[string]*regexp.Regexp, len(rule.MatchRE))
+		for label, pattern := range rule.MatchRE {
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return fmt.Errorf("alert rule %s: invalid match_re for %s: %w", rule.Name, label, err)
+			}
+			rule.matchRE[label] = re
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the alert labels satisfy the rule.
+func (r *AlertRule) matches(labels map[string]string) bool {
+	for label, value := range r.Match {
+		if labels[label] != value {
+			return false
+		}
+	}
+	for label, re := range r.matchRE {
+		if !re.MatchString(labels[label]) {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns the first rule the alert labels satisfy, or nil.
+func (c *AlertmanagerConfig) match(labels map[string]string) *AlertRule {
+	for i := range c.Rules {
+		if c.Rules[i].matches(labels) {
+			return &c.Rules[i]
+		}
+	}
+	return nil
+}
+
+// decide records the payload's alerts in active and picks a single action for them.
+// Firing alerts take precedence over resolved ones, and enabling takes precedence over disabling.
+// A resolved alert does not undo the action of matching alerts that are still active, even if
+// Alertmanager reported them in another group; the decision is then AlertActionNone.
+func (c *AlertmanagerConfig) decide(alerts []Alert, active map[string]activeAlert, now time.Time) *alertDecision {
+	var candidates []alertDecision
+	for _, alert := range alerts {
+		rule := c.match(alert.Labels)
+		if rule == nil {
+			continue
+		}
+
+		key := alertKey(alert)
+		if alert.Status == alertStatusFiring && rule.OnFiring != AlertActionNone {
+			active[key] = activeAlert{action: rule.OnFiring, endsAt: alert.EndsAt}
+		} else {
+			delete(active, key)
+		}
+		candidates = append(candidates, alertDecision{action: AlertActionNone, alert: alert, rule: rule})
+	}
+
+	// Alertmanager stops sending alerts once they end, so alerts whose resolution was missed expire.
+	for key, a := range active {
+		if !a.endsAt.IsZero() && a.endsAt.Before(now) {
+			delete(active, key)
+		}
+	}
+
+	var best, held *alertDecision
+	bestRank := 0
+	for i := range candidates {
+		candidate := &candidates[i]
+		switch candidate.alert.Status {
+		case alertStatusFiring:
+			candidate.action = candidate.rule.OnFiring
+		case alertStatusResolved:
+			candidate.action = candidate.rule.OnResolved
+			if candidate.action != AlertActionNone && heldBy(active, candidate.action) {
+				candidate.action = AlertActionNone
+				held = candidate
+			}
+		}
+
+		if rank := actionRank(candidate.alert.Status, candidate.action); rank > bestRank {
+			best = candidate
+			bestRank = rank
+		}
+	}
+
+	if best == nil {
+		return held
+	}
+	return best
+}
+
+// heldBy reports whether an active alert still requires an action other than action.
+func heldBy(active map[string]activeAlert, action string) bool {
+	for _, a := range active {
+		if a.action != action {
+			return true
+		}
+	}
+	return false
+}
+
+// alertKey identifies an alert across payloads. Alertmanager sends a fingerprint; the
+// labels identify the alert when it does not.
+func alertKey(alert Alert) string {
+	if alert.Fingerprint != "" {
+		return alert.Fingerprint
+	}
+
+	var b strings.Builder
+	for _, label := range slices.Sorted(maps.Keys(alert.Labels)) {
+		b.WriteString(label)
+		b.WriteByte('=')
+		b.WriteString(alert.Labels[label])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// actionRank orders candidate actions for decide.
+func actionRank(status, action string) int {
+	var rank int
+	switch action {
+	case AlertActionDisable:
+		rank = 1
+	case AlertActionEnable:
+		rank = 2
+	default:
+		return 0
+	}
+	if status == alertStatusFiring {
+		rank += 2
+	}
+	return rank
+}
+
+// AlertmanagerHandler toggles the rule in response to Alertmanager webhooks.
+// It remembers firing alerts across webhook groups until they resolve.
+type AlertmanagerHandler struct {
+	reconciler RuleReconciler
+	config     *AlertmanagerConfig
+	logger     *slog.Logger
+
+	mutex  sync.Mutex
+	active map[string]activeAlert
+}
+
+// NewAlertmanagerHandler creates a new Alertmanager webhook handler.
+func NewAlertmanagerHandler(
+	reconciler RuleReconciler,
+	config *AlertmanagerConfig,
+	logger *slog.Logger,
+) *AlertmanagerHandler {
+	return &AlertmanagerHandler{
+		reconciler: reconciler,
+		config:     config,
+		logger:     logger,
+		active:     make(map[string]activeAlert),
+	}
+}
+
+// Receive handles POST /v1/integrations/alertmanager.
+func (h *AlertmanagerHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	var payload AlertmanagerPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		h.logger.Warn("Invalid Alertmanager payload", "error", err)
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Payloads are handled one at a time so toggles follow the order of the alert state.
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	decision := h.config.decide(payload.Alerts, h.active, time.Now())
+	if decision == nil {
+		h.logger.Debug("No alert rule matched", "group_key", payload.GroupKey, "alerts", len(payload.Alerts))
+		writeJSONResponse(w, http.StatusOK, AlertmanagerResponse{Action: AlertActionNone, Reason: "no matching rule"})
+		return
+	}
+
+	alertName := decision.alert.Labels["alertname"]
+	if decision.action == AlertActionNone {
+		writeJSONResponse(w, http.StatusOK, AlertmanagerResponse{
+			Action: AlertActionNone,
+			Reason: fmt.Sprintf("alert %s resolved but other matching alerts are still firing", alertName),
+		})
+		return
+	}
+
+	ctx := audit.WithFields(r.Context(), "alert", alertName)
+	enabled := decision.action == AlertActionEnable
+
+	current, err := h.reconciler.GetCurrentRule(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to get current rule", "error", err)
+		writeRuleError(w, err, "Failed to get rule")
+		return
+	}
+
+	if current.Enabled == enabled {
+		writeJSONResponse(w, http.StatusOK, AlertmanagerResponse{
+			Action: AlertActionNone,
+			Reason: "rule already " + enabledWord(enabled),
+			Rule:   toRuleResponse(current),
+		})
+		return
+	}
+
+	audit.Record(ctx, h.logger, "alertmanager.trigger",
+		"status", decision.alert.Status,
+		"fingerprint", decision.alert.Fingerprint,
+		"alert_rule", decision.rule.Name,
+		"switch_action", decision.action)
+
+	rule, err := h.reconciler.ToggleRule(ctx, enabled)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to toggle rule from alert", "alert", alertName, "error", err)
+		writeRuleError(w, err, "Failed to toggle rule")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, AlertmanagerResponse{
+		Action: decision.action,
+		Reason: fmt.Sprintf("alert %s %s (rule %s)", alertName, decision.alert.Status, decision.rule.Name),
+		Rule:   toRuleResponse(rule),
+	})
+}
+
+// enabledWord returns a human-readable rule state.
+func enabledWord(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
@@ -0,0 +1,237 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testAlertmanagerConfig(t *testing.T) *AlertmanagerConfig {
+	t.Helper()
+
+	config := &AlertmanagerConfig{
+		Rules: []AlertRule{
+			{
+				Name:       "overload",
+				Match:      map[string]string{"alertname": "OriginOverload"},
+				OnFiring:   AlertActionEnable,
+				OnResolved: AlertActionDisable,
+			},
+			{
+				Name:     "security",
+				MatchRE:  map[string]string{"severity": "critical|page"},
+				OnFiring: AlertActionEnable,
+			},
+		},
+	}
+	if err := config.compile(); err != nil {
+		t.Fatalf("failed to compile config: %v", err)
+	}
+	return config
+}
+
+func TestAlertmanagerConfig_Decide(t *testing.T) {
+	config := testAlertmanagerConfig(t)
+
+	tests := []struct {
+		name     string
+		alerts   []Alert
+		expected string
+	}{
+		{
+			name:     "no alerts",
+			expected: "",
+		},
+		{
+			name: "unmatched alert",
+			alerts: []Alert{
+				{Status: "firing", Labels: map[string]string{"alertname": "DiskFull", "severity": "warning"}},
+			},
+			expected: "",
+		},
+		{
+			name: "firing enables",
+			alerts: []Alert{
+				{Status: "firing", Labels: map[string]string{"alertname": "OriginOverload"}},
+			},
+			expected: AlertActionEnable,
+		},
+		{
+			name: "resolved disables",
+			alerts: []Alert{
+				{Status: "resolved", Labels: map[string]string{"alertname": "OriginOverload"}},
+			},
+			expected: AlertActionDisable,
+		},
+		{
+			name: "regex match",
+			alerts: []Alert{
+				{Status: "firing", Labels: map[string]string{"alertname": "WAFAttack", "severity": "page"}},
+			},
+			expected: AlertActionEnable,
+		},
+		{
+			name: "resolved without action",
+			alerts: []Alert{
+				{Status: "resolved", Labels: map[string]string{"alertname": "WAFAttack", "severity": "critical"}},
+			},
+			expected: "",
+		},
+		{
+			name: "firing wins over resolved",
+			alerts: []Alert{
+				{Status: "resolved", Labels: map[string]string{"alertname": "OriginOverload"}},
+				{Status: "firing", Labels: map[string]string{"alertname": "WAFAttack", "severity": "critical"}},
+			},
+			expected: AlertActionEnable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := config.decide(tt.alerts, make(map[string]activeAlert), time.Now())
+			if tt.expected == "" {
+				if decision != nil {
+					t.Errorf("expected no decision, got %q", decision.action)
+				}
+				return
+			}
+			if decision == nil {
+				t.Fatalf("expected action %q, got no decision", tt.expected)
+			}
+			if decision.action != tt.expected {
+				t.Errorf("expected action %q, got %q", tt.expected, decision.action)
+			}
+		})
+	}
+}
+
+func TestAlertmanagerConfig_DecideAcrossGroups(t *testing.T) {
+	config := testAlertmanagerConfig(t)
+	active := make(map[string]activeAlert)
+	now := time.Now()
+
+	overload := func(fingerprint, status string) []Alert {
+		return []Alert{{
+			Status:      status,
+			Fingerprint: fingerprint,
+			Labels:      map[string]string{"alertname": "OriginOverload", "instance": fingerprint},
+			EndsAt:      now.Add(time.Hour),
+		}}
+	}
+
+	// Two groups fire for the same rule.
+	for _, fingerprint := range []string{"a", "b"} {
+		decision := config.decide(overload(fingerprint, alertStatusFiring), active, now)
+		if decision.action != AlertActionEnable {
+			t.Fatalf("expected enable for %s, got %q", fingerprint, decision.action)
+		}
+	}
+
+	// Resolving one group keeps blocking on while the other still fires.
+	if decision := config.decide(overload("a", alertStatusResolved), active, now); decision.action != AlertActionNone {
+		t.Errorf("expected no action while b is firing, got %q", decision.action)
+	}
+
+	if decision := config.decide(overload("b", alertStatusResolved), active, now); decision.action != AlertActionDisable {
+		t.Errorf("expected disable once all alerts resolved, got %q", decision.action)
+	}
+
+	// Alerts whose resolution was missed no longer hold the rule once they end.
+	config.decide(overload("c", alertStatusFiring), active, now)
+	later := now.Add(2 * time.Hour)
+	decision := config.decide(overload("d", alertStatusResolved), active, later)
+	if decision.action != AlertActionDisable {
+		t.Errorf("expected expired alert to be dropped, got %q", decision.action)
+	}
+}
+
+func TestAlertmanagerHandler_Receive(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	reconciler := &MockReconciler{}
+	handler := NewAlertmanagerHandler(reconciler, testAlertmanagerConfig(t), logger)
+
+	send := func(status string) AlertmanagerResponse {
+		payload := AlertmanagerPayload{
+			Version: "4",
+			Status:  status,
+			Alerts: []Alert{
+				{Status: status, Labels: map[string]string{"alertname": "OriginOverload"}},
+			},
+		}
+		body, _ := json.Marshal(payload)
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/integrations/alertmanager", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.Receive(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response AlertmanagerResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return response
+	}
+
+	if resp := send("firing"); resp.Action != AlertActionEnable || resp.Rule == nil || !resp.Rule.Enabled {
+		t.Errorf("expected rule to be enabled, got %+v", resp)
+	}
+
+	// A repeated notification leaves the rule untouched.
+	if resp := send("firing"); resp.Action != AlertActionNone {
+		t.Errorf("expected no action for already enabled rule, got %q", resp.Action)
+	}
+
+	if resp := send("resolved"); resp.Action != AlertActionDisable || resp.Rule.Enabled {
+		t.Errorf("expected rule to be disabled, got %+v", resp)
+	}
+
+	t.Run("invalid payload", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/integrations/alertmanager", bytes.NewReader([]byte("{")))
+		rr := httptest.NewRecorder()
+		handler.Receive(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestLoadAlertmanagerConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	content := `{"rules":[{"match":{"alertname":"OriginOverload"},"on_firing":"enable","on_resolved":"disable"}]}`
+	if err := os.WriteFile(valid, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	config, err := LoadAlertmanagerConfig(valid)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Rules[0].Name != "rule-0" {
+		t.Errorf("expected default rule name %q, got %q", "rule-0", config.Rules[0].Name)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err = os.WriteFile(invalid, []byte(`{"rules":[{"match":{"a":"b"},"on_firing":"explode"}]}`), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, err = LoadAlertmanagerConfig(invalid); err == nil {
+		t.Error("expected error for unknown action")
+	}
+}
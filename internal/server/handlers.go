@@ -3,24 +3,39 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/meyeringh/cf-switch/internal/audit"
+	"github.com/meyeringh/cf-switch/internal/auth"
+	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/internal/reconcile"
 	"github.com/meyeringh/cf-switch/pkg/types"
 )
 
-// AuthMiddleware provides Bearer token authentication.
+const (
+	// Interval between keepalive comments on idle event streams.
+	eventKeepaliveInterval = 15 * time.Second
+)
+
+// AuthMiddleware authenticates API requests and attaches the caller identity.
 type AuthMiddleware struct {
-	token  string
-	logger *slog.Logger
+	authenticator auth.Authenticator
+	logger        *slog.Logger
 }
 
 // NewAuthMiddleware creates a new authentication middleware.
-func NewAuthMiddleware(token string, logger *slog.Logger) *AuthMiddleware {
+func NewAuthMiddleware(authenticator auth.Authenticator, logger *slog.Logger) *AuthMiddleware {
 	return &AuthMiddleware{
-		token:  token,
-		logger: logger,
+		authenticator: authenticator,
+		logger:        logger,
 	}
 }
 
@@ -33,32 +48,58 @@ func (a *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Check for Bearer token.
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		identity, err := a.authenticator.Authenticate(r)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials):
 			a.logger.Warn("Missing Authorization header", "path", r.URL.Path, "method", r.Method)
 			writeErrorResponse(w, http.StatusUnauthorized, "Missing Authorization header")
 			return
-		}
-
-		const bearerPrefix = "Bearer "
-		if !strings.HasPrefix(authHeader, bearerPrefix) {
+		case errors.Is(err, auth.ErrMalformedCredentials):
 			a.logger.Warn("Invalid Authorization header format", "path", r.URL.Path, "method", r.Method)
 			writeErrorResponse(w, http.StatusUnauthorized, "Invalid Authorization header format")
 			return
-		}
-
-		token := strings.TrimPrefix(authHeader, bearerPrefix)
-		if token != a.token {
-			a.logger.Warn("Invalid token", "path", r.URL.Path, "method", r.Method)
+		case err != nil:
+			a.logger.Warn("Invalid token", "path", r.URL.Path, "method", r.Method, "error", err)
 			writeErrorResponse(w, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		a.logger.Debug("Authenticated request", "actor", identity.Name, "path", r.URL.Path, "method", r.Method)
+
+		ctx := auth.WithIdentity(r.Context(), identity)
+		ctx = audit.WithActor(ctx, identity.Name)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RequireScope rejects requests whose identity lacks scope.
+func RequireScope(scope auth.Scope, logger *slog.Logger, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed := false
+		if identity, ok := auth.FromContext(r.Context()); ok {
+			var err error
+			if allowed, err = identity.Authorized(r.Context(), scope); err != nil {
+				logger.ErrorContext(r.Context(), "Failed to authorize request",
+					"actor", audit.Actor(r.Context()),
+					"required_scope", scope,
+					"error", err)
+				writeErrorResponse(w, http.StatusServiceUnavailable, "Authorization is temporarily unavailable")
+				return
+			}
+		}
+		if !allowed {
+			logger.WarnContext(r.Context(), "Insufficient scope",
+				"actor", audit.Actor(r.Context()),
+				"required_scope", scope,
+				"path", r.URL.Path,
+				"method", r.Method)
+			writeErrorResponse(w, http.StatusForbidden, fmt.Sprintf("Token lacks required scope %s", scope))
+			return
+		}
+		next(w, r)
+	}
+}
+
 // RuleHandler handles rule-related operations.
 type RuleHandler struct {
 	reconciler RuleReconciler
@@ -70,6 +111,9 @@ type RuleReconciler interface {
 	GetCurrentRule(ctx context.Context) (*types.Rule, error)
 	ToggleRule(ctx context.Context, enabled bool) (*types.Rule, error)
 	UpdateHosts(ctx context.Context, hostnames []string) (*types.Rule, error)
+	Subscribe(ctx context.Context) <-chan types.RuleEvent
+	Status() types.ReconcileStatus
+	Trigger(reason string) bool
 }
 
 // NewRuleHandler creates a new rule handler.
@@ -85,20 +129,11 @@ func (h *RuleHandler) GetRule(w http.ResponseWriter, r *http.Request) {
 	rule, err := h.reconciler.GetCurrentRule(r.Context())
 	if err != nil {
 		h.logger.Error("Failed to get current rule", "error", err)
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get rule")
+		writeRuleError(w, err, "Failed to get rule")
 		return
 	}
 
-	response := types.RuleResponse{
-		RuleID:      rule.ID,
-		Enabled:     rule.Enabled,
-		Expression:  rule.Expression,
-		Hostnames:   rule.Hostnames,
-		Description: rule.Description,
-		Version:     rule.Version,
-	}
-
-	writeJSONResponse(w, http.StatusOK, response)
+	writeJSONResponse(w, http.StatusOK, toRuleResponse(rule))
 }
 
 // ToggleRule handles POST /v1/rule/enable.
@@ -113,22 +148,13 @@ func (h *RuleHandler) ToggleRule(w http.ResponseWriter, r *http.Request) {
 	rule, err := h.reconciler.ToggleRule(r.Context(), req.Enabled)
 	if err != nil {
 		h.logger.Error("Failed to toggle rule", "enabled", req.Enabled, "error", err)
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to toggle rule")
+		writeRuleError(w, err, "Failed to toggle rule")
 		return
 	}
 
 	h.logger.Info("Rule toggled successfully", "enabled", req.Enabled, "rule_id", rule.ID)
 
-	response := types.RuleResponse{
-		RuleID:      rule.ID,
-		Enabled:     rule.Enabled,
-		Expression:  rule.Expression,
-		Hostnames:   rule.Hostnames,
-		Description: rule.Description,
-		Version:     rule.Version,
-	}
-
-	writeJSONResponse(w, http.StatusOK, response)
+	writeJSONResponse(w, http.StatusOK, toRuleResponse(rule))
 }
 
 // UpdateHosts handles PUT /v1/rule/hosts.
@@ -149,32 +175,89 @@ func (h *RuleHandler) UpdateHosts(w http.ResponseWriter, r *http.Request) {
 	rule, err := h.reconciler.UpdateHosts(r.Context(), req.Hostnames)
 	if err != nil {
 		h.logger.Error("Failed to update hosts", "hostnames", req.Hostnames, "error", err)
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to update hosts")
+		writeRuleError(w, err, "Failed to update hosts")
 		return
 	}
 
 	h.logger.Info("Rule hosts updated successfully", "hostnames", req.Hostnames, "rule_id", rule.ID)
 
-	response := types.RuleResponse{
-		RuleID:      rule.ID,
-		Enabled:     rule.Enabled,
-		Expression:  rule.Expression,
-		Hostnames:   rule.Hostnames,
-		Description: rule.Description,
-		Version:     rule.Version,
+	writeJSONResponse(w, http.StatusOK, toRuleResponse(rule))
+}
+
+// TriggerReconcile handles POST /v1/reconcile.
+func (h *RuleHandler) TriggerReconcile(w http.ResponseWriter, r *http.Request) {
+	queued := h.reconciler.Trigger(reconcile.ReasonAPI)
+	h.logger.Info("Reconciliation requested", "queued", queued)
+
+	writeJSONResponse(w, http.StatusAccepted, types.ReconcileTriggerResponse{Queued: queued})
+}
+
+// StreamEvents handles GET /v1/events as a Server-Sent Events stream.
+func (h *RuleHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rc := http.NewResponseController(w)
+
+	// The stream outlives the server write timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.WarnContext(ctx, "Failed to clear write deadline for event stream", "error", err)
 	}
 
-	writeJSONResponse(w, http.StatusOK, response)
+	events := h.reconciler.Subscribe(ctx)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if rule, err := h.reconciler.GetCurrentRule(ctx); err == nil {
+		writeEvent(w, types.RuleEvent{Type: types.EventRuleSnapshot, Rule: rule, Timestamp: time.Now()})
+	}
+	if err := rc.Flush(); err != nil {
+		h.logger.WarnContext(ctx, "Event stream does not support flushing", "error", err)
+		return
+	}
+
+	h.logger.DebugContext(ctx, "Event stream opened", "remote_addr", r.RemoteAddr)
+
+	keepalive := time.NewTicker(eventKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				h.logger.DebugContext(ctx, "Event stream closed", "remote_addr", r.RemoteAddr)
+				return
+			}
+			writeEvent(w, event)
+		case <-keepalive.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
 }
 
-// HealthHandler handles health checks.
+// HealthHandler handles health checks and status reports.
 type HealthHandler struct {
-	logger *slog.Logger
+	reconciler      RuleReconciler
+	maxReconcileAge time.Duration
+	logger          *slog.Logger
+	now             func() time.Time
 }
 
-// NewHealthHandler creates a new health handler.
-func NewHealthHandler(logger *slog.Logger) *HealthHandler {
-	return &HealthHandler{logger: logger}
+// NewHealthHandler creates a new health handler. The service is ready once a rule is cached and,
+// if maxReconcileAge is positive, the last successful reconcile is at most that old.
+func NewHealthHandler(reconciler RuleReconciler, maxReconcileAge time.Duration, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{
+		reconciler:      reconciler,
+		maxReconcileAge: maxReconcileAge,
+		logger:          logger,
+		now:             time.Now,
+	}
 }
 
 // Health handles GET /healthz.
@@ -183,16 +266,61 @@ func (h *HealthHandler) Health(w http.ResponseWriter, _ *http.Request) {
 }
 
 // Ready handles GET /readyz.
-func (h *HealthHandler) Ready(w http.ResponseWriter, _ *http.Request) {
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if reason := h.notReadyReason(h.reconciler.Status()); reason != "" {
+		h.logger.WarnContext(r.Context(), "Readiness check failed", "reason", reason)
+		writeJSONResponse(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready", "reason": reason})
+		return
+	}
 	writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ready"})
 }
 
+// Status handles GET /v1/status.
+func (h *HealthHandler) Status(w http.ResponseWriter, _ *http.Request) {
+	status := h.reconciler.Status()
+	reason := h.notReadyReason(status)
+	writeJSONResponse(w, http.StatusOK, &types.StatusResponse{
+		Ready:           reason == "",
+		ReadyReason:     reason,
+		ReconcileStatus: status,
+	})
+}
+
+// notReadyReason explains why the service is not ready, or returns "" if it is.
+func (h *HealthHandler) notReadyReason(status types.ReconcileStatus) string {
+	if !status.RuleCached {
+		return "rule not loaded"
+	}
+	if h.maxReconcileAge > 0 {
+		if age := h.now().Sub(status.LastSuccessTime); age > h.maxReconcileAge {
+			reason := fmt.Sprintf("last successful reconcile was %s ago", age.Round(time.Second))
+			if status.LastError != "" {
+				reason += ": " + status.LastError
+			}
+			return reason
+		}
+	}
+	return ""
+}
+
 // ErrorResponse represents an error response.
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
 }
 
+// toRuleResponse converts a rule into its API representation.
+func toRuleResponse(rule *types.Rule) *types.RuleResponse {
+	return &types.RuleResponse{
+		RuleID:      rule.ID,
+		Enabled:     rule.Enabled,
+		Expression:  rule.Expression,
+		Hostnames:   rule.Hostnames,
+		Description: rule.Description,
+		Version:     rule.Version,
+	}
+}
+
 // writeJSONResponse writes a JSON response.
 func writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -205,6 +333,70 @@ func writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{})
 	}
 }
 
+// writeEvent writes a single Server-Sent Event.
+func writeEvent(w io.Writer, event types.RuleEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		//nolint:sloglint // Global logger acceptable for encoding errors mid-stream
+		slog.ErrorContext(context.Background(), "Failed to encode event", "error", err)
+		return
+	}
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		//nolint:sloglint // Global logger acceptable for write errors mid-stream
+		slog.DebugContext(context.Background(), "Failed to write event", "error", err)
+	}
+}
+
+// writeRuleError writes the error response for a failed rule operation.
+// Cloudflare failures map to gateway statuses so clients can tell them from cf-switch errors.
+func writeRuleError(w http.ResponseWriter, err error, fallback string) {
+	var circuitErr *cloudflare.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(circuitErr.RetryAfter.Seconds())))))
+	}
+	status, message := ruleErrorStatus(err, fallback)
+	writeErrorResponse(w, status, message)
+}
+
+// ruleErrorStatus returns the HTTP status and message for an error from the reconciler.
+func ruleErrorStatus(err error, fallback string) (int, string) {
+	var apiErr *cloudflare.APIError
+	switch {
+	case errors.Is(err, reconcile.ErrRuleNotInitialized):
+		return http.StatusServiceUnavailable, "Rule not initialized yet"
+	case errors.Is(err, reconcile.ErrNotLeader):
+		return http.StatusServiceUnavailable, "Not the leader, retry the request"
+	case errors.Is(err, reconcile.ErrNoHostnames):
+		return http.StatusBadRequest, "No valid hostnames provided"
+	case errors.Is(err, cloudflare.ErrCircuitOpen):
+		return http.StatusServiceUnavailable, fallback + ": Cloudflare API is failing, requests are paused until it recovers"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, fallback + ": Cloudflare API timed out"
+	case errors.Is(err, cloudflare.ErrRateLimited):
+		return http.StatusServiceUnavailable, fallback + ": Cloudflare API rate limit exceeded"
+	case errors.Is(err, cloudflare.ErrAuthentication):
+		return http.StatusBadGateway, fallback + ": Cloudflare API token was rejected"
+	case errors.As(err, &apiErr) && errors.Is(err, cloudflare.ErrInvalidRequest):
+		return http.StatusUnprocessableEntity, fallback + ": " + cloudflareMessage(apiErr)
+	case errors.As(err, &apiErr):
+		return http.StatusBadGateway, fallback + ": " + cloudflareMessage(apiErr)
+	default:
+		return http.StatusInternalServerError, fallback
+	}
+}
+
+// cloudflareMessage summarizes the Cloudflare errors of apiErr for API clients.
+func cloudflareMessage(apiErr *cloudflare.APIError) string {
+	messages := make([]string, 0, len(apiErr.Errors))
+	for _, e := range apiErr.Errors {
+		messages = append(messages, fmt.Sprintf("%s (code %d)", e.Message, e.Code))
+	}
+	if len(messages) == 0 {
+		return fmt.Sprintf("Cloudflare API returned %d", apiErr.StatusCode)
+	}
+	return "Cloudflare API error: " + strings.Join(messages, "; ")
+}
+
 // writeErrorResponse writes an error response.
 func writeErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 	response := ErrorResponse{
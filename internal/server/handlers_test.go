@@ -5,12 +5,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/meyeringh/cf-switch/internal/audit"
+	"github.com/meyeringh/cf-switch/internal/auth"
+	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/internal/reconcile"
 	"github.com/meyeringh/cf-switch/pkg/types"
 )
 
@@ -20,6 +29,9 @@ type MockReconciler struct {
 	toggleErr     error
 	updateErr     error
 	getCurrentErr error
+	events        chan types.RuleEvent
+	status        *types.ReconcileStatus
+	triggers      []string
 }
 
 func (m *MockReconciler) GetCurrentRule(_ context.Context) (*types.Rule, error) {
@@ -62,14 +74,39 @@ func (m *MockReconciler) UpdateHosts(ctx context.Context, hostnames []string) (*
 	return rule, nil
 }
 
+func (m *MockReconciler) Status() types.ReconcileStatus {
+	if m.status == nil {
+		return types.ReconcileStatus{RuleCached: true, RuleID: "test-rule-id", LastSuccessTime: time.Now()}
+	}
+	return *m.status
+}
+
+func (m *MockReconciler) Trigger(reason string) bool {
+	m.triggers = append(m.triggers, reason)
+	return len(m.triggers) == 1
+}
+
+func (m *MockReconciler) Subscribe(_ context.Context) <-chan types.RuleEvent {
+	if m.events == nil {
+		m.events = make(chan types.RuleEvent)
+		close(m.events)
+	}
+	return m.events
+}
+
 //nolint:gocognit // Comprehensive authentication middleware test covering multiple scenarios
 func TestAuthMiddleware(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelError, // Suppress logs during tests.
 	}))
 
-	token := "test-token"
-	middleware := NewAuthMiddleware(token, logger)
+	authenticator, err := auth.NewTokenAuthenticator([]auth.Token{
+		{Name: "test", Token: "test-token", Role: auth.RoleAdmin},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	middleware := NewAuthMiddleware(authenticator, logger)
 
 	// Create a test handler.
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -169,6 +206,103 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 }
 
+func TestRequireScope(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	authenticator, err := auth.NewTokenAuthenticator([]auth.Token{
+		{Name: "viewer", Token: "read-token", Role: auth.RoleReadOnly},
+		{Name: "oncall", Token: "operator-token", Role: auth.RoleOperator},
+		{Name: "hosts-bot", Token: "hosts-token", Scopes: []auth.Scope{auth.ScopeRuleHosts}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+
+	var gotActor string
+	toggle := RequireScope(auth.ScopeRuleToggle, logger, func(w http.ResponseWriter, r *http.Request) {
+		gotActor = audit.Actor(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := NewAuthMiddleware(authenticator, logger).Middleware(toggle)
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+		expectedActor  string
+	}{
+		{"read-only token is forbidden", "read-token", http.StatusForbidden, ""},
+		{"hosts-only token is forbidden", "hosts-token", http.StatusForbidden, ""},
+		{"operator token is allowed", "operator-token", http.StatusOK, "token:oncall"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotActor = ""
+			req := httptest.NewRequest(http.MethodPost, "/v1/rule/enable", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if gotActor != tt.expectedActor {
+				t.Errorf("expected actor %q, got %q", tt.expectedActor, gotActor)
+			}
+		})
+	}
+}
+
+func TestRequireScope_Authorize(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	toggle := RequireScope(auth.ScopeRuleToggle, logger, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		allowed        bool
+		err            error
+		expectedStatus int
+	}{
+		{"allowed on demand", true, nil, http.StatusOK},
+		{"denied on demand", false, nil, http.StatusForbidden},
+		{"authorizer unavailable", false, errors.New("api server down"), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []auth.Scope
+			identity := &auth.Identity{
+				Name: "k8s:job",
+				Authorize: func(_ context.Context, scope auth.Scope) (bool, error) {
+					asked = append(asked, scope)
+					return tt.allowed, tt.err
+				},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/rule/enable", nil)
+			req = req.WithContext(auth.WithIdentity(req.Context(), identity))
+
+			rr := httptest.NewRecorder()
+			toggle.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if len(asked) != 1 || asked[0] != auth.ScopeRuleToggle {
+				t.Errorf("expected only the route's scope to be checked, got %v", asked)
+			}
+		})
+	}
+}
+
 func TestRuleHandler_GetRule(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelError,
@@ -260,6 +394,93 @@ func TestRuleHandler_ToggleRule(t *testing.T) {
 	})
 }
 
+func TestRuleHandler_CloudflareErrors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantText       string
+		wantRetryAfter string
+	}{
+		{
+			name:       "not initialized",
+			err:        reconcile.ErrRuleNotInitialized,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "invalid rule",
+			err: fmt.Errorf("failed to update rule: %w", &cloudflare.APIError{
+				StatusCode: http.StatusBadRequest,
+				Errors:     []types.CloudflareAPIError{{Code: 20120, Message: "invalid expression"}},
+			}),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantText:   "invalid expression (code 20120)",
+		},
+		{
+			name:       "token rejected",
+			err:        &cloudflare.APIError{StatusCode: http.StatusForbidden},
+			wantStatus: http.StatusBadGateway,
+			wantText:   "token was rejected",
+		},
+		{
+			name:       "rate limited",
+			err:        &cloudflare.APIError{StatusCode: http.StatusTooManyRequests},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "server error",
+			err:        &cloudflare.APIError{StatusCode: http.StatusBadGateway},
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name: "circuit open",
+			err: fmt.Errorf("failed to update rule: %w",
+				&cloudflare.CircuitOpenError{RetryAfter: 1500 * time.Millisecond}),
+			wantStatus:     http.StatusServiceUnavailable,
+			wantText:       "paused until it recovers",
+			wantRetryAfter: "2",
+		},
+		{
+			name:       "timeout",
+			err:        fmt.Errorf("request failed: %w", context.DeadlineExceeded),
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:       "unknown",
+			err:        errors.New("boom"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewRuleHandler(&MockReconciler{toggleErr: tt.err}, logger)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/rule/enable", strings.NewReader(`{"enabled": true}`))
+			rr := httptest.NewRecorder()
+			handler.ToggleRule(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if got := rr.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("expected Retry-After %q, got %q", tt.wantRetryAfter, got)
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if !strings.Contains(response.Message, tt.wantText) {
+				t.Errorf("expected message containing %q, got %q", tt.wantText, response.Message)
+			}
+		})
+	}
+}
+
 func TestRuleHandler_UpdateHosts(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelError,
@@ -313,14 +534,85 @@ func TestRuleHandler_UpdateHosts(t *testing.T) {
 	})
 }
 
-func TestHealthHandler(t *testing.T) {
+func TestRuleHandler_TriggerReconcile(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	reconciler := &MockReconciler{}
+	handler := NewRuleHandler(reconciler, logger)
+
+	for _, wantQueued := range []bool{true, false} {
+		rr := httptest.NewRecorder()
+		handler.TriggerReconcile(rr, httptest.NewRequest(http.MethodPost, "/v1/reconcile", nil))
+
+		if rr.Code != http.StatusAccepted {
+			t.Errorf("expected status %d, got %d", http.StatusAccepted, rr.Code)
+		}
+		var response types.ReconcileTriggerResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if response.Queued != wantQueued {
+			t.Errorf("expected queued = %v, got %v", wantQueued, response.Queued)
+		}
+	}
+
+	if !slices.Equal(reconciler.triggers, []string{reconcile.ReasonAPI, reconcile.ReasonAPI}) {
+		t.Errorf("expected two API triggers, got %v", reconciler.triggers)
+	}
+}
+
+func TestRuleHandler_StreamEvents(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelError,
 	}))
 
-	handler := NewHealthHandler(logger)
+	events := make(chan types.RuleEvent, 1)
+	events <- types.RuleEvent{
+		Type:      types.EventRuleToggled,
+		Rule:      &types.Rule{ID: "test-rule-id", Enabled: true},
+		Timestamp: time.Now(),
+	}
+	close(events)
+
+	reconciler := &MockReconciler{events: events}
+	handler := NewRuleHandler(reconciler, logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+	rr := httptest.NewRecorder()
+
+	handler.StreamEvents(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected content type %q, got %q", "text/event-stream", ct)
+	}
+
+	body := rr.Body.String()
+	snapshotIdx := strings.Index(body, "event: rule.snapshot\n")
+	toggledIdx := strings.Index(body, "event: rule.toggled\n")
+	if snapshotIdx < 0 || toggledIdx < 0 {
+		t.Fatalf("expected snapshot and toggled events, got %q", body)
+	}
+	if snapshotIdx > toggledIdx {
+		t.Error("expected snapshot event before toggled event")
+	}
+	if !strings.Contains(body, `"enabled":true`) {
+		t.Errorf("expected toggled rule payload in stream, got %q", body)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
 
 	t.Run("health check", func(t *testing.T) {
+		handler := NewHealthHandler(&MockReconciler{}, time.Minute, logger)
 		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
 		rr := httptest.NewRecorder()
 
@@ -340,25 +632,71 @@ func TestHealthHandler(t *testing.T) {
 		}
 	})
 
-	t.Run("readiness check", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
-		rr := httptest.NewRecorder()
+	now := time.Now()
+	tests := []struct {
+		name           string
+		status         types.ReconcileStatus
+		expectedStatus int
+		expectedReason string
+	}{
+		{
+			name:           "ready",
+			status:         types.ReconcileStatus{RuleCached: true, LastSuccessTime: now.Add(-30 * time.Second)},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "no rule cached",
+			status:         types.ReconcileStatus{},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReason: "rule not loaded",
+		},
+		{
+			name: "stale reconcile",
+			status: types.ReconcileStatus{
+				RuleCached:          true,
+				LastSuccessTime:     now.Add(-2 * time.Minute),
+				LastError:           "invalid token",
+				ConsecutiveFailures: 2,
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReason: "last successful reconcile was 2m0s ago: invalid token",
+		},
+	}
 
-		handler.Ready(rr, req)
+	for _, tt := range tests {
+		t.Run("readiness "+tt.name, func(t *testing.T) {
+			handler := NewHealthHandler(&MockReconciler{status: &tt.status}, time.Minute, logger)
+			handler.now = func() time.Time { return now }
 
-		if rr.Code != http.StatusOK {
-			t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
-		}
+			rr := httptest.NewRecorder()
+			handler.Ready(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
 
-		var response map[string]string
-		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
-			t.Fatalf("failed to unmarshal response: %v", err)
-		}
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			var response map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if response["reason"] != tt.expectedReason {
+				t.Errorf("expected reason %q, got %q", tt.expectedReason, response["reason"])
+			}
 
-		if response["status"] != "ready" {
-			t.Errorf("expected status %q, got %q", "ready", response["status"])
-		}
-	})
+			rr = httptest.NewRecorder()
+			handler.Status(rr, httptest.NewRequest(http.MethodGet, "/v1/status", nil))
+
+			var status types.StatusResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+				t.Fatalf("failed to unmarshal status: %v", err)
+			}
+			if status.Ready != (tt.expectedStatus == http.StatusOK) || status.ReadyReason != tt.expectedReason {
+				t.Errorf("unexpected status readiness: %+v", status)
+			}
+			if status.ConsecutiveFailures != tt.status.ConsecutiveFailures || status.LastError != tt.status.LastError {
+				t.Errorf("expected reconcile status %+v, got %+v", tt.status, status.ReconcileStatus)
+			}
+		})
+	}
 }
 
 // MockError implements error interface for testing.
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/auth"
+	"github.com/meyeringh/cf-switch/internal/reconcile"
+)
+
+const (
+	// ForwardedHeader marks requests a follower forwarded to the leader, which never forwards them again.
+	ForwardedHeader = "X-Cf-Switch-Forwarded"
+	// ForwardedIdentityHeader carries the caller's identity, signed by the follower with the peer key.
+	ForwardedIdentityHeader = "X-Cf-Switch-Forwarded-Identity"
+	// Timeout for the leader's response to a forwarded request.
+	forwardTimeout = 30 * time.Second
+	// How long a forwarded identity is accepted by the leader.
+	forwardedIdentityTTL = time.Minute
+)
+
+// LeaderResolver reports whether this replica is the leader and where to reach the leader otherwise.
+type LeaderResolver interface {
+	IsLeader() bool
+	// LeaderAddress returns the host:port of the leader, or "" if none is known.
+	LeaderAddress() string
+}
+
+// forwardedIdentity is the identity a follower authenticated, as asserted to the leader.
+type forwardedIdentity struct {
+	Name    string       `json:"name"`
+	Scopes  []auth.Scope `json:"scopes"`
+	Expires int64        `json:"exp"`
+}
+
+// peerSigner signs and verifies forwarded identities with the key shared by all replicas.
+type peerSigner struct {
+	key []byte
+	now func() time.Time
+}
+
+// sign returns the header value asserting that the caller was authenticated as name with scopes.
+func (p *peerSigner) sign(name string, scopes []auth.Scope) (string, error) {
+	payload, err := json.Marshal(forwardedIdentity{
+		Name:    name,
+		Scopes:  scopes,
+		Expires: p.now().Add(forwardedIdentityTTL).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode forwarded identity: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(p.mac(encoded)), nil
+}
+
+// verify returns the identity asserted by a header value created with sign.
+func (p *peerSigner) verify(value string) (*forwardedIdentity, error) {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, errors.New("malformed forwarded identity")
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, p.mac(encoded)) {
+		return nil, errors.New("invalid forwarded identity signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed forwarded identity: %w", err)
+	}
+
+	var identity forwardedIdentity
+	if err = json.Unmarshal(payload, &identity); err != nil {
+		return nil, fmt.Errorf("malformed forwarded identity: %w", err)
+	}
+	if p.now().Unix() > identity.Expires {
+		return nil, errors.New("forwarded identity expired")
+	}
+	return &identity, nil
+}
+
+// mac returns the HMAC-SHA256 of data with the peer key.
+func (p *peerSigner) mac(data string) []byte {
+	h := hmac.New(sha256.New, p.key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Authenticate implements auth.Authenticator for requests forwarded by other replicas.
+func (p *peerSigner) Authenticate(r *http.Request) (*auth.Identity, error) {
+	value := r.Header.Get(ForwardedIdentityHeader)
+	if value == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	identity, err := p.verify(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", auth.ErrInvalidCredentials, err)
+	}
+	return &auth.Identity{Name: identity.Name, Scopes: identity.Scopes}, nil
+}
+
+// leaderForwarder forwards requests that change the rule from followers to the leader.
+type leaderForwarder struct {
+	resolver   LeaderResolver
+	reconciler RuleReconciler
+	signer     *peerSigner
+	scheme     string
+	transport  http.RoundTripper
+	logger     *slog.Logger
+}
+
+// newLeaderForwarder creates a forwarder that signs forwarded identities with key and reaches the
+// leader over HTTPS if tlsConfig is set.
+func newLeaderForwarder(
+	resolver LeaderResolver,
+	reconciler RuleReconciler,
+	key []byte,
+	tlsConfig *tls.Config,
+	logger *slog.Logger,
+) (*leaderForwarder, error) {
+	if len(key) == 0 {
+		return nil, errors.New("a peer key is required to forward requests to the leader")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:errcheck // Always an *http.Transport.
+	transport.ResponseHeaderTimeout = forwardTimeout
+
+	f := &leaderForwarder{
+		resolver:   resolver,
+		reconciler: reconciler,
+		signer:     &peerSigner{key: key, now: time.Now},
+		scheme:     "http",
+		transport:  transport,
+		logger:     logger,
+	}
+	if tlsConfig != nil {
+		f.scheme = "https"
+		transport.TLSClientConfig = tlsConfig
+	}
+	return f, nil
+}
+
+// wrap serves requests with next on the leader and forwards them to the leader on followers.
+// The caller's identity is forwarded with scope, which the follower already checked, instead of
+// its credentials. Without leader election f is nil and every request is served with next.
+func (f *leaderForwarder) wrap(scope auth.Scope, next http.HandlerFunc) http.HandlerFunc {
+	if f == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if f.resolver.IsLeader() {
+			next(w, r)
+			return
+		}
+		if r.Header.Get(ForwardedHeader) != "" {
+			// Leadership moved while the request was forwarded; let the client retry instead of looping.
+			writeErrorResponse(w, http.StatusServiceUnavailable, "Not the leader, retry the request")
+			return
+		}
+		address := f.resolver.LeaderAddress()
+		if address == "" {
+			writeErrorResponse(w, http.StatusServiceUnavailable, "No leader elected, retry the request")
+			return
+		}
+
+		var assertion string
+		if identity, ok := auth.FromContext(r.Context()); ok {
+			var err error
+			if assertion, err = f.signer.sign(identity.Name, []auth.Scope{scope}); err != nil {
+				f.logger.ErrorContext(r.Context(), "Failed to sign forwarded identity", "error", err)
+				writeErrorResponse(w, http.StatusInternalServerError, "Failed to forward request to the leader")
+				return
+			}
+		}
+
+		f.logger.DebugContext(r.Context(), "Forwarding request to the leader", "path", r.URL.Path, "leader", address)
+		f.proxy(address, assertion).ServeHTTP(w, r)
+	}
+}
+
+// proxy returns a reverse proxy to the leader at address. If assertion is set, it replaces
+// the caller's credentials.
+func (f *leaderForwarder) proxy(address, assertion string) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(&url.URL{Scheme: f.scheme, Host: address})
+			pr.SetXForwarded()
+			pr.Out.Header.Set(ForwardedHeader, "true")
+			pr.Out.Header.Del(ForwardedIdentityHeader)
+			if assertion != "" {
+				pr.Out.Header.Del("Authorization")
+				pr.Out.Header.Set(ForwardedIdentityHeader, assertion)
+			}
+		},
+		Transport: f.transport,
+		ModifyResponse: func(resp *http.Response) error {
+			// Refresh the cached rule so reads on this replica see the change.
+			if resp.StatusCode < http.StatusMultipleChoices {
+				f.reconciler.Trigger(reconcile.ReasonForwarded)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			f.logger.ErrorContext(r.Context(), "Failed to forward request to the leader",
+				"path", r.URL.Path,
+				"leader", address,
+				"error", err)
+			writeErrorResponse(w, http.StatusBadGateway, "Failed to forward request to the leader")
+		},
+	}
+}
+
+// peerTLSConfig returns a TLS client config that verifies the leader's certificate against the CAs
+// in caFile, or the system roots if caFile is empty. The certificate must cover the leader's address.
+func peerTLSConfig(caFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read peer CA file: %w", err)
+	}
+	config.RootCAs = x509.NewCertPool()
+	if !config.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, errors.New("peer CA file contains no certificates")
+	}
+	return config, nil
+}
@@ -0,0 +1,273 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/auth"
+	"github.com/meyeringh/cf-switch/internal/reconcile"
+)
+
+// testPeerKey is the peer key shared by the replicas in leader tests.
+var testPeerKey = []byte("0123456789abcdef0123456789abcdef") //nolint:gochecknoglobals // Test fixture.
+
+// staticLeader implements LeaderResolver with a fixed leader.
+type staticLeader struct {
+	leader  bool
+	address string
+}
+
+func (l *staticLeader) IsLeader() bool { return l.leader }
+
+func (l *staticLeader) LeaderAddress() string { return l.address }
+
+func TestLeaderForwarding(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	var forwarded *http.Request
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"rule_id":"leader-rule","enabled":true}`))
+	}))
+	defer leader.Close()
+	leaderURL, _ := url.Parse(leader.URL)
+
+	toggle := func(
+		t *testing.T,
+		resolver *staticLeader,
+		reconciler *MockReconciler,
+		header http.Header,
+	) *httptest.ResponseRecorder {
+		t.Helper()
+		srv, err := NewServer(":0", "test-token", reconciler, logger,
+			WithLeaderForwarding(resolver, testPeerKey), WithMetrics(newMetrics()))
+		if err != nil {
+			t.Fatalf("failed to create server: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/v1/rule/enable", bytes.NewReader([]byte(`{"enabled":true}`)))
+		req.Header.Set("Authorization", "Bearer test-token")
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rr := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("leader serves changes", func(t *testing.T) {
+		forwarded = nil
+		reconciler := &MockReconciler{}
+		rr := toggle(t, &staticLeader{leader: true}, reconciler, nil)
+
+		if rr.Code != http.StatusOK || forwarded != nil || reconciler.rule == nil {
+			t.Errorf("expected the leader to toggle the rule itself, got %d: %s", rr.Code, rr.Body)
+		}
+	})
+
+	t.Run("follower forwards changes", func(t *testing.T) {
+		forwarded = nil
+		reconciler := &MockReconciler{}
+		rr := toggle(t, &staticLeader{address: leaderURL.Host}, reconciler, nil)
+
+		if rr.Code != http.StatusOK || !bytes.Contains(rr.Body.Bytes(), []byte("leader-rule")) {
+			t.Fatalf("expected the leader's response, got %d: %s", rr.Code, rr.Body)
+		}
+		if forwarded == nil || forwarded.Header.Get(ForwardedHeader) == "" {
+			t.Fatal("expected the request to be forwarded and marked")
+		}
+		if forwarded.Header.Get("Authorization") != "" || forwarded.URL.Path != "/v1/rule/enable" {
+			t.Errorf("expected the request to be forwarded without credentials, got %s %v",
+				forwarded.URL.Path, forwarded.Header)
+		}
+		signer := &peerSigner{key: testPeerKey, now: time.Now}
+		identity, err := signer.verify(forwarded.Header.Get(ForwardedIdentityHeader))
+		if err != nil {
+			t.Fatalf("expected a valid forwarded identity: %v", err)
+		}
+		if !slices.Equal(identity.Scopes, []auth.Scope{auth.ScopeRuleToggle}) {
+			t.Errorf("expected the forwarded identity to carry the checked scope, got %v", identity.Scopes)
+		}
+		if reconciler.rule != nil {
+			t.Error("expected the follower not to toggle the rule")
+		}
+		if !slices.Equal(reconciler.triggers, []string{reconcile.ReasonForwarded}) {
+			t.Errorf("expected the follower to refresh its rule, got %v", reconciler.triggers)
+		}
+	})
+
+	t.Run("no leader", func(t *testing.T) {
+		rr := toggle(t, &staticLeader{}, &MockReconciler{}, nil)
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+		}
+	})
+
+	t.Run("forwarded request on a follower", func(t *testing.T) {
+		forwarded = nil
+		rr := toggle(t, &staticLeader{address: leaderURL.Host}, &MockReconciler{},
+			http.Header{ForwardedHeader: {"true"}})
+		if rr.Code != http.StatusServiceUnavailable || forwarded != nil {
+			t.Errorf("expected status %d without forwarding again, got %d", http.StatusServiceUnavailable, rr.Code)
+		}
+	})
+
+	t.Run("unreachable leader", func(t *testing.T) {
+		rr := toggle(t, &staticLeader{address: "127.0.0.1:1"}, &MockReconciler{}, nil)
+		if rr.Code != http.StatusBadGateway {
+			t.Errorf("expected status %d, got %d", http.StatusBadGateway, rr.Code)
+		}
+	})
+}
+
+func TestLeaderForwarding_ForwardedIdentity(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	newLeader := func(t *testing.T, opts ...Option) *Server {
+		t.Helper()
+		opts = append(opts, WithLeaderForwarding(&staticLeader{leader: true}, testPeerKey), WithMetrics(newMetrics()))
+		srv, err := NewServer(":0", "test-token", &MockReconciler{}, logger, opts...)
+		if err != nil {
+			t.Fatalf("failed to create server: %v", err)
+		}
+		return srv
+	}
+
+	signer := &peerSigner{key: testPeerKey, now: time.Now}
+	sign := func(t *testing.T, signer *peerSigner, scope auth.Scope) string {
+		t.Helper()
+		value, signErr := signer.sign("cert:alice", []auth.Scope{scope})
+		if signErr != nil {
+			t.Fatalf("failed to sign identity: %v", signErr)
+		}
+		return value
+	}
+	toggle := func(srv *Server, identity string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/rule/enable", bytes.NewReader([]byte(`{"enabled":true}`)))
+		req.RemoteAddr = "10.0.0.2:1234"
+		if identity != "" {
+			req.Header.Set(ForwardedHeader, "true")
+			req.Header.Set(ForwardedIdentityHeader, identity)
+		} else {
+			req.Header.Set("Authorization", "Bearer test-token")
+		}
+		rr := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	valid := sign(t, signer, auth.ScopeRuleToggle)
+	expired := sign(t, &peerSigner{key: testPeerKey, now: func() time.Time {
+		return time.Now().Add(-2 * forwardedIdentityTTL)
+	}}, auth.ScopeRuleToggle)
+	otherKey := sign(t, &peerSigner{key: []byte("another-peer-key"), now: time.Now}, auth.ScopeRuleToggle)
+	// A payload granting another scope with the signature of the valid identity.
+	payload, _, _ := strings.Cut(sign(t, signer, auth.ScopeAdmin), ".")
+	_, signature, _ := strings.Cut(valid, ".")
+	tampered := payload + "." + signature
+
+	tests := []struct {
+		name     string
+		identity string
+		want     int
+	}{
+		{name: "valid", identity: valid, want: http.StatusOK},
+		{name: "tampered", identity: tampered, want: http.StatusUnauthorized},
+		{name: "expired", identity: expired, want: http.StatusUnauthorized},
+		{name: "other key", identity: otherKey, want: http.StatusUnauthorized},
+		{name: "other scope", identity: sign(t, signer, auth.ScopeRuleRead), want: http.StatusForbidden},
+	}
+
+	srv := newLeader(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := toggle(srv, tt.identity); rr.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, rr.Code, rr.Body)
+			}
+		})
+	}
+
+	t.Run("forwarded requests skip the client IP limit", func(t *testing.T) {
+		limited := newLeader(t, WithRateLimit(RateLimitConfig{IPRate: 1, IPBurst: 1}))
+		for i := range 3 {
+			if rr := toggle(limited, valid); rr.Code != http.StatusOK {
+				t.Fatalf("request %d: expected status %d, got %d", i, http.StatusOK, rr.Code)
+			}
+		}
+		if rr := toggle(limited, ""); rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if rr := toggle(limited, ""); rr.Code != http.StatusTooManyRequests {
+			t.Errorf("expected requests from the same IP to be limited, got %d", rr.Code)
+		}
+	})
+}
+
+func TestPeerTLSConfig(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+	otherCAFile := filepath.Join(dir, "other.crt")
+	writeFile(t, otherCAFile, newTestCA(t).pem)
+
+	// The leader's certificate covers 127.0.0.1, the address followers use to reach it.
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "leader"}, x509.ExtKeyUsageServerAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load certificate: %v", err)
+	}
+	leader := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	leader.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	leader.StartTLS()
+	defer leader.Close()
+
+	get := func(t *testing.T, caFile string) error {
+		t.Helper()
+		config, configErr := peerTLSConfig(caFile)
+		if configErr != nil {
+			t.Fatalf("failed to create peer TLS config: %v", configErr)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		resp, getErr := client.Get(leader.URL)
+		if getErr == nil {
+			resp.Body.Close()
+		}
+		return getErr
+	}
+
+	if err = get(t, caFile); err != nil {
+		t.Errorf("expected the leader to be verified against the peer CA: %v", err)
+	}
+	if err = get(t, otherCAFile); err == nil {
+		t.Error("expected a leader signed by another CA to be rejected")
+	}
+
+	emptyFile := filepath.Join(dir, "empty.crt")
+	writeFile(t, emptyFile, nil)
+	if _, err = peerTLSConfig(emptyFile); err == nil {
+		t.Error("expected an error for a CA file without certificates")
+	}
+	if _, err = peerTLSConfig(filepath.Join(dir, "missing.crt")); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+}
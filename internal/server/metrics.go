@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/pkg/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statusError labels Cloudflare API requests that received no response.
+const statusError = "error"
+
+// Metrics holds Prometheus metrics.
+type Metrics struct {
+	togglesTotal          *prometheus.CounterVec
+	apiRequestsTotal      *prometheus.CounterVec
+	rejectedRequestsTotal *prometheus.CounterVec
+	ruleStatusGauge       prometheus.Gauge
+	ruleHostnamesGauge    prometheus.Gauge
+	cfAPIHistogram        *prometheus.HistogramVec
+	cfAPIErrorsTotal      *prometheus.CounterVec
+	reconcileHistogram    *prometheus.HistogramVec
+	lastSuccessGauge      prometheus.Gauge
+	driftCorrectionsTotal prometheus.Counter
+	circuitStateGauge     *prometheus.GaugeVec
+	circuitOpensTotal     prometheus.Counter
+}
+
+// NewMetrics creates new Prometheus metrics and registers them with the default registry.
+func NewMetrics() *Metrics {
+	m := newMetrics()
+	prometheus.MustRegister(m.collectors()...)
+	return m
+}
+
+// newMetrics creates unregistered Prometheus metrics.
+func newMetrics() *Metrics {
+	return &Metrics{
+		togglesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cf_switch_toggles_total",
+				Help: "Total number of rule toggles",
+			},
+			[]string{"enabled"},
+		),
+		apiRequestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cf_switch_api_requests_total",
+				Help: "Total number of API requests",
+			},
+			[]string{"method", "path", "status"},
+		),
+		rejectedRequestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cf_switch_api_rejected_requests_total",
+				Help: "Total number of API requests rejected by authentication, lockout, or rate limits",
+			},
+			[]string{"reason"},
+		),
+		ruleStatusGauge: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cf_switch_rule_enabled",
+				Help: "Whether the Cloudflare rule is currently enabled (1) or disabled (0)",
+			},
+		),
+		ruleHostnamesGauge: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cf_switch_rule_hostnames",
+				Help: "Number of hostnames matched by the Cloudflare rule",
+			},
+		),
+		cfAPIHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "cf_switch_cloudflare_api_duration_seconds",
+				Help:    "Duration of Cloudflare API calls",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"method", "endpoint", "status"},
+		),
+		cfAPIErrorsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cf_switch_cloudflare_api_errors_total",
+				Help: "Total number of error codes returned by the Cloudflare API",
+			},
+			[]string{"endpoint", "code"},
+		),
+		reconcileHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "cf_switch_reconcile_duration_seconds",
+				Help:    "Duration of reconciliations",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"result"},
+		),
+		lastSuccessGauge: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cf_switch_reconcile_last_success_timestamp_seconds",
+				Help: "Unix time of the last successful reconciliation",
+			},
+		),
+		driftCorrectionsTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cf_switch_drift_corrections_total",
+				Help: "Total number of times the rule was changed back to match the configuration",
+			},
+		),
+		circuitStateGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cf_switch_cloudflare_circuit_state",
+				Help: "Whether the Cloudflare API circuit breaker is in the given state (1) or not (0)",
+			},
+			[]string{"state"},
+		),
+		circuitOpensTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cf_switch_cloudflare_circuit_opens_total",
+				Help: "Total number of times the Cloudflare API circuit breaker opened",
+			},
+		),
+	}
+}
+
+// collectors returns every metric for registration.
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.togglesTotal,
+		m.apiRequestsTotal,
+		m.rejectedRequestsTotal,
+		m.ruleStatusGauge,
+		m.ruleHostnamesGauge,
+		m.cfAPIHistogram,
+		m.cfAPIErrorsTotal,
+		m.reconcileHistogram,
+		m.lastSuccessGauge,
+		m.driftCorrectionsTotal,
+		m.circuitStateGauge,
+		m.circuitOpensTotal,
+	}
+}
+
+// ObserveCloudflareRequest records a Cloudflare API request. It implements cloudflare.Instrumentation.
+func (m *Metrics) ObserveCloudflareRequest(_ context.Context, info cloudflare.RequestInfo) {
+	status := statusError
+	if info.Err == nil {
+		status = strconv.Itoa(info.StatusCode)
+	}
+	m.cfAPIHistogram.WithLabelValues(info.Method, info.Operation, status).Observe(info.Duration.Seconds())
+
+	for _, code := range info.ErrorCodes {
+		m.cfAPIErrorsTotal.WithLabelValues(info.Operation, strconv.Itoa(code)).Inc()
+	}
+}
+
+// ObserveCircuitState records a Cloudflare API circuit breaker transition.
+// It can be used as cloudflare.CircuitBreakerConfig.OnStateChange.
+func (m *Metrics) ObserveCircuitState(state cloudflare.CircuitState) {
+	for _, s := range []cloudflare.CircuitState{cloudflare.CircuitClosed, cloudflare.CircuitOpen, cloudflare.CircuitHalfOpen} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		m.circuitStateGauge.WithLabelValues(string(s)).Set(value)
+	}
+	if state == cloudflare.CircuitOpen {
+		m.circuitOpensTotal.Inc()
+	}
+}
+
+// ObserveRule implements reconcile.MetricsRecorder.
+func (m *Metrics) ObserveRule(rule *types.Rule) {
+	m.setRuleEnabled(rule.Enabled)
+	m.ruleHostnamesGauge.Set(float64(len(rule.Hostnames)))
+}
+
+// ObserveReconcile implements reconcile.MetricsRecorder.
+func (m *Metrics) ObserveReconcile(duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.reconcileHistogram.WithLabelValues(result).Observe(duration.Seconds())
+
+	if err == nil {
+		m.lastSuccessGauge.SetToCurrentTime()
+	}
+}
+
+// ObserveDriftCorrection implements reconcile.MetricsRecorder.
+func (m *Metrics) ObserveDriftCorrection() {
+	m.driftCorrectionsTotal.Inc()
+}
+
+// ObserveToggle implements reconcile.MetricsRecorder.
+func (m *Metrics) ObserveToggle(enabled bool) {
+	m.togglesTotal.WithLabelValues(strconv.FormatBool(enabled)).Inc()
+}
+
+// setRuleEnabled sets the rule status metric.
+func (m *Metrics) setRuleEnabled(enabled bool) {
+	if enabled {
+		m.ruleStatusGauge.Set(1)
+	} else {
+		m.ruleStatusGauge.Set(0)
+	}
+}
@@ -0,0 +1,90 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/cloudflare"
+	"github.com/meyeringh/cf-switch/pkg/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_ObserveCloudflareRequest(t *testing.T) {
+	m := newMetrics()
+
+	m.ObserveCloudflareRequest(context.Background(), cloudflare.RequestInfo{
+		Operation:  cloudflare.OperationUpdateRule,
+		Method:     http.MethodPatch,
+		StatusCode: http.StatusForbidden,
+		ErrorCodes: []int{10000},
+		Duration:   50 * time.Millisecond,
+	})
+	m.ObserveCloudflareRequest(context.Background(), cloudflare.RequestInfo{
+		Operation: cloudflare.OperationUpdateRule,
+		Method:    http.MethodPatch,
+		Err:       errors.New("connection refused"),
+	})
+
+	if got := testutil.CollectAndCount(m.cfAPIHistogram); got != 2 {
+		t.Errorf("expected 2 histogram series, got %d", got)
+	}
+	if got := testutil.ToFloat64(m.cfAPIErrorsTotal.WithLabelValues(cloudflare.OperationUpdateRule, "10000")); got != 1 {
+		t.Errorf("expected 1 error with code 10000, got %v", got)
+	}
+}
+
+func TestMetrics_Reconciler(t *testing.T) {
+	m := newMetrics()
+
+	m.ObserveRule(&types.Rule{Enabled: true, Hostnames: []string{"a.example.com", "b.example.com"}})
+	if got := testutil.ToFloat64(m.ruleStatusGauge); got != 1 {
+		t.Errorf("expected rule enabled gauge 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.ruleHostnamesGauge); got != 2 {
+		t.Errorf("expected hostnames gauge 2, got %v", got)
+	}
+
+	m.ObserveReconcile(time.Second, errors.New("failed"))
+	if got := testutil.ToFloat64(m.lastSuccessGauge); got != 0 {
+		t.Errorf("expected no last success after a failure, got %v", got)
+	}
+
+	m.ObserveReconcile(time.Second, nil)
+	if got := testutil.ToFloat64(m.lastSuccessGauge); got <= 0 {
+		t.Errorf("expected last success timestamp, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.reconcileHistogram); got != 2 {
+		t.Errorf("expected success and failure series, got %d", got)
+	}
+
+	m.ObserveDriftCorrection()
+	if got := testutil.ToFloat64(m.driftCorrectionsTotal); got != 1 {
+		t.Errorf("expected 1 drift correction, got %v", got)
+	}
+
+	m.ObserveToggle(false)
+	if got := testutil.ToFloat64(m.togglesTotal.WithLabelValues("false")); got != 1 {
+		t.Errorf("expected 1 toggle, got %v", got)
+	}
+}
+
+func TestMetrics_ObserveCircuitState(t *testing.T) {
+	m := newMetrics()
+
+	m.ObserveCircuitState(cloudflare.CircuitClosed)
+	m.ObserveCircuitState(cloudflare.CircuitOpen)
+
+	if got := testutil.ToFloat64(m.circuitStateGauge.WithLabelValues("open")); got != 1 {
+		t.Errorf("expected open state 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.circuitStateGauge.WithLabelValues("closed")); got != 0 {
+		t.Errorf("expected closed state 0, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.circuitOpensTotal); got != 1 {
+		t.Errorf("expected 1 circuit open, got %v", got)
+	}
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+
+	"github.com/meyeringh/cf-switch/internal/auth"
+)
+
+const (
+	// Idle time after which per-client limiter state is dropped.
+	rateLimitIdleTTL = 10 * time.Minute
+	// Minimum time between sweeps of idle limiter state.
+	rateLimitSweepInterval = time.Minute
+)
+
+// Reasons for rejected API requests, used as metric labels.
+const (
+	rejectReasonAuthFailed     = "auth_failed"
+	rejectReasonLockedOut      = "locked_out"
+	rejectReasonIPRateLimit    = "ip_rate_limit"
+	rejectReasonTokenRateLimit = "token_rate_limit"
+)
+
+// RateLimitConfig configures per-client rate limits and lockout after failed authentication.
+// Zero rates or thresholds disable the corresponding check.
+type RateLimitConfig struct {
+	// IPRate and IPBurst limit requests per client IP, in requests per second.
+	IPRate  float64
+	IPBurst int
+	// TokenRate and TokenBurst limit requests per authenticated identity, in requests per second.
+	TokenRate  float64
+	TokenBurst int
+	// LockoutThreshold failed authentications within LockoutDuration lock a client IP out for LockoutDuration.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// TrustedProxies are proxies whose X-Forwarded-For header is used to find the client IP.
+	TrustedProxies []netip.Prefix
+}
+
+// RateLimiter throttles API requests per client IP and per identity and locks out clients
+// that repeatedly fail to authenticate.
+type RateLimiter struct {
+	config   RateLimitConfig
+	rejected *prometheus.CounterVec
+	logger   *slog.Logger
+	now      func() time.Time
+	// exempt reports requests that skip the client IP limits and lockout, such as requests with
+	// client certificates or forwarded by other replicas.
+	exempt func(*http.Request) bool
+
+	mu        sync.Mutex
+	clients   map[string]*clientState
+	tokens    map[string]*clientState
+	lastSweep time.Time
+}
+
+// clientState is the limiter and failure state of one client IP or identity.
+type clientState struct {
+	limiter      *rate.Limiter
+	failures     int
+	firstFailure time.Time
+	lockedUntil  time.Time
+	lastSeen     time.Time
+}
+
+// NewRateLimiter creates a rate limiter. Rejected requests are counted in rejected by reason.
+func NewRateLimiter(config RateLimitConfig, rejected *prometheus.CounterVec, logger *slog.Logger) *RateLimiter {
+	return &RateLimiter{
+		config:   config,
+		rejected: rejected,
+		logger:   logger,
+		now:      time.Now,
+		clients:  make(map[string]*clientState),
+		tokens:   make(map[string]*clientState),
+	}
+}
+
+// ParseTrustedProxies parses IP addresses and CIDR ranges of trusted proxies.
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ClientMiddleware rejects rate-limited and locked-out client IPs before authentication and records
+// responses with status 401 as failed authentication attempts. Exempt requests skip both.
+func (l *RateLimiter) ClientMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.exempt != nil && l.exempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := l.clientIP(r)
+
+		if wait, locked := l.lockedOut(ip); locked {
+			l.reject(w, r, rejectReasonLockedOut, wait, "Too many failed authentication attempts", "client_ip", ip)
+			return
+		}
+		if wait := l.reserve(l.clients, ip, l.config.IPRate, l.config.IPBurst); wait > 0 {
+			l.reject(w, r, rejectReasonIPRateLimit, wait, "Rate limit exceeded", "client_ip", ip)
+			return
+		}
+
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r)
+
+		if wrapped.statusCode == http.StatusUnauthorized {
+			l.rejected.WithLabelValues(rejectReasonAuthFailed).Inc()
+			l.recordFailure(r, ip)
+		} else {
+			l.resetFailures(ip)
+		}
+	})
+}
+
+// IdentityMiddleware rate limits requests per authenticated identity. It must run after authentication.
+func (l *RateLimiter) IdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity, ok := auth.FromContext(r.Context()); ok {
+			if wait := l.reserve(l.tokens, identity.Name, l.config.TokenRate, l.config.TokenBurst); wait > 0 {
+				l.reject(w, r, rejectReasonTokenRateLimit, wait, "Rate limit exceeded", "actor", identity.Name)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticatedBy returns a function reporting whether any of authenticators accepts a request.
+// Such requests carry credentials that cannot be guessed, like client certificates.
+func authenticatedBy(authenticators ...auth.Authenticator) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, authenticator := range authenticators {
+			if _, err := authenticator.Authenticate(r); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// reserve takes a token from the key's bucket and returns how long the caller must wait if none is left.
+func (l *RateLimiter) reserve(states map[string]*clientState, key string, limit float64, burst int) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	state := l.state(states, key, now)
+	if state.limiter == nil {
+		if burst < 1 {
+			burst = max(1, int(math.Ceil(limit)))
+		}
+		state.limiter = rate.NewLimiter(rate.Limit(limit), burst)
+	}
+
+	reservation := state.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// lockedOut reports whether the client IP is locked out and for how long.
+func (l *RateLimiter) lockedOut(ip string) (time.Duration, bool) {
+	if l.config.LockoutThreshold <= 0 {
+		return 0, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.clients[ip]
+	if !ok {
+		return 0, false
+	}
+	now := l.now()
+	if now.Before(state.lockedUntil) {
+		return state.lockedUntil.Sub(now), true
+	}
+	return 0, false
+}
+
+// recordFailure counts a failed authentication and locks the client IP out once the threshold is reached.
+func (l *RateLimiter) recordFailure(r *http.Request, ip string) {
+	if l.config.LockoutThreshold <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	state := l.state(l.clients, ip, now)
+	if state.failures == 0 || now.Sub(state.firstFailure) > l.config.LockoutDuration {
+		state.failures = 0
+		state.firstFailure = now
+	}
+	state.failures++
+
+	if state.failures >= l.config.LockoutThreshold {
+		state.lockedUntil = now.Add(l.config.LockoutDuration)
+		state.failures = 0
+		l.logger.WarnContext(r.Context(), "Locking out client after repeated authentication failures",
+			"client_ip", ip,
+			"failures", l.config.LockoutThreshold,
+			"locked_until", state.lockedUntil)
+	}
+}
+
+// resetFailures clears the failed authentication count of a client IP.
+func (l *RateLimiter) resetFailures(ip string) {
+	if l.config.LockoutThreshold <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if state, ok := l.clients[ip]; ok {
+		state.failures = 0
+	}
+}
+
+// state returns the state for key, creating it if needed, and drops idle state. l.mu must be held.
+func (l *RateLimiter) state(states map[string]*clientState, key string, now time.Time) *clientState {
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.lastSweep = now
+		ttl := max(rateLimitIdleTTL, l.config.LockoutDuration)
+		for _, m := range []map[string]*clientState{l.clients, l.tokens} {
+			for k, s := range m {
+				if now.Sub(s.lastSeen) > ttl {
+					delete(m, k)
+				}
+			}
+		}
+	}
+
+	state, ok := states[key]
+	if !ok {
+		state = &clientState{}
+		states[key] = state
+	}
+	state.lastSeen = now
+	return state
+}
+
+// reject writes a 429 response with a Retry-After header and counts it.
+func (l *RateLimiter) reject(
+	w http.ResponseWriter,
+	r *http.Request,
+	reason string,
+	wait time.Duration,
+	message string,
+	logAttrs ...any,
+) {
+	l.rejected.WithLabelValues(reason).Inc()
+	l.logger.WarnContext(r.Context(), "Rejected API request",
+		append([]any{"reason", reason, "path", r.URL.Path, "method", r.Method}, logAttrs...)...)
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeErrorResponse(w, http.StatusTooManyRequests, message)
+}
+
+// clientIP returns the IP of the client, following X-Forwarded-For through trusted proxies.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+
+	// Walk the proxy chain from the nearest hop; the first untrusted address is the client.
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0 && l.trusted(addr); i-- {
+		hop, parseErr := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if parseErr != nil {
+			break
+		}
+		addr = hop.Unmap()
+	}
+	return addr.String()
+}
+
+// trusted reports whether addr belongs to a trusted proxy.
+func (l *RateLimiter) trusted(addr netip.Addr) bool {
+	for _, prefix := range l.config.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/meyeringh/cf-switch/internal/auth"
+)
+
+// newTestRateLimiter returns a limiter with a controllable clock, wrapped around a token-authenticated handler.
+func newTestRateLimiter(t *testing.T, config RateLimitConfig) (*RateLimiter, http.Handler, *time.Time) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	authenticator, err := auth.NewTokenAuthenticator([]auth.Token{
+		{Name: "oncall", Token: "valid-token", Role: auth.RoleOperator},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+
+	rejected := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_rejected_total"}, []string{"reason"})
+	limiter := NewRateLimiter(config, rejected, logger)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler := limiter.ClientMiddleware(NewAuthMiddleware(authenticator, logger).Middleware(limiter.IdentityMiddleware(ok)))
+	return limiter, handler, &now
+}
+
+// doLimited sends an API request from remoteAddr with token and returns the recorded response.
+func doLimited(handler http.Handler, remoteAddr, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/rule", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRateLimiter_IPLimit(t *testing.T) {
+	limiter, handler, now := newTestRateLimiter(t, RateLimitConfig{IPRate: 1, IPBurst: 2})
+
+	for i := range 2 {
+		if rr := doLimited(handler, "198.51.100.1:1234", "valid-token"); rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d", i, http.StatusOK, rr.Code)
+		}
+	}
+
+	rr := doLimited(handler, "198.51.100.1:1234", "valid-token")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	// Other clients have their own bucket.
+	if rr = doLimited(handler, "198.51.100.2:1234", "other"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected other client to reach authentication, got %d", rr.Code)
+	}
+
+	*now = now.Add(time.Second)
+	if rr = doLimited(handler, "198.51.100.1:1234", "valid-token"); rr.Code != http.StatusOK {
+		t.Errorf("expected bucket to refill, got %d", rr.Code)
+	}
+
+	if got := testutil.ToFloat64(limiter.rejected.WithLabelValues(rejectReasonIPRateLimit)); got != 1 {
+		t.Errorf("expected 1 rate-limited request, got %v", got)
+	}
+}
+
+func TestRateLimiter_TokenLimit(t *testing.T) {
+	limiter, handler, _ := newTestRateLimiter(t, RateLimitConfig{TokenRate: 1, TokenBurst: 1})
+
+	if rr := doLimited(handler, "198.51.100.1:1234", "valid-token"); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	// The same token is limited from another address.
+	if rr := doLimited(handler, "198.51.100.2:1234", "valid-token"); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+
+	if got := testutil.ToFloat64(limiter.rejected.WithLabelValues(rejectReasonTokenRateLimit)); got != 1 {
+		t.Errorf("expected 1 token-limited request, got %v", got)
+	}
+}
+
+func TestRateLimiter_Lockout(t *testing.T) {
+	limiter, handler, now := newTestRateLimiter(t, RateLimitConfig{
+		LockoutThreshold: 3,
+		LockoutDuration:  time.Minute,
+	})
+	const client = "203.0.113.7:4321"
+
+	// A success resets the failure count.
+	doLimited(handler, client, "wrong")
+	doLimited(handler, client, "wrong")
+	doLimited(handler, client, "valid-token")
+
+	for range 3 {
+		if rr := doLimited(handler, client, "wrong"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	}
+
+	// The locked-out IP is refused before authentication, so a correct guess does not succeed.
+	rr := doLimited(handler, client, "valid-token")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a valid token to be refused during the lockout, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected Retry-After 60, got %q", rr.Header().Get("Retry-After"))
+	}
+	if rr = doLimited(handler, client, "wrong"); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+
+	// Exempt requests, e.g. with client certificates, still pass.
+	limiter.exempt = func(r *http.Request) bool { return r.Header.Get("X-Test-Exempt") != "" }
+	req := httptest.NewRequest(http.MethodGet, "/v1/rule", nil)
+	req.RemoteAddr = client
+	req.Header.Set("Authorization", "Bearer valid-token")
+	req.Header.Set("X-Test-Exempt", "true")
+	exempt := httptest.NewRecorder()
+	handler.ServeHTTP(exempt, req)
+	if exempt.Code != http.StatusOK {
+		t.Errorf("expected an exempt request to pass the lockout, got %d", exempt.Code)
+	}
+	limiter.exempt = nil
+
+	*now = now.Add(time.Minute)
+	if rr = doLimited(handler, client, "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected lockout to expire, got %d", rr.Code)
+	}
+
+	if got := testutil.ToFloat64(limiter.rejected.WithLabelValues(rejectReasonAuthFailed)); got != 6 {
+		t.Errorf("expected 6 failed authentications, got %v", got)
+	}
+	if got := testutil.ToFloat64(limiter.rejected.WithLabelValues(rejectReasonLockedOut)); got != 2 {
+		t.Errorf("expected 2 locked-out requests, got %v", got)
+	}
+}
+
+func TestRateLimiter_ClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	limiter := NewRateLimiter(RateLimitConfig{TrustedProxies: proxies}, nil, slog.Default())
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"direct client", "198.51.100.1:1234", "", "198.51.100.1"},
+		{"untrusted proxy is ignored", "198.51.100.1:1234", "203.0.113.9", "198.51.100.1"},
+		{"trusted proxy", "10.1.2.3:1234", "203.0.113.9", "203.0.113.9"},
+		{"spoofed hops are ignored", "10.1.2.3:1234", "1.1.1.1, 203.0.113.9, 192.0.2.1", "203.0.113.9"},
+		{"trusted proxy without header", "10.1.2.3:1234", "", "10.1.2.3"},
+		{"invalid hop", "10.1.2.3:1234", "unknown", "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/rule", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := limiter.clientIP(req); got != tt.expected {
+				t.Errorf("expected client IP %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err = ParseTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Error("expected error for invalid proxy")
+	}
+	if proxies[1] != netip.MustParsePrefix("192.0.2.1/32") {
+		t.Errorf("expected single address to become a /32, got %s", proxies[1])
+	}
+}
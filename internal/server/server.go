@@ -2,13 +2,16 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/meyeringh/cf-switch/internal/auth"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 const (
@@ -16,80 +19,211 @@ const (
 	readTimeout  = 30 * time.Second
 	writeTimeout = 30 * time.Second
 	idleTimeout  = 120 * time.Second
+
+	// DefaultTokenName names the generated API token, which is granted every scope.
+	DefaultTokenName = "api-token"
+	// DefaultTokenGracePeriod is how long replaced API tokens remain valid.
+	DefaultTokenGracePeriod = 5 * time.Minute
 )
 
 // Server represents the HTTP server.
 type Server struct {
-	httpServer *http.Server
-	logger     *slog.Logger
-	metrics    *Metrics
-}
-
-// Metrics holds Prometheus metrics.
-type Metrics struct {
-	togglesTotal     *prometheus.CounterVec
-	apiRequestsTotal *prometheus.CounterVec
-	ruleStatusGauge  prometheus.Gauge
-	cfAPIHistogram   *prometheus.HistogramVec
-}
-
-// NewMetrics creates new Prometheus metrics.
-func NewMetrics() *Metrics {
-	m := &Metrics{
-		togglesTotal: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "cf_switch_toggles_total",
-				Help: "Total number of rule toggles",
-			},
-			[]string{"enabled"},
-		),
-		apiRequestsTotal: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "cf_switch_api_requests_total",
-				Help: "Total number of API requests",
-			},
-			[]string{"method", "path", "status"},
-		),
-		ruleStatusGauge: prometheus.NewGauge(
-			prometheus.GaugeOpts{
-				Name: "cf_switch_rule_enabled",
-				Help: "Whether the Cloudflare rule is currently enabled (1) or disabled (0)",
-			},
-		),
-		cfAPIHistogram: prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "cf_switch_cloudflare_api_duration_seconds",
-				Help:    "Duration of Cloudflare API calls",
-				Buckets: prometheus.DefBuckets,
-			},
-			[]string{"method", "endpoint"},
-		),
-	}
-
-	prometheus.MustRegister(m.togglesTotal)
-	prometheus.MustRegister(m.apiRequestsTotal)
-	prometheus.MustRegister(m.ruleStatusGauge)
-	prometheus.MustRegister(m.cfAPIHistogram)
-
-	return m
+	httpServer  *http.Server
+	logger      *slog.Logger
+	tokens      *auth.TokenAuthenticator
+	gracePeriod time.Duration
+}
+
+// Option configures optional server features.
+type Option func(*options)
+
+// options holds optional server features.
+type options struct {
+	alertmanager   *AlertmanagerConfig
+	slack          *SlackConfig
+	tokens         []auth.Token
+	authenticators []auth.Authenticator
+	tls            *TLSConfig
+	gracePeriod    time.Duration
+	rotator        TokenRotator
+	rateLimit      RateLimitConfig
+	maxAge         time.Duration
+	metrics        *Metrics
+	leader         LeaderResolver
+	peerKey        []byte
+}
+
+// WithAlertmanager enables the Alertmanager webhook receiver.
+func WithAlertmanager(config *AlertmanagerConfig) Option {
+	return func(o *options) {
+		o.alertmanager = config
+	}
+}
+
+// WithSlack enables the Slack slash-command endpoint.
+func WithSlack(config SlackConfig) Option {
+	return func(o *options) {
+		o.slack = &config
+	}
+}
+
+// WithTokens adds named API tokens with restricted scopes alongside the default token.
+func WithTokens(tokens []auth.Token) Option {
+	return func(o *options) {
+		o.tokens = append(o.tokens, tokens...)
+	}
+}
+
+// WithAuthenticator adds an authentication method that is tried before the API tokens.
+func WithAuthenticator(authenticator auth.Authenticator) Option {
+	return func(o *options) {
+		o.authenticators = append(o.authenticators, authenticator)
+	}
+}
+
+// WithTLS serves HTTPS, optionally verifying client certificates.
+// Verified client certificates authenticate requests with the scopes in config.ClientScopes.
+func WithTLS(config TLSConfig) Option {
+	return func(o *options) {
+		o.tls = &config
+	}
+}
+
+// WithTokenGracePeriod sets how long replaced API tokens remain valid (default DefaultTokenGracePeriod).
+func WithTokenGracePeriod(gracePeriod time.Duration) Option {
+	return func(o *options) {
+		o.gracePeriod = gracePeriod
+	}
+}
+
+// WithTokenRotator enables POST /v1/admin/rotate-token, which stores a new default token with rotator.
+func WithTokenRotator(rotator TokenRotator) Option {
+	return func(o *options) {
+		o.rotator = rotator
+	}
+}
+
+// WithRateLimit throttles /v1/ requests per client IP and identity and locks out clients
+// after repeated authentication failures.
+func WithRateLimit(config RateLimitConfig) Option {
+	return func(o *options) {
+		o.rateLimit = config
+	}
+}
+
+// WithReadinessMaxReconcileAge fails /readyz once the last successful reconcile is older than maxAge.
+func WithReadinessMaxReconcileAge(maxAge time.Duration) Option {
+	return func(o *options) {
+		o.maxAge = maxAge
+	}
+}
+
+// WithMetrics records server metrics in metrics instead of newly registered ones,
+// so they can be shared with the Cloudflare client and reconciler.
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
+
+// WithLeaderForwarding forwards requests that change the rule to the leader while this replica is a follower.
+// The caller's identity is forwarded signed with peerKey, which must be the same on all replicas.
+func WithLeaderForwarding(resolver LeaderResolver, peerKey []byte) Option {
+	return func(o *options) {
+		o.leader = resolver
+		o.peerKey = peerKey
+	}
 }
 
 // NewServer creates a new HTTP server.
-func NewServer(addr string, authToken string, reconciler RuleReconciler, logger *slog.Logger) *Server {
-	metrics := NewMetrics()
+// The authToken is granted every scope; additional tokens are added with WithTokens.
+func NewServer(
+	addr string,
+	authToken string,
+	reconciler RuleReconciler,
+	logger *slog.Logger,
+	opts ...Option,
+) (*Server, error) {
+	o := options{gracePeriod: DefaultTokenGracePeriod}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	tokenAuthenticator, err := auth.NewTokenAuthenticator(serverTokens(authToken, o.tokens))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API tokens: %w", err)
+	}
+
+	var (
+		tlsConfig *tls.Config
+		reloader  *tlsReloader
+		// Authenticators whose requests skip the client IP limits and lockout.
+		exempt []auth.Authenticator
+	)
+	if o.tls != nil {
+		var tlsErr error
+		reloader, tlsErr = newTLSReloader(*o.tls, logger)
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+		tlsConfig = reloader.serverConfig()
+
+		if o.tls.ClientCAFile != "" {
+			certAuthenticator := auth.NewCertificateAuthenticator(o.tls.ClientScopes)
+			o.authenticators = append([]auth.Authenticator{certAuthenticator}, o.authenticators...)
+			exempt = append(exempt, certAuthenticator)
+		}
+	}
+
+	// Followers forward changes to the leader, which authenticates them by the forwarded identity.
+	var forwarder *leaderForwarder
+	if o.leader != nil {
+		var peerTLS *tls.Config
+		if o.tls != nil {
+			if peerTLS, err = peerTLSConfig(o.tls.PeerCAFile); err != nil {
+				return nil, err
+			}
+		}
+		if forwarder, err = newLeaderForwarder(o.leader, reconciler, o.peerKey, peerTLS, logger); err != nil {
+			return nil, err
+		}
+		o.authenticators = append([]auth.Authenticator{forwarder.signer}, o.authenticators...)
+		// Forwarded requests come from the follower's IP and were already limited by the follower.
+		exempt = append(exempt, forwarder.signer)
+	}
+
+	// API tokens remain the fallback for every other authentication method.
+	authenticator := auth.NewChainAuthenticator(append(o.authenticators, tokenAuthenticator)...)
+
+	metrics := o.metrics
+	if metrics == nil {
+		metrics = NewMetrics()
+	}
 
 	mux := http.NewServeMux()
 
 	// Create handlers.
-	authMiddleware := NewAuthMiddleware(authToken, logger)
+	authMiddleware := NewAuthMiddleware(authenticator, logger)
 	ruleHandler := NewRuleHandler(reconciler, logger)
-	healthHandler := NewHealthHandler(logger)
+	healthHandler := NewHealthHandler(reconciler, o.maxAge, logger)
 
 	// Health endpoints (no auth required).
 	mux.HandleFunc("/healthz", healthHandler.Health)
 	mux.HandleFunc("/readyz", healthHandler.Ready)
 	mux.Handle("/metrics", promhttp.Handler())
 
+	// Slack slash commands authenticate with request signatures instead of the API token.
+	if o.slack != nil {
+		slackHandler := NewSlackHandler(reconciler, *o.slack, logger)
+		mux.HandleFunc("/integrations/slack/command", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+				return
+			}
+			forwarder.wrap(auth.ScopeRuleToggle, slackHandler.Command)(w, r)
+		})
+	}
+
 	// API endpoints (auth required).
 	apiMux := http.NewServeMux()
 	apiMux.HandleFunc("/v1/rule", func(w http.ResponseWriter, r *http.Request) {
@@ -97,7 +231,7 @@ func NewServer(addr string, authToken string, reconciler RuleReconciler, logger
 			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
-		ruleHandler.GetRule(w, r)
+		RequireScope(auth.ScopeRuleRead, logger, ruleHandler.GetRule)(w, r)
 	})
 
 	apiMux.HandleFunc("/v1/rule/enable", func(w http.ResponseWriter, r *http.Request) {
@@ -105,7 +239,7 @@ func NewServer(addr string, authToken string, reconciler RuleReconciler, logger
 			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
-		ruleHandler.ToggleRule(w, r)
+		RequireScope(auth.ScopeRuleToggle, logger, forwarder.wrap(auth.ScopeRuleToggle, ruleHandler.ToggleRule))(w, r)
 	})
 
 	apiMux.HandleFunc("/v1/rule/hosts", func(w http.ResponseWriter, r *http.Request) {
@@ -113,15 +247,75 @@ func NewServer(addr string, authToken string, reconciler RuleReconciler, logger
 			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
-		ruleHandler.UpdateHosts(w, r)
+		RequireScope(auth.ScopeRuleHosts, logger, forwarder.wrap(auth.ScopeRuleHosts, ruleHandler.UpdateHosts))(w, r)
+	})
+
+	apiMux.HandleFunc("/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		RequireScope(auth.ScopeRuleRead, logger, healthHandler.Status)(w, r)
 	})
 
-	// Apply auth middleware to API routes.
-	mux.Handle("/v1/", authMiddleware.Middleware(apiMux))
+	apiMux.HandleFunc("/v1/reconcile", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		RequireScope(auth.ScopeRuleToggle, logger, forwarder.wrap(auth.ScopeRuleToggle, ruleHandler.TriggerReconcile))(w, r)
+	})
+
+	apiMux.HandleFunc("/v1/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		RequireScope(auth.ScopeRuleRead, logger, ruleHandler.StreamEvents)(w, r)
+	})
+
+	if o.alertmanager != nil {
+		alertmanagerHandler := NewAlertmanagerHandler(reconciler, o.alertmanager, logger)
+		apiMux.HandleFunc("/v1/integrations/alertmanager", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+				return
+			}
+			RequireScope(auth.ScopeRuleToggle, logger, forwarder.wrap(auth.ScopeRuleToggle, alertmanagerHandler.Receive))(w, r)
+		})
+	}
+
+	if o.rotator != nil {
+		adminHandler := NewAdminHandler(o.rotator, tokenAuthenticator, o.gracePeriod, logger)
+		apiMux.HandleFunc("/v1/admin/rotate-token", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+				return
+			}
+			RequireScope(auth.ScopeAdmin, logger, adminHandler.RotateToken)(w, r)
+		})
+	}
+
+	// Apply auth middleware to API routes, with rate limits around and after authentication.
+	// Without WithRateLimit the limiter only counts failed authentications.
+	limiter := NewRateLimiter(o.rateLimit, metrics.rejectedRequestsTotal, logger)
+	if len(exempt) > 0 {
+		// Client certificates and forwarded identities cannot be guessed, so clients sharing an IP with
+		// an attacker keep working.
+		limiter.exempt = authenticatedBy(exempt...)
+	}
+	mux.Handle("/v1/", limiter.ClientMiddleware(authMiddleware.Middleware(limiter.IdentityMiddleware(apiMux))))
 
 	// Apply metrics middleware to all routes.
 	handler := metricsMiddleware(mux, metrics, logger)
 
+	// Trace requests, continuing W3C trace contexts sent by callers.
+	handler = otelhttp.NewHandler(handler, "cf-switch",
+		otelhttp.WithFilter(tracedRequest),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}))
+
 	server := &Server{
 		httpServer: &http.Server{
 			Addr:         addr,
@@ -129,16 +323,38 @@ func NewServer(addr string, authToken string, reconciler RuleReconciler, logger
 			ReadTimeout:  readTimeout,
 			WriteTimeout: writeTimeout,
 			IdleTimeout:  idleTimeout,
+			TLSConfig:    tlsConfig,
 		},
-		logger:  logger,
-		metrics: metrics,
+		logger:      logger,
+		tokens:      tokenAuthenticator,
+		gracePeriod: o.gracePeriod,
+	}
+
+	return server, nil
+}
+
+// UpdateTokens atomically replaces the default and named API tokens, e.g. after the auth secret changed.
+// Replaced token values remain valid for the grace period.
+func (s *Server) UpdateTokens(authToken string, tokens []auth.Token) error {
+	if err := s.tokens.Update(serverTokens(authToken, tokens), s.gracePeriod); err != nil {
+		return fmt.Errorf("invalid API tokens: %w", err)
 	}
+	return nil
+}
 
-	return server
+// serverTokens returns the default token, granted every scope, followed by the named tokens.
+func serverTokens(authToken string, tokens []auth.Token) []auth.Token {
+	return append([]auth.Token{{Name: DefaultTokenName, Token: authToken, Role: auth.RoleAdmin}}, tokens...)
 }
 
-// Start starts the HTTP server.
+// Start starts the HTTP server, serving HTTPS if TLS is configured.
 func (s *Server) Start() error {
+	if s.httpServer.TLSConfig != nil {
+		s.logger.Info("Starting HTTPS server", "addr", s.httpServer.Addr)
+		// Certificates come from the TLS config so they can be reloaded.
+		return s.httpServer.ListenAndServeTLS("", "")
+	}
+
 	s.logger.Info("Starting HTTP server", "addr", s.httpServer.Addr)
 	return s.httpServer.ListenAndServe()
 }
@@ -149,12 +365,13 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
 
-// UpdateRuleMetrics updates the rule status metric.
-func (s *Server) UpdateRuleMetrics(enabled bool) {
-	if enabled {
-		s.metrics.ruleStatusGauge.Set(1)
-	} else {
-		s.metrics.ruleStatusGauge.Set(0)
+// tracedRequest reports whether a request is traced; health checks and scrapes are not.
+func tracedRequest(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/healthz", "/readyz", "/metrics":
+		return false
+	default:
+		return true
 	}
 }
 
@@ -198,3 +415,8 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/audit"
+	"github.com/meyeringh/cf-switch/internal/reconcile"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+const (
+	// Maximum accepted age of a Slack request timestamp.
+	slackMaxRequestAge = 5 * time.Minute
+	// Maximum accepted size of a Slack request body.
+	slackMaxBodyBytes = 64 << 10
+
+	// Time allowed for a change and the follow-up message to response_url.
+	slackCommandTimeout = 2 * time.Minute
+	// HTTP timeout for posting a follow-up message to response_url.
+	slackResponseTimeout = 10 * time.Second
+
+	// Slack response visibility.
+	slackResponseEphemeral = "ephemeral"
+	slackResponseInChannel = "in_channel"
+
+	slackUsage = "Usage: `/cfswitch status | on | off | hosts list | hosts add <host>... | hosts remove <host>...`"
+)
+
+// SlackConfig configures the Slack slash-command integration.
+type SlackConfig struct {
+	// SigningSecret verifies that requests come from Slack.
+	SigningSecret string
+	// AllowedUserIDs are the Slack user IDs allowed to run mutating commands.
+	AllowedUserIDs []string
+	// AllowAllUsers allows every member of the workspace to run mutating commands.
+	AllowAllUsers bool
+}
+
+// SlackResponse is the JSON reply to a slash command.
+type SlackResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// slackUserError is a problem with a command that is safe to show in Slack.
+type slackUserError string
+
+func (e slackUserError) Error() string {
+	return string(e)
+}
+
+// SlackHandler handles Slack slash commands.
+// Slack expects a reply within three seconds, so changes are acknowledged right away
+// and their result is posted to the command's response_url once it is known.
+type SlackHandler struct {
+	reconciler RuleReconciler
+	config     SlackConfig
+	logger     *slog.Logger
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewSlackHandler creates a new Slack slash-command handler.
+func NewSlackHandler(reconciler RuleReconciler, config SlackConfig, logger *slog.Logger) *SlackHandler {
+	return &SlackHandler{
+		reconciler: reconciler,
+		config:     config,
+		logger:     logger,
+		httpClient: &http.Client{Timeout: slackResponseTimeout},
+		now:        time.Now,
+	}
+}
+
+// Command handles POST /integrations/slack/command.
+func (h *SlackHandler) Command(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, slackMaxBodyBytes))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if verifyErr := h.verify(r.Header, body); verifyErr != nil {
+		h.logger.Warn("Rejected Slack request", "error", verifyErr, "remote_addr", r.RemoteAddr)
+		writeErrorResponse(w, http.StatusUnauthorized, "Invalid Slack signature")
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID := form.Get("user_id")
+	ctx := audit.WithActor(r.Context(), "slack:"+form.Get("user_name"))
+
+	args := strings.Fields(strings.ToLower(form.Get("text")))
+	writeJSONResponse(w, http.StatusOK, h.dispatch(ctx, userID, args, form.Get("response_url")))
+}
+
+// verify checks the Slack request signature and timestamp.
+func (h *SlackHandler) verify(header http.Header, body []byte) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return errors.New("missing signature headers")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	age := h.now().Sub(time.Unix(seconds, 0))
+	if age > slackMaxRequestAge || age < -slackMaxRequestAge {
+		return fmt.Errorf("timestamp outside allowed window: %s", age)
+	}
+
+	expected := "v0=" + SlackSignature(h.config.SigningSecret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// dispatch runs a parsed slash command and builds the reply. Changes run in the background
+// and report to responseURL; without a responseURL they run before replying.
+func (h *SlackHandler) dispatch(ctx context.Context, userID string, args []string, responseURL string) SlackResponse {
+	if len(args) == 0 || args[0] == "help" {
+		return SlackResponse{ResponseType: slackResponseEphemeral, Text: slackUsage}
+	}
+
+	command := args[0]
+	switch {
+	case command == "status" || (command == "hosts" && len(args) > 1 && args[1] == "list"):
+		return h.status(ctx)
+	case command == "hosts" && (len(args) < 3 || (args[1] != "add" && args[1] != "remove")):
+		return slackError("Usage: `hosts add|remove <host>...`")
+	case command != "hosts" && !slices.Contains([]string{"on", "enable", "off", "disable"}, command):
+		return SlackResponse{ResponseType: slackResponseEphemeral, Text: "Unknown command `" + command + "`\n" + slackUsage}
+	}
+
+	if !h.config.AllowAllUsers && !slices.Contains(h.config.AllowedUserIDs, userID) {
+		h.logger.WarnContext(ctx, "Slack user not allowed to change the rule", "user_id", userID, "command", command)
+		return slackError("You are not allowed to change cf-switch")
+	}
+
+	if responseURL == "" {
+		return h.run(ctx, userID, args)
+	}
+
+	go func() {
+		runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), slackCommandTimeout)
+		defer cancel()
+		h.respond(runCtx, responseURL, h.run(runCtx, userID, args))
+	}()
+
+	return SlackResponse{
+		ResponseType: slackResponseEphemeral,
+		Text:         "Running `" + strings.Join(args, " ") + "`...",
+	}
+}
+
+// status replies with the current rule.
+func (h *SlackHandler) status(ctx context.Context) SlackResponse {
+	rule, err := h.reconciler.GetCurrentRule(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to get current rule", "error", err)
+		return slackError("Failed to get rule")
+	}
+	return SlackResponse{ResponseType: slackResponseEphemeral, Text: formatSlackRule(rule)}
+}
+
+// run applies a validated mutating command and builds the reply.
+func (h *SlackHandler) run(ctx context.Context, userID string, args []string) SlackResponse {
+	var (
+		rule *types.Rule
+		err  error
+	)
+	switch args[0] {
+	case "on", "enable":
+		rule, err = h.reconciler.ToggleRule(ctx, true)
+	case "off", "disable":
+		rule, err = h.reconciler.ToggleRule(ctx, false)
+	default:
+		rule, err = h.updateHosts(ctx, args[1], args[2:])
+	}
+
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Slack command failed", "command", strings.Join(args, " "), "error", err)
+		return slackFailure(err)
+	}
+
+	return SlackResponse{
+		ResponseType: slackResponseInChannel,
+		Text:         fmt.Sprintf("<@%s> ran `%s`\n%s", userID, strings.Join(args, " "), formatSlackRule(rule)),
+	}
+}
+
+// respond posts a delayed reply to the response_url of a slash command.
+func (h *SlackHandler) respond(ctx context.Context, responseURL string, response SlackResponse) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to encode Slack response", "error", err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Invalid Slack response_url", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to post Slack response", "error", err)
+		return
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			h.logger.WarnContext(ctx, "Failed to close Slack response body", "error", closeErr)
+		}
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		h.logger.ErrorContext(ctx, "Slack rejected the response", "status", resp.StatusCode)
+	}
+}
+
+// updateHosts applies a "hosts add|remove" command to the current hostnames.
+func (h *SlackHandler) updateHosts(ctx context.Context, operation string, hosts []string) (*types.Rule, error) {
+	current, err := h.reconciler.GetCurrentRule(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule: %w", err)
+	}
+
+	hostnames := slices.Clone(current.Hostnames)
+	for _, host := range hosts {
+		if operation == "add" {
+			if !slices.Contains(hostnames, host) {
+				hostnames = append(hostnames, host)
+			}
+		} else {
+			hostnames = slices.DeleteFunc(hostnames, func(existing string) bool { return existing == host })
+		}
+	}
+
+	if len(hostnames) == 0 {
+		return nil, slackUserError("Cannot remove the last hostname")
+	}
+
+	return h.reconciler.UpdateHosts(ctx, hostnames)
+}
+
+// SlackSignature returns the hex HMAC-SHA256 Slack expects for a request.
+func SlackSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// formatSlackRule renders the rule state as Slack mrkdwn.
+func formatSlackRule(rule *types.Rule) string {
+	state := ":large_green_circle: Blocking is *DISABLED*"
+	if rule.Enabled {
+		state = ":red_circle: Blocking is *ENABLED*"
+	}
+
+	hosts := make([]string, 0, len(rule.Hostnames))
+	for _, host := range rule.Hostnames {
+		hosts = append(hosts, "`"+host+"`")
+	}
+
+	return fmt.Sprintf("%s\nHosts: %s\nRule: `%s` (version %d)",
+		state, strings.Join(hosts, ", "), rule.ID, rule.Version)
+}
+
+// slackError builds an ephemeral error reply.
+func slackError(message string) SlackResponse {
+	return SlackResponse{ResponseType: slackResponseEphemeral, Text: ":warning: " + message}
+}
+
+// slackFailure builds the reply for a failed change. Details stay in the logs so that
+// Cloudflare and internal error text is not posted to Slack.
+func slackFailure(err error) SlackResponse {
+	var userErr slackUserError
+	switch {
+	case errors.As(err, &userErr):
+		return slackError(userErr.Error())
+	case errors.Is(err, reconcile.ErrNoHostnames):
+		return slackError("No valid hostnames provided")
+	case errors.Is(err, reconcile.ErrNotLeader), errors.Is(err, reconcile.ErrRuleNotInitialized):
+		return slackError("cf-switch is not ready, try again shortly")
+	default:
+		return slackError("The command failed, see the cf-switch logs for details")
+	}
+}
@@ -0,0 +1,198 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSlackRequest(t *testing.T, secret string, timestamp time.Time, form url.Values) *http.Request {
+	t.Helper()
+
+	body := form.Encode()
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+
+	req := httptest.NewRequest(http.MethodPost, "/integrations/slack/command", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+SlackSignature(secret, ts, []byte(body)))
+	return req
+}
+
+func TestSlackHandler_Command(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	const secret = "slack-signing-secret"
+
+	reconciler := &MockReconciler{}
+	handler := NewSlackHandler(reconciler, SlackConfig{
+		SigningSecret:  secret,
+		AllowedUserIDs: []string{"U-ONCALL"},
+	}, logger)
+
+	run := func(userID, text string) SlackResponse {
+		form := url.Values{"user_id": {userID}, "user_name": {"oncall"}, "text": {text}}
+		rr := httptest.NewRecorder()
+		handler.Command(rr, newSlackRequest(t, secret, time.Now(), form))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response SlackResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return response
+	}
+
+	if resp := run("U-ONCALL", "status"); !strings.Contains(resp.Text, "DISABLED") ||
+		resp.ResponseType != slackResponseEphemeral {
+		t.Errorf("unexpected status response: %+v", resp)
+	}
+
+	if resp := run("U-ONCALL", "on"); !strings.Contains(resp.Text, "ENABLED") ||
+		resp.ResponseType != slackResponseInChannel {
+		t.Errorf("unexpected on response: %+v", resp)
+	}
+
+	if resp := run("U-ONCALL", "hosts add New.Example.com"); !strings.Contains(resp.Text, "`new.example.com`") ||
+		!strings.Contains(resp.Text, "`test.com`") {
+		t.Errorf("expected both hosts after add, got %+v", resp)
+	}
+
+	if resp := run("U-ONCALL", "hosts remove test.com"); strings.Contains(resp.Text, "`test.com`") {
+		t.Errorf("expected test.com to be removed, got %+v", resp)
+	}
+
+	if resp := run("U-OTHER", "off"); !strings.Contains(resp.Text, "not allowed") {
+		t.Errorf("expected unauthorized user to be rejected, got %+v", resp)
+	}
+
+	if resp := run("U-ONCALL", "explode"); !strings.Contains(resp.Text, "Unknown command") {
+		t.Errorf("expected unknown command reply, got %+v", resp)
+	}
+}
+
+func TestSlackHandler_RespondsLater(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError + 1,
+	}))
+
+	const secret = "slack-signing-secret"
+
+	responses := make(chan SlackResponse, 1)
+	slack := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response SlackResponse
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			t.Errorf("failed to decode delayed response: %v", err)
+		}
+		responses <- response
+	}))
+	defer slack.Close()
+
+	reconciler := &MockReconciler{}
+	handler := NewSlackHandler(reconciler, SlackConfig{SigningSecret: secret, AllowAllUsers: true}, logger)
+	handler.httpClient = slack.Client()
+
+	run := func(text string) (SlackResponse, SlackResponse) {
+		form := url.Values{"user_id": {"U-ANY"}, "text": {text}, "response_url": {slack.URL + "/commands/1"}}
+		rr := httptest.NewRecorder()
+		handler.Command(rr, newSlackRequest(t, secret, time.Now(), form))
+
+		var immediate SlackResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &immediate); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+
+		select {
+		case delayed := <-responses:
+			return immediate, delayed
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the delayed response")
+			return immediate, SlackResponse{}
+		}
+	}
+
+	immediate, delayed := run("on")
+	if !strings.Contains(immediate.Text, "Running `on`") || immediate.ResponseType != slackResponseEphemeral {
+		t.Errorf("expected an immediate acknowledgement, got %+v", immediate)
+	}
+	if !strings.Contains(delayed.Text, "ENABLED") || delayed.ResponseType != slackResponseInChannel {
+		t.Errorf("expected the result in the channel, got %+v", delayed)
+	}
+
+	// Error details stay in the logs.
+	reconciler.toggleErr = errors.New("cloudflare said: zone 1234 is locked")
+	if _, delayed = run("off"); strings.Contains(delayed.Text, "zone 1234") || !strings.Contains(delayed.Text, "logs") {
+		t.Errorf("expected a generic error message, got %+v", delayed)
+	}
+}
+
+func TestSlackHandler_RequiresAllowedUsers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	const secret = "slack-signing-secret"
+
+	// Without allowed users nobody may change the rule.
+	handler := NewSlackHandler(&MockReconciler{}, SlackConfig{SigningSecret: secret}, logger)
+
+	form := url.Values{"user_id": {"U-ANY"}, "text": {"on"}}
+	rr := httptest.NewRecorder()
+	handler.Command(rr, newSlackRequest(t, secret, time.Now(), form))
+
+	if !strings.Contains(rr.Body.String(), "not allowed") {
+		t.Errorf("expected the change to be rejected, got %s", rr.Body.String())
+	}
+}
+
+func TestSlackHandler_Verify(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	handler := NewSlackHandler(&MockReconciler{}, SlackConfig{SigningSecret: "secret"}, logger)
+	form := url.Values{"user_id": {"U1"}, "text": {"status"}}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{
+			name: "wrong secret",
+			req:  newSlackRequest(t, "other-secret", time.Now(), form),
+		},
+		{
+			name: "stale timestamp",
+			req:  newSlackRequest(t, "secret", time.Now().Add(-10*time.Minute), form),
+		},
+		{
+			name: "missing headers",
+			req:  httptest.NewRequest(http.MethodPost, "/integrations/slack/command", strings.NewReader(form.Encode())),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.Command(rr, tt.req)
+
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+			}
+		})
+	}
+}
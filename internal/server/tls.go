@@ -0,0 +1,170 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/auth"
+)
+
+const (
+	// Minimum time between checks for changed certificate files.
+	tlsReloadCheckInterval = 10 * time.Second
+)
+
+// TLSConfig configures native TLS serving.
+type TLSConfig struct {
+	// CertFile and KeyFile hold the PEM server certificate and key. They are reloaded when changed.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds PEM CA certificates used to verify client certificates. Empty disables mTLS.
+	ClientCAFile string
+	// RequireClientCert rejects connections without a valid client certificate.
+	// Otherwise client certificates are verified if presented.
+	RequireClientCert bool
+	// ClientScopes maps "cn:", "ou:", and "o:" subject keys of client certificates to scopes.
+	ClientScopes map[string][]auth.Scope
+	// PeerCAFile holds PEM CA certificates used to verify the leader when forwarding requests.
+	// Empty uses the system roots. The leader's certificate must cover its pod IP.
+	PeerCAFile string
+}
+
+// tlsReloader serves the current certificate and client CAs, reloading them when the files change.
+type tlsReloader struct {
+	config TLSConfig
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+// newTLSReloader loads the configured files.
+func newTLSReloader(config TLSConfig, logger *slog.Logger) (*tlsReloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("TLS certificate and key files are required")
+	}
+	if config.RequireClientCert && config.ClientCAFile == "" {
+		return nil, errors.New("a client CA file is required to require client certificates")
+	}
+
+	r := &tlsReloader{config: config, logger: logger}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+// serverConfig returns a TLS config that resolves certificates per handshake.
+func (r *tlsReloader) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.configForClient,
+		GetCertificate:     r.certificate,
+	}
+}
+
+// certificate returns the current server certificate.
+func (r *tlsReloader) certificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+// configForClient returns the TLS config for a handshake, reloading changed files first.
+func (r *tlsReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= tlsReloadCheckInterval {
+		r.lastCheck = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				r.logger.Error("Failed to reload TLS files, keeping previous certificate", "error", err)
+			} else {
+				r.logger.Info("Reloaded TLS certificate", "cert_file", r.config.CertFile)
+			}
+		}
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.clientCAs != nil {
+		config.ClientCAs = r.clientCAs
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if r.config.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return config, nil
+}
+
+// changed reports whether any configured file has a new modification time. r.mu must be held.
+func (r *tlsReloader) changed() bool {
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			r.logger.Warn("Failed to stat TLS file", "path", path, "error", err)
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads the certificate, key, and client CAs. r.mu must be held or r not yet shared.
+func (r *tlsReloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, readErr := os.ReadFile(r.config.ClientCAFile)
+		if readErr != nil {
+			return fmt.Errorf("failed to read client CA file: %w", readErr)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("client CA file contains no certificates")
+		}
+	}
+
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return nil
+}
+
+// files returns the configured file paths.
+func (r *tlsReloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
@@ -0,0 +1,228 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meyeringh/cf-switch/internal/audit"
+	"github.com/meyeringh/cf-switch/internal/auth"
+)
+
+// testCA issues certificates for TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM certificate and key for subject.
+func (ca *testCA) issue(t *testing.T, subject pkix.Name, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestTLSReloader_ReloadsChangedCertificate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "first"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+
+	reloader, err := newTLSReloader(TLSConfig{CertFile: certFile, KeyFile: keyFile}, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	servedCN := func() string {
+		config, configErr := reloader.configForClient(nil)
+		if configErr != nil {
+			t.Fatalf("unexpected error: %v", configErr)
+		}
+		leaf, _ := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+		return leaf.Subject.CommonName
+	}
+
+	if cn := servedCN(); cn != "first" {
+		t.Fatalf("expected first certificate, got %s", cn)
+	}
+
+	certPEM, keyPEM = ca.issue(t, pkix.Name{CommonName: "second"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+
+	// Changes are only picked up after the check interval.
+	if cn := servedCN(); cn != "first" {
+		t.Errorf("expected reload to wait for the check interval, got %s", cn)
+	}
+
+	reloader.lastCheck = time.Time{}
+	if cn := servedCN(); cn != "second" {
+		t.Errorf("expected reloaded certificate, got %s", cn)
+	}
+
+	// A broken file keeps the previous certificate.
+	writeFile(t, certFile, []byte("garbage"))
+	os.Chtimes(certFile, future.Add(time.Minute), future.Add(time.Minute))
+	reloader.lastCheck = time.Time{}
+	if cn := servedCN(); cn != "second" {
+		t.Errorf("expected previous certificate after failed reload, got %s", cn)
+	}
+}
+
+func TestTLS_ClientCertificateIdentity(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "cf-switch"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	writeFile(t, caFile, ca.pem)
+
+	reloader, err := newTLSReloader(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tokenAuthenticator, err := auth.NewTokenAuthenticator([]auth.Token{
+		{Name: "oncall", Token: "operator-token", Role: auth.RoleOperator},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	authenticator := auth.NewChainAuthenticator(auth.NewCertificateAuthenticator(map[string][]auth.Scope{
+		"ou:sre": {auth.ScopeRuleRead, auth.ScopeRuleToggle},
+	}), tokenAuthenticator)
+	handler := NewAuthMiddleware(authenticator, logger).Middleware(
+		RequireScope(auth.ScopeRuleToggle, logger, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, audit.Actor(r.Context()))
+		}))
+
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = reloader.serverConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	newClient := func(subject *pkix.Name) *http.Client {
+		config := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+		if subject != nil {
+			clientCert, clientKey := ca.issue(t, *subject, x509.ExtKeyUsageClientAuth)
+			pair, pairErr := tls.X509KeyPair(clientCert, clientKey)
+			if pairErr != nil {
+				t.Fatalf("failed to load client certificate: %v", pairErr)
+			}
+			config.Certificates = []tls.Certificate{pair}
+		}
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	}
+
+	alice := &pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"sre"}}
+	bob := &pkix.Name{CommonName: "bob"}
+	tests := []struct {
+		name           string
+		subject        *pkix.Name
+		token          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"sre certificate", alice, "", http.StatusOK, "cert:alice"},
+		{"unmapped certificate", bob, "", http.StatusUnauthorized, ""},
+		{"unmapped certificate with token", bob, "operator-token", http.StatusOK, "token:oncall"},
+		{"no certificate", nil, "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL+"/v1/rule/enable", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, reqErr := newClient(tt.subject).Do(req)
+			if reqErr != nil {
+				t.Fatalf("request failed: %v", reqErr)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, body)
+			}
+			if tt.expectedBody != "" && string(body) != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, body)
+			}
+		})
+	}
+}
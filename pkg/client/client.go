@@ -0,0 +1,336 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+const (
+	// HTTP timeout for a single non-streaming API request.
+	defaultTimeout = 30 * time.Second
+	// Maximum size of a single Server-Sent Events line.
+	maxEventLineBytes = 1 << 20
+	// Default number of attempts for retryable failures.
+	defaultMaxAttempts = 3
+	// Default delay before the first retry.
+	defaultRetryBackoff = 500 * time.Millisecond
+)
+
+// Sentinel errors matched by APIError via errors.Is.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnavailable  = errors.New("service unavailable")
+	ErrServer       = errors.New("server error")
+)
+
+// errDecode marks responses that could not be decoded; they are not retried.
+var errDecode = errors.New("failed to decode response")
+
+// API is the set of cf-switch operations, implemented by Client and clienttest.Fake.
+type API interface {
+	GetRule(ctx context.Context) (*types.RuleResponse, error)
+	Toggle(ctx context.Context, enabled bool) (*types.RuleResponse, error)
+	UpdateHosts(ctx context.Context, hostnames []string) (*types.RuleResponse, error)
+	Watch(ctx context.Context, fn func(types.RuleEvent) error) error
+}
+
+// Client is a client for the cf-switch HTTP API.
+type Client struct {
+	baseURL      string
+	token        string
+	httpClient   *http.Client
+	transport    http.RoundTripper
+	userAgent    string
+	maxAttempts  int
+	retryBackoff time.Duration
+}
+
+// Client must satisfy API.
+var _ API = (*Client)(nil)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests.
+// Its timeout must be zero for Watch to stream indefinitely.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the RoundTripper used to send requests, replacing the HTTP client's own.
+// Use it to add instrumentation, e.g. otelhttp.NewTransport(http.DefaultTransport) to propagate traces.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithRetries sets how many times a request is attempted and the initial backoff between attempts.
+// Network errors, 429, 502, 503, and 504 responses are retried; maxAttempts of 1 disables retries.
+func WithRetries(maxAttempts int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = max(maxAttempts, 1)
+		c.retryBackoff = backoff
+	}
+}
+
+// New creates a client for the cf-switch API at baseURL authenticating with token.
+func New(baseURL, token string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		token:        token,
+		httpClient:   &http.Client{},
+		userAgent:    "cf-switch-client",
+		maxAttempts:  defaultMaxAttempts,
+		retryBackoff: defaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.transport != nil {
+		// Copy the client so that one passed with WithHTTPClient is not modified.
+		httpClient := *c.httpClient
+		httpClient.Transport = c.transport
+		c.httpClient = &httpClient
+	}
+	return c
+}
+
+// APIError is returned when the API responds with a non-2xx status.
+// It mirrors the server's ErrorResponse body.
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Status     string `json:"error"`
+	Message    string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("cf-switch API error: %d %s", e.StatusCode, e.Status)
+	}
+	return fmt.Sprintf("cf-switch API error: %d %s: %s", e.StatusCode, e.Status, e.Message)
+}
+
+// Unwrap returns the sentinel error matching the status code, if any.
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusServiceUnavailable:
+		return ErrUnavailable
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrServer
+	default:
+		return nil
+	}
+}
+
+// Retryable reports whether the request may succeed if repeated.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetRule returns the current rule state.
+func (c *Client) GetRule(ctx context.Context) (*types.RuleResponse, error) {
+	var rule types.RuleResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/rule", nil, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Toggle enables or disables the rule.
+func (c *Client) Toggle(ctx context.Context, enabled bool) (*types.RuleResponse, error) {
+	var rule types.RuleResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/rule/enable", types.ToggleRequest{Enabled: enabled}, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateHosts replaces the hostnames the rule applies to.
+func (c *Client) UpdateHosts(ctx context.Context, hostnames []string) (*types.RuleResponse, error) {
+	var rule types.RuleResponse
+	payload := types.UpdateHostsRequest{Hostnames: hostnames}
+	if err := c.do(ctx, http.MethodPut, "/v1/rule/hosts", payload, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Watch streams rule events to fn until ctx is done, the stream ends, or fn returns an error.
+func (c *Client) Watch(ctx context.Context, fn func(types.RuleEvent) error) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/v1/events", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to open event stream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxEventLineBytes)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var event types.RuleEvent
+			if err = json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return fmt.Errorf("failed to decode event: %w", err)
+			}
+			data.Reset()
+			if err = fn(event); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err = scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("event stream failed: %w", err)
+	}
+	return ctx.Err()
+}
+
+// do performs a JSON request with retries and decodes the response into out.
+func (c *Client) do(ctx context.Context, method, path string, payload, out interface{}) error {
+	backoff := c.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.doOnce(ctx, method, path, payload, out)
+		if err == nil || attempt >= c.maxAttempts || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (last error: %w)", ctx.Err(), err)
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// doOnce performs a single JSON request and decodes the response into out.
+func (c *Client) doOnce(ctx context.Context, method, path string, payload, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return decodeError(resp)
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: %w", errDecode, err)
+	}
+	return nil
+}
+
+// newRequest builds an authenticated API request.
+func (c *Client) newRequest(ctx context.Context, method, path string, payload interface{}) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("User-Agent", c.userAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// retryable reports whether err is worth retrying.
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	// Everything else is a transport failure; a caller-cancelled context is not retried.
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, errDecode)
+}
+
+// decodeError converts a non-2xx response into an *APIError.
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Status == "" {
+		apiErr.Status = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,232 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+func TestClient_RuleOperations(t *testing.T) {
+	rule := types.RuleResponse{RuleID: "rule-1", Hostnames: []string{"a.com"}, Version: 1}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Unauthorized","message":"Invalid token"}`))
+			return
+		}
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/rule":
+		case "POST /v1/rule/enable":
+			var req types.ToggleRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			rule.Enabled = req.Enabled
+			rule.Version++
+		case "PUT /v1/rule/hosts":
+			var req types.UpdateHostsRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			rule.Hostnames = req.Hostnames
+			rule.Version++
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(rule)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	c := New(server.URL+"/", "test-token")
+
+	got, err := c.GetRule(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.RuleID != "rule-1" {
+		t.Errorf("expected rule ID %q, got %q", "rule-1", got.RuleID)
+	}
+
+	got, err = c.Toggle(ctx, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.Enabled || got.Version != 2 {
+		t.Errorf("expected enabled rule at version 2, got %+v", got)
+	}
+
+	got, err = c.UpdateHosts(ctx, []string{"b.com", "c.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Hostnames) != 2 {
+		t.Errorf("expected 2 hostnames, got %v", got.Hostnames)
+	}
+
+	_, err = New(server.URL, "wrong-token").GetRule(ctx)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Invalid token" {
+		t.Errorf("unexpected API error: %+v", apiErr)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClient_WithTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Traceparent") == "" {
+			t.Error("expected the transport to add a traceparent header")
+		}
+		json.NewEncoder(w).Encode(types.RuleResponse{RuleID: "rule-1"})
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{}
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	c := New(server.URL, "test-token", WithHTTPClient(httpClient), WithTransport(transport))
+	if _, err := c.GetRule(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if httpClient.Transport != nil {
+		t.Error("expected the caller's HTTP client not to be modified")
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprint(w, "event: rule.snapshot\ndata: {\"type\":\"rule.snapshot\",\"rule\":{\"rule_id\":\"r\"}}\n\n")
+		fmt.Fprint(w, "event: rule.toggled\ndata: {\"type\":\"rule.toggled\",\"actor\":\"api-token\"}\n\n")
+	}))
+	defer server.Close()
+
+	var events []types.RuleEvent
+	err := New(server.URL, "token").Watch(context.Background(), func(event types.RuleEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].Rule == nil || events[0].Rule.ID != "r" {
+		t.Errorf("unexpected snapshot event: %+v", events[0])
+	}
+	if events[1].Type != types.EventRuleToggled || events[1].Actor != "api-token" {
+		t.Errorf("unexpected toggled event: %+v", events[1])
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantErr      error
+	}{
+		{"succeeds after transient failures", []int{503, 502, 200}, 3, nil},
+		{"gives up after max attempts", []int{503, 503, 503, 200}, 3, ErrUnavailable},
+		{"does not retry client errors", []int{400, 200}, 1, ErrBadRequest},
+		{"does not retry internal errors", []int{500, 200}, 1, ErrServer},
+		{"retries rate limiting", []int{429, 200}, 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				status := tt.statuses[attempts]
+				attempts++
+				if status != http.StatusOK {
+					w.WriteHeader(status)
+					w.Write([]byte(`{"error":"Failure","message":"try again"}`))
+					return
+				}
+				json.NewEncoder(w).Encode(types.RuleResponse{RuleID: "rule-1"})
+			}))
+			defer server.Close()
+
+			c := New(server.URL, "token", WithRetries(3, time.Millisecond))
+			_, err := c.GetRule(context.Background())
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestClient_RetryHonorsContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c := New(server.URL, "token", WithRetries(10, time.Hour))
+	_, err := c.Toggle(ctx, true)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected the last API error to be wrapped, got %v", err)
+	}
+}
+
+func TestAPIError_Is(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusServiceUnavailable, ErrUnavailable},
+		{http.StatusBadGateway, ErrServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: tt.status})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v to match %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := (&APIError{StatusCode: http.StatusConflict}); errors.Is(err, ErrServer) {
+		t.Error("expected 409 not to match ErrServer")
+	}
+}
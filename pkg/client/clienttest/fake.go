@@ -0,0 +1,199 @@
+// Package clienttest provides an in-memory implementation of client.API for tests.
+package clienttest
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/client"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+// Fake-specific defaults.
+const (
+	// Rule ID reported by a Fake created without one.
+	defaultRuleID = "fake-rule"
+	// Buffer size of each Watch subscription.
+	watchBuffer = 16
+)
+
+// Fake is an in-memory client.API that behaves like a cf-switch server.
+// It is safe for concurrent use.
+type Fake struct {
+	mu       sync.Mutex
+	rule     types.RuleResponse
+	err      error
+	calls    []string
+	watchers map[chan types.RuleEvent]struct{}
+}
+
+// Fake must satisfy client.API.
+var _ client.API = (*Fake)(nil)
+
+// NewFake creates a Fake holding a disabled rule for hostnames.
+func NewFake(hostnames ...string) *Fake {
+	return &Fake{
+		rule: types.RuleResponse{
+			RuleID:      defaultRuleID,
+			Enabled:     false,
+			Hostnames:   slices.Clone(hostnames),
+			Expression:  types.BuildExpression(hostnames),
+			Description: "cf-switch fake rule",
+			Version:     1,
+		},
+		watchers: make(map[chan types.RuleEvent]struct{}),
+	}
+}
+
+// SetRule replaces the stored rule without recording a call or publishing an event.
+func (f *Fake) SetRule(rule types.RuleResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rule = cloneRule(rule)
+}
+
+// SetError makes every subsequent call return err until it is reset with nil.
+// Use a *client.APIError to simulate server responses.
+func (f *Fake) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Calls returns the names of the methods called so far, in order.
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// GetRule returns the stored rule.
+func (f *Fake) GetRule(ctx context.Context) (*types.RuleResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(ctx, "GetRule"); err != nil {
+		return nil, err
+	}
+	rule := cloneRule(f.rule)
+	return &rule, nil
+}
+
+// Toggle sets the rule state and publishes a toggled event.
+func (f *Fake) Toggle(ctx context.Context, enabled bool) (*types.RuleResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(ctx, "Toggle"); err != nil {
+		return nil, err
+	}
+	f.rule.Enabled = enabled
+	return f.commit(types.EventRuleToggled), nil
+}
+
+// UpdateHosts replaces the hostnames and publishes a hosts-updated event.
+// Like the server, an empty list is rejected with a 400 APIError.
+func (f *Fake) UpdateHosts(ctx context.Context, hostnames []string) (*types.RuleResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(ctx, "UpdateHosts"); err != nil {
+		return nil, err
+	}
+	parsed := types.ParseHostnames(strings.Join(hostnames, ","))
+	if len(parsed) == 0 {
+		return nil, &client.APIError{
+			StatusCode: http.StatusBadRequest,
+			Status:     http.StatusText(http.StatusBadRequest),
+			Message:    "Hostnames list cannot be empty",
+		}
+	}
+	f.rule.Hostnames = parsed
+	f.rule.Expression = types.BuildExpression(parsed)
+	return f.commit(types.EventHostsUpdated), nil
+}
+
+// Watch sends a snapshot followed by every change to fn until ctx is done or fn returns an error.
+func (f *Fake) Watch(ctx context.Context, fn func(types.RuleEvent) error) error {
+	f.mu.Lock()
+	if err := f.begin(ctx, "Watch"); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	events := make(chan types.RuleEvent, watchBuffer)
+	f.watchers[events] = struct{}{}
+	snapshot := f.event(types.EventRuleSnapshot)
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.watchers, events)
+		f.mu.Unlock()
+	}()
+
+	if err := fn(snapshot); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event := <-events:
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// begin records a call and returns the injected or context error, if any. f.mu must be held.
+func (f *Fake) begin(ctx context.Context, method string) error {
+	f.calls = append(f.calls, method)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.err
+}
+
+// commit bumps the rule version, notifies watchers, and returns a copy of the rule. f.mu must be held.
+func (f *Fake) commit(eventType string) *types.RuleResponse {
+	f.rule.Version++
+
+	event := f.event(eventType)
+	for watcher := range f.watchers {
+		select {
+		case watcher <- event:
+		default:
+		}
+	}
+
+	rule := cloneRule(f.rule)
+	return &rule
+}
+
+// event builds an event for the stored rule. f.mu must be held.
+func (f *Fake) event(eventType string) types.RuleEvent {
+	return types.RuleEvent{
+		Type: eventType,
+		Rule: &types.Rule{
+			ID:          f.rule.RuleID,
+			Enabled:     f.rule.Enabled,
+			Expression:  f.rule.Expression,
+			Hostnames:   slices.Clone(f.rule.Hostnames),
+			Description: f.rule.Description,
+			Version:     f.rule.Version,
+		},
+		Actor:     "clienttest",
+		Timestamp: time.Now(),
+	}
+}
+
+// cloneRule copies a rule so callers cannot mutate the stored hostnames.
+func cloneRule(rule types.RuleResponse) types.RuleResponse {
+	rule.Hostnames = slices.Clone(rule.Hostnames)
+	return rule
+}
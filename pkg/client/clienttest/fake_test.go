@@ -0,0 +1,82 @@
+//nolint:testpackage // Using same package as implementation to test unexported functions
+package clienttest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/meyeringh/cf-switch/pkg/client"
+	"github.com/meyeringh/cf-switch/pkg/types"
+)
+
+func TestFake_RuleOperations(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("a.com")
+
+	rule, err := fake.Toggle(ctx, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !rule.Enabled || rule.Version != 2 {
+		t.Errorf("expected enabled rule at version 2, got %+v", rule)
+	}
+
+	rule, err = fake.UpdateHosts(ctx, []string{"B.com", " c.com "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(rule.Hostnames, []string{"b.com", "c.com"}) {
+		t.Errorf("expected normalized hostnames, got %v", rule.Hostnames)
+	}
+
+	_, err = fake.UpdateHosts(ctx, nil)
+	if !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("expected ErrBadRequest for empty hostnames, got %v", err)
+	}
+
+	fake.SetError(&client.APIError{StatusCode: 401, Status: "Unauthorized"})
+	if _, err = fake.GetRule(ctx); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("expected injected ErrUnauthorized, got %v", err)
+	}
+
+	want := []string{"Toggle", "UpdateHosts", "UpdateHosts", "GetRule"}
+	if calls := fake.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestFake_Watch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fake := NewFake("a.com")
+	received := make(chan types.RuleEvent, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- fake.Watch(ctx, func(event types.RuleEvent) error {
+			received <- event
+			if event.Type == types.EventRuleToggled {
+				return errors.New("stop")
+			}
+			return nil
+		})
+	}()
+
+	if event := <-received; event.Type != types.EventRuleSnapshot {
+		t.Fatalf("expected snapshot first, got %s", event.Type)
+	}
+	if _, err := fake.Toggle(ctx, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	event := <-received
+	if event.Type != types.EventRuleToggled || !event.Rule.Enabled {
+		t.Errorf("expected toggled event for enabled rule, got %+v", event)
+	}
+	if err := <-done; err == nil || err.Error() != "stop" {
+		t.Errorf("expected Watch to return the callback error, got %v", err)
+	}
+}
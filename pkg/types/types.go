@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"sort"
 	"strconv"
@@ -19,9 +20,73 @@ type Config struct {
 	DestHostnames        []string `json:"dest_hostnames"`
 	CFRuleDefaultEnabled bool     `json:"cf_rule_default_enabled"`
 
+	// Cloudflare API connection configuration.
+	CloudflareAPIBaseURL        string        `json:"cloudflare_api_base_url"`
+	CloudflareAPIProxy          string        `json:"-"` // May contain credentials.
+	CloudflareAPICAFile         string        `json:"cloudflare_api_ca_file"`
+	CloudflareAPITimeout        time.Duration `json:"cloudflare_api_timeout"`
+	CloudflareAPIConnectTimeout time.Duration `json:"cloudflare_api_connect_timeout"`
+	// Consecutive failed requests that open the circuit breaker; 0 disables it.
+	CloudflareCircuitThreshold   int           `json:"cloudflare_circuit_threshold"`
+	CloudflareCircuitOpenTimeout time.Duration `json:"cloudflare_circuit_open_timeout"`
+	// Whether to check the API token permissions at startup and log missing ones.
+	CloudflareStartupCheck bool `json:"cloudflare_startup_check"`
+
 	// Server configuration.
 	HTTPAddr          string        `json:"http_addr"`
 	ReconcileInterval time.Duration `json:"reconcile_interval"`
+	// ReconcileRetryDelay is the first retry delay after a failed reconcile; it doubles up to ReconcileInterval.
+	ReconcileRetryDelay time.Duration `json:"reconcile_retry_delay"`
+	// ReadinessMaxReconcileAge is how old the last successful reconcile may be for /readyz to pass.
+	ReadinessMaxReconcileAge time.Duration `json:"readiness_max_reconcile_age"`
+
+	// Integration configuration.
+	NotifyConfigFile       string   `json:"notify_config_file"`
+	AlertmanagerConfigFile string   `json:"alertmanager_config_file"`
+	SlackSigningSecret     string   `json:"-"` // Never log this.
+	SlackAllowedUserIDs    []string `json:"slack_allowed_user_ids"`
+	SlackAllowAllUsers     bool     `json:"slack_allow_all_users"`
+	AuthTokensFile         string   `json:"auth_tokens_file"`
+
+	// TokenGracePeriod keeps replaced API tokens valid after rotation.
+	TokenGracePeriod time.Duration `json:"token_grace_period"`
+
+	// API rate limiting and brute-force protection.
+	RateLimitIPRPS       float64       `json:"rate_limit_ip_rps"`
+	RateLimitIPBurst     int           `json:"rate_limit_ip_burst"`
+	RateLimitTokenRPS    float64       `json:"rate_limit_token_rps"`
+	RateLimitTokenBurst  int           `json:"rate_limit_token_burst"`
+	AuthLockoutThreshold int           `json:"auth_lockout_threshold"`
+	AuthLockoutDuration  time.Duration `json:"auth_lockout_duration"`
+	TrustedProxies       []string      `json:"trusted_proxies"`
+
+	// OIDC/JWT authentication configuration.
+	OIDCIssuerURL     string `json:"oidc_issuer_url"`
+	OIDCAudience      string `json:"oidc_audience"`
+	OIDCJWKSURL       string `json:"oidc_jwks_url"`
+	OIDCUsernameClaim string `json:"oidc_username_claim"`
+	OIDCGroupsClaim   string `json:"oidc_groups_claim"`
+	OIDCGroupScopes   string `json:"oidc_group_scopes"`
+
+	// Kubernetes ServiceAccount token authentication configuration.
+	ServiceAccountAuth      bool     `json:"service_account_auth"`
+	ServiceAccountAudiences []string `json:"service_account_audiences"`
+	ServiceAccountIssuer    string   `json:"service_account_issuer"`
+
+	// TLS serving configuration.
+	TLSCertFile          string `json:"tls_cert_file"`
+	TLSKeyFile           string `json:"tls_key_file"`
+	TLSClientCAFile      string `json:"tls_client_ca_file"`
+	TLSRequireClientCert bool   `json:"tls_require_client_cert"`
+	TLSClientScopes      string `json:"tls_client_scopes"`
+	TLSPeerCAFile        string `json:"tls_peer_ca_file"`
+
+	// Leader election among replicas through a Kubernetes Lease.
+	LeaderElection              bool          `json:"leader_election"`
+	LeaderElectionLeaseDuration time.Duration `json:"leader_election_lease_duration"`
+
+	// TracingEnabled exports OpenTelemetry traces via OTLP, configured with the OTEL_* variables.
+	TracingEnabled bool `json:"tracing_enabled"`
 
 	// Development configuration.
 	RunningLocally bool `json:"running_locally"`
@@ -29,6 +94,8 @@ type Config struct {
 	// Kubernetes configuration (derived from environment).
 	Namespace          string `json:"namespace"`
 	ServiceAccountName string `json:"service_account_name"`
+	PodName            string `json:"pod_name"`
+	PodIP              string `json:"pod_ip"`
 }
 
 // Rule represents the Cloudflare WAF Custom Rule.
@@ -61,6 +128,54 @@ type RuleResponse struct {
 	Version     int      `json:"version"`
 }
 
+// ReconcileStatus reports the state of the reconciliation loop.
+type ReconcileStatus struct {
+	RuleCached          bool      `json:"rule_cached"`
+	RulesetID           string    `json:"ruleset_id,omitempty"`
+	RuleID              string    `json:"rule_id,omitempty"`
+	LastReconcileTime   time.Time `json:"last_reconcile_time,omitzero"`
+	LastSuccessTime     time.Time `json:"last_success_time,omitzero"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	DriftDetected       bool      `json:"drift_detected"`
+	LastDriftTime       time.Time `json:"last_drift_time,omitzero"`
+	NextReconcileTime   time.Time `json:"next_reconcile_time,omitzero"`
+	CloudflareCircuit   string    `json:"cloudflare_circuit,omitempty"`
+	// Role is "leader" or "follower" with leader election and empty otherwise.
+	Role   string `json:"role,omitempty"`
+	Leader string `json:"leader,omitempty"`
+}
+
+// StatusResponse represents the response for service status.
+type StatusResponse struct {
+	Ready       bool   `json:"ready"`
+	ReadyReason string `json:"ready_reason,omitempty"`
+
+	ReconcileStatus
+}
+
+// ReconcileTriggerResponse is returned after requesting a reconciliation.
+type ReconcileTriggerResponse struct {
+	// Queued is false if the request was merged into an already pending reconciliation.
+	Queued bool `json:"queued"`
+}
+
+// TokenRotationResponse is returned after rotating the default API token.
+type TokenRotationResponse struct {
+	Token                   string    `json:"token"`
+	GracePeriodSeconds      int       `json:"grace_period_seconds"`
+	PreviousTokenValidUntil time.Time `json:"previous_token_valid_until"`
+}
+
+// RuleEvent represents a change notification for the managed rule.
+type RuleEvent struct {
+	Type      string    `json:"type"`
+	Rule      *Rule     `json:"rule,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // CloudflareRuleset represents a Cloudflare ruleset.
 type CloudflareRuleset struct {
 	ID          string           `json:"id"`
@@ -125,6 +240,44 @@ type CloudflareRule struct {
 	Version     FlexibleInt `json:"version,omitempty"`
 }
 
+// CloudflareTokenStatus is the result of verifying a Cloudflare API token.
+type CloudflareTokenStatus struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	ExpiresOn string `json:"expires_on,omitempty"`
+}
+
+// CloudflareToken is a Cloudflare API token with its policies.
+type CloudflareToken struct {
+	ID       string                  `json:"id"`
+	Status   string                  `json:"status"`
+	Policies []CloudflareTokenPolicy `json:"policies"`
+}
+
+// CloudflareTokenPolicy grants or denies permission groups on resources.
+// Resource values are "*" or, for accounts, nested resource maps.
+type CloudflareTokenPolicy struct {
+	Effect           string                      `json:"effect"`
+	PermissionGroups []CloudflarePermissionGroup `json:"permission_groups"`
+	Resources        map[string]json.RawMessage  `json:"resources"`
+}
+
+// CloudflarePermissionGroup is a named set of API permissions.
+type CloudflarePermissionGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// CloudflareZone represents a Cloudflare zone.
+type CloudflareZone struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Account struct {
+		ID string `json:"id"`
+	} `json:"account"`
+}
+
 // CloudflareAPIError represents an error response from Cloudflare API.
 type CloudflareAPIError struct {
 	Code    int    `json:"code"`
@@ -141,11 +294,34 @@ type CloudflareAPIResponse struct {
 // LoadConfig loads configuration from environment variables.
 func LoadConfig() (*Config, error) {
 	config := &Config{
-		HTTPAddr:             getEnvOrDefault("HTTP_ADDR", ":8080"),
-		CFRuleDefaultEnabled: getEnvBoolOrDefault("CF_RULE_DEFAULT_ENABLED", false),
-		RunningLocally:       getEnvBoolOrDefault("RUNNING_LOCALLY", false),
-		Namespace:            getEnvOrDefault("KUBERNETES_NAMESPACE", "default"),
-		ServiceAccountName:   getEnvOrDefault("KUBERNETES_SERVICE_ACCOUNT", "cf-switch"),
+		HTTPAddr:                getEnvOrDefault("HTTP_ADDR", ":8080"),
+		CFRuleDefaultEnabled:    getEnvBoolOrDefault("CF_RULE_DEFAULT_ENABLED", false),
+		RunningLocally:          getEnvBoolOrDefault("RUNNING_LOCALLY", false),
+		Namespace:               getEnvOrDefault("KUBERNETES_NAMESPACE", "default"),
+		ServiceAccountName:      getEnvOrDefault("KUBERNETES_SERVICE_ACCOUNT", "cf-switch"),
+		NotifyConfigFile:        os.Getenv("NOTIFY_CONFIG_FILE"),
+		AlertmanagerConfigFile:  os.Getenv("ALERTMANAGER_CONFIG_FILE"),
+		SlackSigningSecret:      os.Getenv("SLACK_SIGNING_SECRET"),
+		SlackAllowedUserIDs:     splitList(os.Getenv("SLACK_ALLOWED_USER_IDS")),
+		SlackAllowAllUsers:      getEnvBoolOrDefault("SLACK_ALLOW_ALL_USERS", false),
+		AuthTokensFile:          os.Getenv("AUTH_TOKENS_FILE"),
+		OIDCIssuerURL:           os.Getenv("OIDC_ISSUER_URL"),
+		OIDCAudience:            os.Getenv("OIDC_AUDIENCE"),
+		OIDCJWKSURL:             os.Getenv("OIDC_JWKS_URL"),
+		OIDCUsernameClaim:       os.Getenv("OIDC_USERNAME_CLAIM"),
+		OIDCGroupsClaim:         os.Getenv("OIDC_GROUPS_CLAIM"),
+		OIDCGroupScopes:         os.Getenv("OIDC_GROUP_SCOPES"),
+		ServiceAccountAuth:      getEnvBoolOrDefault("SERVICE_ACCOUNT_AUTH", false),
+		ServiceAccountAudiences: splitList(os.Getenv("SERVICE_ACCOUNT_AUDIENCES")),
+		ServiceAccountIssuer:    os.Getenv("SERVICE_ACCOUNT_ISSUER"),
+		TLSCertFile:             os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:              os.Getenv("TLS_KEY_FILE"),
+		TLSClientCAFile:         os.Getenv("TLS_CLIENT_CA_FILE"),
+		TLSRequireClientCert:    getEnvBoolOrDefault("TLS_REQUIRE_CLIENT_CERT", false),
+		TLSClientScopes:         os.Getenv("TLS_CLIENT_SCOPES"),
+		TLSPeerCAFile:           os.Getenv("TLS_PEER_CA_FILE"),
+		TrustedProxies:          splitList(os.Getenv("TRUSTED_PROXIES")),
+		TracingEnabled:          getEnvBoolOrDefault("TRACING_ENABLED", false),
 	}
 
 	// Parse required fields.
@@ -159,6 +335,10 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.New("CLOUDFLARE_API_TOKEN is required")
 	}
 
+	if err := loadCloudflareAPIConfig(config); err != nil {
+		return nil, err
+	}
+
 	destHostnamesStr := os.Getenv("DEST_HOSTNAMES")
 	if destHostnamesStr == "" {
 		return nil, errors.New("DEST_HOSTNAMES is required")
@@ -177,9 +357,131 @@ func LoadConfig() (*Config, error) {
 	}
 	config.ReconcileInterval = interval
 
+	if config.ReconcileRetryDelay, err = getEnvDuration("RECONCILE_RETRY_DELAY", "5s"); err != nil {
+		return nil, err
+	}
+
+	// Parse readiness threshold, defaulting to three missed reconciles.
+	config.ReadinessMaxReconcileAge = 3 * interval //nolint:mnd // Tolerate two failed reconciles.
+	if value := os.Getenv("READINESS_MAX_RECONCILE_AGE"); value != "" {
+		config.ReadinessMaxReconcileAge, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid READINESS_MAX_RECONCILE_AGE: %w", err)
+		}
+	}
+
+	// Parse token rotation grace period.
+	gracePeriod, err := time.ParseDuration(getEnvOrDefault("TOKEN_ROTATION_GRACE_PERIOD", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOKEN_ROTATION_GRACE_PERIOD: %w", err)
+	}
+	if gracePeriod < 0 {
+		return nil, errors.New("TOKEN_ROTATION_GRACE_PERIOD must not be negative")
+	}
+	config.TokenGracePeriod = gracePeriod
+
+	if err = loadRateLimitConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err = loadLeaderElectionConfig(config); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+// loadCloudflareAPIConfig parses the Cloudflare API endpoint, proxy, CA bundle, timeouts, and circuit breaker.
+func loadCloudflareAPIConfig(config *Config) error {
+	config.CloudflareAPIBaseURL = getEnvOrDefault("CLOUDFLARE_API_BASE_URL", "https://api.cloudflare.com/client/v4")
+	if !isHTTPURL(config.CloudflareAPIBaseURL) {
+		return errors.New("CLOUDFLARE_API_BASE_URL must be an http or https URL")
+	}
+
+	config.CloudflareAPIProxy = os.Getenv("CLOUDFLARE_API_PROXY")
+	if config.CloudflareAPIProxy != "" && !isHTTPURL(config.CloudflareAPIProxy) {
+		return errors.New("CLOUDFLARE_API_PROXY must be an http or https URL")
+	}
+	config.CloudflareAPICAFile = os.Getenv("CLOUDFLARE_API_CA_FILE")
+
+	var err error
+	if config.CloudflareAPITimeout, err = getEnvDuration("CLOUDFLARE_API_TIMEOUT", "30s"); err != nil {
+		return err
+	}
+	if config.CloudflareAPIConnectTimeout, err = getEnvDuration("CLOUDFLARE_API_CONNECT_TIMEOUT", "10s"); err != nil {
+		return err
+	}
+	if config.CloudflareCircuitThreshold, err = getEnvInt("CLOUDFLARE_CIRCUIT_THRESHOLD", "5"); err != nil {
+		return err
+	}
+	if config.CloudflareCircuitOpenTimeout, err = getEnvDuration("CLOUDFLARE_CIRCUIT_OPEN_TIMEOUT", "30s"); err != nil {
+		return err
+	}
+	config.CloudflareStartupCheck = getEnvBoolOrDefault("CLOUDFLARE_STARTUP_CHECK", false)
+	return nil
+}
+
+// isHTTPURL reports whether value is an absolute http or https URL.
+func isHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// loadRateLimitConfig parses the rate limit and lockout settings. Zero disables a limit.
+func loadRateLimitConfig(config *Config) error {
+	var err error
+	if config.RateLimitIPRPS, err = getEnvFloat("RATE_LIMIT_IP_RPS", "20"); err != nil {
+		return err
+	}
+	if config.RateLimitIPBurst, err = getEnvInt("RATE_LIMIT_IP_BURST", "40"); err != nil {
+		return err
+	}
+	if config.RateLimitTokenRPS, err = getEnvFloat("RATE_LIMIT_TOKEN_RPS", "10"); err != nil {
+		return err
+	}
+	if config.RateLimitTokenBurst, err = getEnvInt("RATE_LIMIT_TOKEN_BURST", "20"); err != nil {
+		return err
+	}
+	if config.AuthLockoutThreshold, err = getEnvInt("AUTH_LOCKOUT_THRESHOLD", "10"); err != nil {
+		return err
+	}
+
+	config.AuthLockoutDuration, err = time.ParseDuration(getEnvOrDefault("AUTH_LOCKOUT_DURATION", "15m"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_LOCKOUT_DURATION: %w", err)
+	}
+	if config.AuthLockoutThreshold > 0 && config.AuthLockoutDuration <= 0 {
+		return errors.New("AUTH_LOCKOUT_DURATION must be positive when AUTH_LOCKOUT_THRESHOLD is set")
+	}
+	return nil
+}
+
+// loadLeaderElectionConfig parses the leader election settings and the pod identity they require.
+func loadLeaderElectionConfig(config *Config) error {
+	config.LeaderElection = getEnvBoolOrDefault("LEADER_ELECTION", false)
+	config.PodName = os.Getenv("POD_NAME")
+	if config.PodName == "" {
+		config.PodName, _ = os.Hostname()
+	}
+	config.PodIP = os.Getenv("POD_IP")
+
+	var err error
+	if config.LeaderElectionLeaseDuration, err = getEnvDuration("LEADER_ELECTION_LEASE_DURATION", "15s"); err != nil {
+		return err
+	}
+
+	if !config.LeaderElection {
+		return nil
+	}
+	if config.RunningLocally {
+		return errors.New("LEADER_ELECTION requires running in Kubernetes")
+	}
+	if config.PodIP == "" {
+		return errors.New("POD_IP is required for LEADER_ELECTION")
+	}
+	return nil
+}
+
 // ParseHostnames parses and normalizes a comma-separated list of hostnames.
 func ParseHostnames(hostnames string) []string {
 	if hostnames == "" {
@@ -217,6 +519,17 @@ func BuildExpression(hostnames []string) string {
 	return fmt.Sprintf(`http.host in {%s}`, strings.Join(quoted, " "))
 }
 
+// splitList splits a comma-separated list, trimming whitespace and dropping empty items.
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 // getEnvOrDefault returns the environment variable value or a default.
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -225,6 +538,42 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvFloat parses the environment variable as a non-negative number, using defaultValue if unset.
+func getEnvFloat(key, defaultValue string) (float64, error) {
+	value, err := strconv.ParseFloat(getEnvOrDefault(key, defaultValue), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("%s must not be negative", key)
+	}
+	return value, nil
+}
+
+// getEnvInt parses the environment variable as a non-negative integer, using defaultValue if unset.
+func getEnvInt(key, defaultValue string) (int, error) {
+	value, err := strconv.Atoi(getEnvOrDefault(key, defaultValue))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("%s must not be negative", key)
+	}
+	return value, nil
+}
+
+// getEnvDuration parses the environment variable as a positive duration or returns a default.
+func getEnvDuration(key, defaultValue string) (time.Duration, error) {
+	value, err := time.ParseDuration(getEnvOrDefault(key, defaultValue))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("%s must be positive", key)
+	}
+	return value, nil
+}
+
 // getEnvBoolOrDefault returns the environment variable as a boolean or a default.
 func getEnvBoolOrDefault(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
@@ -245,3 +594,20 @@ const (
 	// BlockAction is the action for blocking requests.
 	BlockAction = "block"
 )
+
+const (
+	// EventRuleSnapshot carries the current rule state when a subscriber connects.
+	EventRuleSnapshot = "rule.snapshot"
+	// EventRuleCreated is emitted when the managed rule is created in Cloudflare.
+	EventRuleCreated = "rule.created"
+	// EventRuleToggled is emitted when the rule is enabled or disabled via the API.
+	EventRuleToggled = "rule.toggled"
+	// EventHostsUpdated is emitted when the rule hostnames are replaced via the API.
+	EventHostsUpdated = "rule.hosts_updated"
+	// EventDriftCorrected is emitted when reconciliation rewrites a drifted rule.
+	EventDriftCorrected = "rule.drift_corrected"
+	// EventRuleRefreshed is emitted when reconciliation observes an externally changed rule.
+	EventRuleRefreshed = "rule.refreshed"
+	// EventReconcileError is emitted when a reconciliation cycle fails.
+	EventReconcileError = "reconcile.error"
+)
@@ -4,6 +4,7 @@ package types
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestParseHostnames(t *testing.T) {
@@ -181,6 +182,10 @@ func TestLoadConfig(t *testing.T) {
 		if len(config.DestHostnames) != 2 {
 			t.Errorf("expected 2 hostnames, got %d", len(config.DestHostnames))
 		}
+
+		if config.SlackAllowAllUsers {
+			t.Error("expected Slack changes to be limited to allowed users by default")
+		}
 	})
 
 	t.Run("invalid reconcile interval", func(t *testing.T) {
@@ -195,16 +200,250 @@ func TestLoadConfig(t *testing.T) {
 			t.Error("expected error for invalid reconcile interval")
 		}
 	})
+
+	t.Run("invalid reconcile retry delay", func(t *testing.T) {
+		clearEnv()
+		setEnv("DEST_HOSTNAMES", "test.com")
+		setEnv("CLOUDFLARE_ZONE_ID", "test-zone")
+		setEnv("CLOUDFLARE_API_TOKEN", "test-token")
+		setEnv("RECONCILE_RETRY_DELAY", "0s")
+
+		_, err := LoadConfig()
+		if err == nil {
+			t.Error("expected error for a zero reconcile retry delay")
+		}
+	})
+
+	t.Run("leader election", func(t *testing.T) {
+		clearEnv()
+		setEnv("DEST_HOSTNAMES", "test.com")
+		setEnv("CLOUDFLARE_ZONE_ID", "test-zone")
+		setEnv("CLOUDFLARE_API_TOKEN", "test-token")
+
+		config, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.LeaderElection || config.LeaderElectionLeaseDuration != 15*time.Second {
+			t.Errorf("expected leader election disabled with a 15s lease, got %v and %v",
+				config.LeaderElection, config.LeaderElectionLeaseDuration)
+		}
+
+		setEnv("LEADER_ELECTION", "true")
+		if _, err = LoadConfig(); err == nil {
+			t.Error("expected error for leader election without POD_IP")
+		}
+
+		setEnv("POD_NAME", "cf-switch-0")
+		setEnv("POD_IP", "10.0.0.1")
+		config, err = LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !config.LeaderElection || config.PodName != "cf-switch-0" || config.PodIP != "10.0.0.1" {
+			t.Errorf("unexpected leader election config: %+v", config)
+		}
+	})
+
+	t.Run("token grace period", func(t *testing.T) {
+		clearEnv()
+		setEnv("DEST_HOSTNAMES", "test.com")
+		setEnv("CLOUDFLARE_ZONE_ID", "test-zone")
+		setEnv("CLOUDFLARE_API_TOKEN", "test-token")
+
+		config, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.TokenGracePeriod != 5*time.Minute {
+			t.Errorf("expected default grace period 5m, got %v", config.TokenGracePeriod)
+		}
+		if config.ReadinessMaxReconcileAge != 3*time.Minute {
+			t.Errorf("expected default readiness age 3m, got %v", config.ReadinessMaxReconcileAge)
+		}
+		if config.ReconcileRetryDelay != 5*time.Second {
+			t.Errorf("expected default reconcile retry delay 5s, got %v", config.ReconcileRetryDelay)
+		}
+
+		setEnv("TOKEN_ROTATION_GRACE_PERIOD", "-1s")
+		if _, err = LoadConfig(); err == nil {
+			t.Error("expected error for negative grace period")
+		}
+	})
+
+	t.Run("rate limits", func(t *testing.T) {
+		clearEnv()
+		setEnv("DEST_HOSTNAMES", "test.com")
+		setEnv("CLOUDFLARE_ZONE_ID", "test-zone")
+		setEnv("CLOUDFLARE_API_TOKEN", "test-token")
+		setEnv("RATE_LIMIT_IP_RPS", "0.5")
+		setEnv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.1")
+
+		config, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.RateLimitIPRPS != 0.5 || config.RateLimitTokenRPS != 10 || config.AuthLockoutThreshold != 10 {
+			t.Errorf("unexpected rate limits: %+v", config)
+		}
+		if len(config.TrustedProxies) != 2 {
+			t.Errorf("expected 2 trusted proxies, got %v", config.TrustedProxies)
+		}
+
+		setEnv("RATE_LIMIT_TOKEN_BURST", "many")
+		if _, err = LoadConfig(); err == nil {
+			t.Error("expected error for invalid burst")
+		}
+	})
+
+	t.Run("service account issuer", func(t *testing.T) {
+		clearEnv()
+		setEnv("DEST_HOSTNAMES", "test.com")
+		setEnv("CLOUDFLARE_ZONE_ID", "test-zone")
+		setEnv("CLOUDFLARE_API_TOKEN", "test-token")
+		setEnv("SERVICE_ACCOUNT_ISSUER", "https://kubernetes.default.svc")
+
+		config, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.ServiceAccountIssuer != "https://kubernetes.default.svc" {
+			t.Errorf("unexpected issuer %q", config.ServiceAccountIssuer)
+		}
+	})
+
+	t.Run("TLS peer CA file", func(t *testing.T) {
+		clearEnv()
+		setEnv("DEST_HOSTNAMES", "test.com")
+		setEnv("CLOUDFLARE_ZONE_ID", "test-zone")
+		setEnv("CLOUDFLARE_API_TOKEN", "test-token")
+		setEnv("TLS_PEER_CA_FILE", "/etc/cf-switch/tls/ca.crt")
+
+		config, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.TLSPeerCAFile != "/etc/cf-switch/tls/ca.crt" {
+			t.Errorf("unexpected peer CA file %q", config.TLSPeerCAFile)
+		}
+	})
+
+	t.Run("cloudflare API base URL", func(t *testing.T) {
+		clearEnv()
+		setEnv("DEST_HOSTNAMES", "test.com")
+		setEnv("CLOUDFLARE_ZONE_ID", "test-zone")
+		setEnv("CLOUDFLARE_API_TOKEN", "test-token")
+
+		config, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.CloudflareAPIBaseURL != "https://api.cloudflare.com/client/v4" {
+			t.Errorf("expected the Cloudflare API by default, got %q", config.CloudflareAPIBaseURL)
+		}
+
+		setEnv("CLOUDFLARE_API_BASE_URL", "localhost:8787")
+		if _, err = LoadConfig(); err == nil {
+			t.Error("expected error for a base URL without scheme")
+		}
+	})
+
+	t.Run("cloudflare API transport", func(t *testing.T) {
+		clearEnv()
+		setEnv("DEST_HOSTNAMES", "test.com")
+		setEnv("CLOUDFLARE_ZONE_ID", "test-zone")
+		setEnv("CLOUDFLARE_API_TOKEN", "test-token")
+
+		config, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.CloudflareAPIProxy != "" || config.CloudflareAPICAFile != "" {
+			t.Errorf("expected no proxy or CA bundle by default, got %q and %q",
+				config.CloudflareAPIProxy, config.CloudflareAPICAFile)
+		}
+		if config.CloudflareAPITimeout != 30*time.Second || config.CloudflareAPIConnectTimeout != 10*time.Second {
+			t.Errorf("expected 30s and 10s timeouts, got %v and %v",
+				config.CloudflareAPITimeout, config.CloudflareAPIConnectTimeout)
+		}
+		if config.CloudflareCircuitThreshold != 5 || config.CloudflareCircuitOpenTimeout != 30*time.Second {
+			t.Errorf("expected circuit breaker after 5 failures for 30s, got %d and %v",
+				config.CloudflareCircuitThreshold, config.CloudflareCircuitOpenTimeout)
+		}
+		if config.CloudflareStartupCheck {
+			t.Error("expected no startup permission check by default")
+		}
+
+		setEnv("CLOUDFLARE_API_PROXY", "http://proxy.internal:3128")
+		setEnv("CLOUDFLARE_API_CA_FILE", "/etc/ssl/proxy-ca.pem")
+		setEnv("CLOUDFLARE_API_TIMEOUT", "45s")
+		setEnv("CLOUDFLARE_API_CONNECT_TIMEOUT", "5s")
+		config, err = LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.CloudflareAPIProxy != "http://proxy.internal:3128" ||
+			config.CloudflareAPICAFile != "/etc/ssl/proxy-ca.pem" {
+			t.Errorf("unexpected proxy %q or CA bundle %q", config.CloudflareAPIProxy, config.CloudflareAPICAFile)
+		}
+		if config.CloudflareAPITimeout != 45*time.Second || config.CloudflareAPIConnectTimeout != 5*time.Second {
+			t.Errorf("expected 45s and 5s timeouts, got %v and %v",
+				config.CloudflareAPITimeout, config.CloudflareAPIConnectTimeout)
+		}
+
+		setEnv("CLOUDFLARE_API_PROXY", "proxy.internal:3128")
+		if _, err = LoadConfig(); err == nil {
+			t.Error("expected error for a proxy without scheme")
+		}
+
+		setEnv("CLOUDFLARE_API_PROXY", "")
+		setEnv("CLOUDFLARE_API_TIMEOUT", "0s")
+		if _, err = LoadConfig(); err == nil {
+			t.Error("expected error for a zero timeout")
+		}
+
+		setEnv("CLOUDFLARE_API_TIMEOUT", "30s")
+		setEnv("CLOUDFLARE_CIRCUIT_THRESHOLD", "0")
+		if config, err = LoadConfig(); err != nil || config.CloudflareCircuitThreshold != 0 {
+			t.Errorf("expected 0 to disable the circuit breaker, got %v", err)
+		}
+		setEnv("CLOUDFLARE_CIRCUIT_OPEN_TIMEOUT", "soon")
+		if _, err = LoadConfig(); err == nil {
+			t.Error("expected error for an invalid circuit open timeout")
+		}
+	})
 }
 
 // Helper functions for testing.
 func clearEnv() {
 	os.Unsetenv("CLOUDFLARE_ZONE_ID")
 	os.Unsetenv("CLOUDFLARE_API_TOKEN")
+	os.Unsetenv("CLOUDFLARE_API_BASE_URL")
+	os.Unsetenv("CLOUDFLARE_API_PROXY")
+	os.Unsetenv("CLOUDFLARE_API_CA_FILE")
+	os.Unsetenv("CLOUDFLARE_API_TIMEOUT")
+	os.Unsetenv("CLOUDFLARE_API_CONNECT_TIMEOUT")
+	os.Unsetenv("CLOUDFLARE_CIRCUIT_THRESHOLD")
+	os.Unsetenv("CLOUDFLARE_CIRCUIT_OPEN_TIMEOUT")
+	os.Unsetenv("CLOUDFLARE_STARTUP_CHECK")
 	os.Unsetenv("DEST_HOSTNAMES")
 	os.Unsetenv("CF_RULE_DEFAULT_ENABLED")
 	os.Unsetenv("HTTP_ADDR")
 	os.Unsetenv("RECONCILE_INTERVAL")
+	os.Unsetenv("RECONCILE_RETRY_DELAY")
+	os.Unsetenv("LEADER_ELECTION")
+	os.Unsetenv("LEADER_ELECTION_LEASE_DURATION")
+	os.Unsetenv("POD_NAME")
+	os.Unsetenv("POD_IP")
+	os.Unsetenv("TOKEN_ROTATION_GRACE_PERIOD")
+	os.Unsetenv("RATE_LIMIT_IP_RPS")
+	os.Unsetenv("RATE_LIMIT_TOKEN_BURST")
+	os.Unsetenv("TRUSTED_PROXIES")
+	os.Unsetenv("TRACING_ENABLED")
+	os.Unsetenv("SLACK_ALLOWED_USER_IDS")
+	os.Unsetenv("SLACK_ALLOW_ALL_USERS")
+	os.Unsetenv("SERVICE_ACCOUNT_ISSUER")
+	os.Unsetenv("TLS_PEER_CA_FILE")
 	os.Unsetenv("NAMESPACE")
 	os.Unsetenv("SERVICE_ACCOUNT_NAME")
 }